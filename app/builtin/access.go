@@ -87,6 +87,11 @@ var accessGrantCmd = func(sess cli.Session) *cobra.Command {
 				sess.Exit(77)
 				return nil
 			}
+			if err := checkSubjects(args[1:]); err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(64)
+				return nil
+			}
 			cli.Status(sess, fmt.Sprintf(
 				"Granting %s access to %s",
 				cli.Bright(strings.Join(args[1:], ", ")), cli.Bright(cmd.Name)))
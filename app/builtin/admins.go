@@ -84,6 +84,11 @@ var adminsGrantCmd = func(sess cli.Session) *cobra.Command {
 				sess.Exit(cli.StatusNoPerm)
 				return nil
 			}
+			if err := checkSubjects(args[1:]); err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
 			cli.Status(sess, fmt.Sprintf(
 				"Granting %s admin to %s",
 				cli.Bright(strings.Join(args[1:], ", ")), cli.Bright(cmd.Name)))
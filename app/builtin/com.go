@@ -23,6 +23,18 @@ func (c *Component) BuiltinCommands() []cli.CommandFactory {
 		editCmd,
 		tokensCmd,
 		sourceCmd,
+		historyCmd,
+		rollbackCmd,
+		diffCmd,
+		runCmd,
+		jobsCmd,
+		logsCmd,
+		attachCmd,
+		killCmd,
+		scheduleCmd,
+		runsCmd,
+		webhookCmd,
+		keysCmd,
 	}
 }
 
@@ -21,6 +21,7 @@ var createCmd = func(sess cli.Session) *cobra.Command {
 			limit := billing.ContextPlan(sess.Context()).MaxCmds
 			cmds := store.Selected().List(sess.User())
 			flags := c.Flags()
+			args = flags.Args()
 			if len(cmds) >= limit {
 				fmt.Fprintln(sess.Stderr(), "Command limit for plan reached")
 				sess.Exit(cli.StatusNoPerm)
@@ -54,13 +55,21 @@ var createCmd = func(sess cli.Session) *cobra.Command {
 				cmd.Description = c.Flags().Lookup("description").Value.String()
 			}
 
-			if err := cmd.Build(); err != nil {
+			quiet, _ := flags.GetBool("quiet")
+			if err := cmd.Build(buildOutput(sess, quiet)); err != nil {
 				log.Info(err)
 				cli.StatusErr(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusCreateError)
+				return nil
+			}
+			// clear any history left by a deleted command of the same name
+			if err := store.Selected().DeleteRevisions(cmd.User, cmd.Name); err != nil {
+				log.Info(sess, cmd, err)
+				cli.StatusErr(sess.Stderr(), err.Error())
 				sess.Exit(cli.StatusInternalError)
 				return nil
 			}
-			if err := store.Selected().Put(cmd.User, cmd.Name, cmd); err != nil {
+			if err := putCmd(cmd, core.RevisionCreate, sess.User()); err != nil {
 				log.Info(sess, cmd, err)
 				cli.StatusErr(sess.Stderr(), err.Error())
 				sess.Exit(cli.StatusInternalError)
@@ -70,6 +79,7 @@ var createCmd = func(sess cli.Session) *cobra.Command {
 			return nil
 		},
 	}
-	cli.AddFlag(retval, cli.Flag{"description", "", "add descriptive text", "d", "string"})
+	cli.AddFlag(retval, cli.Flag{Name: "description", Value: "", Usage: "add descriptive text", Shorthand: "d", Kind: "string"})
+	cli.AddFlag(retval, cli.Flag{Name: "quiet", Value: false, Usage: "suppress build output", Shorthand: "q", Kind: "bool"})
 	return retval
-}
\ No newline at end of file
+}
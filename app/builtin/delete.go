@@ -34,6 +34,13 @@ var deleteCmd = func(sess cli.Session) *cobra.Command {
 				sess.Exit(cli.StatusInternalError)
 				return nil
 			}
+			// a command created with the same name starts a new history
+			if err := store.Selected().DeleteRevisions(cmd.User, cmd.Name); err != nil {
+				log.Info(sess, cmd, err)
+				cli.StatusErr(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
 			cli.StatusDone(sess)
 			return nil
 		},
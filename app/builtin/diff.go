@@ -0,0 +1,76 @@
+package builtin
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/lib/cli"
+)
+
+var diffCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff <cmd> <revA> <revB>",
+		Short: "Compare two command revisions",
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) < 3 {
+				c.Usage()
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
+			cmd, err := LookupCmd(sess.User(), args[0])
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusError)
+				return nil
+			}
+			if !cmd.IsAdmin(sess.User()) {
+				fmt.Fprintln(sess.Stderr(), "Not allowed")
+				sess.Exit(cli.StatusNoPerm)
+				return nil
+			}
+			var revs []*core.Revision
+			for _, arg := range args[1:3] {
+				rev, err := lookupRevision(cmd, arg)
+				if err != nil {
+					fmt.Fprintln(sess.Stderr(), err.Error())
+					sess.Exit(cli.StatusError)
+					return nil
+				}
+				revs = append(revs, rev)
+			}
+			if err := writeRevisionDiff(sess, cmd.Name, revs[0], revs[1]); err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			return nil
+		},
+	}
+}
+
+// writeRevisionDiff writes a unified diff of source between revisions a and
+// b followed by the environment keys added or removed.
+func writeRevisionDiff(w io.Writer, name string, a, b *core.Revision) error {
+	err := difflib.WriteUnifiedDiff(w, difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a.Source),
+		B:        difflib.SplitLines(b.Source),
+		FromFile: fmt.Sprintf("%s@%d", name, a.Revision),
+		ToFile:   fmt.Sprintf("%s@%d", name, b.Revision),
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range store.SetRemove(a.EnvKeys, b.EnvKeys...) {
+		fmt.Fprintf(w, "-env %s\n", key)
+	}
+	for _, key := range store.SetRemove(b.EnvKeys, a.EnvKeys...) {
+		fmt.Fprintf(w, "+env %s\n", key)
+	}
+	return nil
+}
@@ -7,12 +7,12 @@ import (
 	"github.com/gliderlabs/comlab/pkg/log"
 	"github.com/spf13/cobra"
 
-	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/app/core"
 	"github.com/gliderlabs/cmd/lib/cli"
 )
 
 var editCmd = func(sess cli.Session) *cobra.Command {
-	return &cobra.Command{
+	retval := &cobra.Command{
 		Use:   "edit <name> [-]",
 		Short: "Edit a command",
 		Long: `Edit source for an existing command.
@@ -21,7 +21,7 @@ var editCmd = func(sess cli.Session) *cobra.Command {
 		Example: `  # Edit command with name "cmd" reading source from stdin
 	  echo -e '#!cmd alpine\n echo "hello world"' | ssh cmd.io :edit cmd -`,
 		RunE: func(c *cobra.Command, args []string) error {
-
+			args = c.Flags().Args()
 			if len(args) < 2 {
 				fmt.Fprintln(sess.Stderr(), "Unsupported: use - to read from stdin")
 				c.Usage()
@@ -42,13 +42,14 @@ var editCmd = func(sess cli.Session) *cobra.Command {
 				return nil
 			}
 			cmd.Source = string(source)
-			if err := cmd.Build(); err != nil {
+			quiet, _ := c.Flags().GetBool("quiet")
+			if err := cmd.Build(buildOutput(sess, quiet)); err != nil {
 				log.Info(err)
 				cli.StatusErr(sess.Stderr(), err.Error())
-				sess.Exit(cli.StatusInternalError)
+				sess.Exit(cli.StatusCreateError)
 				return nil
 			}
-			if err := store.Selected().Put(cmd.User, cmd.Name, cmd); err != nil {
+			if err := putCmd(cmd, core.RevisionEdit, sess.User()); err != nil {
 				log.Info(sess, cmd, err)
 				cli.StatusErr(sess.Stderr(), err.Error())
 				sess.Exit(cli.StatusInternalError)
@@ -58,4 +59,6 @@ var editCmd = func(sess cli.Session) *cobra.Command {
 			return nil
 		},
 	}
+	cli.AddFlag(retval, cli.Flag{Name: "quiet", Value: false, Usage: "suppress build output", Shorthand: "q", Kind: "bool"})
+	return retval
 }
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/app/core"
 	"github.com/gliderlabs/cmd/lib/cli"
 	"github.com/gliderlabs/cmd/lib/crypto"
 	"github.com/spf13/cobra"
@@ -99,7 +99,7 @@ var envSetCmd = func(sess cli.Session) *cobra.Command {
 			}
 			cli.Status(sess, fmt.Sprintf(
 				"Setting %s on %s", strings.Join(keys, ", "), cli.Bright(cmd.Name)))
-			if err := store.Selected().Put(cmd.User, cmd.Name, cmd); err != nil {
+			if err := putCmd(cmd, core.RevisionEnv, sess.User()); err != nil {
 				cli.StatusErr(sess.Stderr(), err.Error())
 				sess.Exit(cli.StatusInternalError)
 				return nil
@@ -139,7 +139,7 @@ var envUnsetCmd = func(sess cli.Session) *cobra.Command {
 			}
 			cli.Status(sess, fmt.Sprintf(
 				"Unsetting %s on %s", strings.Join(keys, ", "), cli.Bright(cmd.Name)))
-			if err := store.Selected().Put(cmd.User, cmd.Name, cmd); err != nil {
+			if err := putCmd(cmd, core.RevisionEnv, sess.User()); err != nil {
 				cli.StatusErr(sess.Stderr(), err.Error())
 				sess.Exit(cli.StatusInternalError)
 				return nil
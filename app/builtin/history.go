@@ -0,0 +1,59 @@
+package builtin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/lib/cli"
+)
+
+var historyCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "history <cmd>",
+		Short: "List command revisions",
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				c.Usage()
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
+			cmd, err := LookupCmd(sess.User(), args[0])
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusError)
+				return nil
+			}
+			if !cmd.IsAdmin(sess.User()) {
+				fmt.Fprintln(sess.Stderr(), "Not allowed")
+				sess.Exit(cli.StatusNoPerm)
+				return nil
+			}
+			revs, err := store.Selected().ListRevisions(cmd.User, cmd.Name)
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			if len(revs) == 0 {
+				fmt.Fprintln(sess, "No revisions recorded for this command.")
+				return nil
+			}
+			cli.Header(sess, "History of "+cmd.Name)
+			w := cli.NewTable(sess)
+			fmt.Fprintln(w, "  REV\tCREATED\tACTION\tAUTHOR\tENV")
+			for _, rev := range revs {
+				fmt.Fprintf(w, "  %d\t%s\t%s\t%s\t%s\n",
+					rev.Revision,
+					rev.Created.Format("2006-01-02 15:04:05"),
+					rev.Action,
+					rev.Author,
+					strings.Join(rev.EnvKeys, ","))
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
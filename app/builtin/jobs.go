@@ -0,0 +1,154 @@
+package builtin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/lib/cli"
+	"github.com/gliderlabs/cmd/lib/runtime"
+)
+
+var jobsCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "jobs",
+		Short: "List detached jobs",
+		RunE: func(c *cobra.Command, args []string) error {
+			rt, err := core.JobRuntime(sess.Context())
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			jobs, err := core.ListJobs(sess.Context(), rt, sess.User())
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			if len(jobs) == 0 {
+				fmt.Fprintln(sess, "No jobs.")
+				return nil
+			}
+			cli.Header(sess, "Jobs")
+			w := cli.NewTable(sess)
+			fmt.Fprintln(w, "  ID\tCMD\tSTATE\tCREATED")
+			for _, job := range jobs {
+				state := job.State
+				if !job.Running() {
+					state = fmt.Sprintf("%s (%d)", state, job.ExitCode)
+				}
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n",
+					job.ID,
+					strings.TrimPrefix(job.Cmd, sess.User()+"/"),
+					state,
+					job.Created.Format("2006-01-02 15:04:05"))
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+var logsCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "logs <id>",
+		Short: "Show output of a job",
+		RunE: func(c *cobra.Command, args []string) error {
+			rt, job := lookupJob(sess, c, args)
+			if job == nil {
+				return nil
+			}
+			err := core.JobLogs(sess.Context(), rt, job.ID, false, sess, sess.Stderr())
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+			}
+			return nil
+		},
+	}
+}
+
+var attachCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "attach <id>",
+		Short: "Follow output of a job until it exits",
+		RunE: func(c *cobra.Command, args []string) error {
+			rt, job := lookupJob(sess, c, args)
+			if job == nil {
+				return nil
+			}
+			err := core.JobLogs(sess.Context(), rt, job.ID, true, sess, sess.Stderr())
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			// exit with the status of the job once it has finished
+			job, err = core.GetJob(sess.Context(), rt, sess.User(), job.ID)
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			sess.Exit(job.ExitCode)
+			return nil
+		},
+	}
+}
+
+var killCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "kill <id>",
+		Short: "Stop a running job",
+		RunE: func(c *cobra.Command, args []string) error {
+			rt, job := lookupJob(sess, c, args)
+			if job == nil {
+				return nil
+			}
+			if !job.Running() {
+				fmt.Fprintln(sess.Stderr(), "Job is not running:", job.ID)
+				sess.Exit(cli.StatusError)
+				return nil
+			}
+			cli.Status(sess, fmt.Sprintf("Killing job %s", cli.Bright(job.ID)))
+			if err := core.KillJob(sess.Context(), rt, job.ID); err != nil {
+				cli.StatusErr(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			cli.StatusDone(sess)
+			return nil
+		},
+	}
+}
+
+// lookupJob given as the first argument for the session user. On failure
+// the session exit status is set and a nil job returned.
+func lookupJob(sess cli.Session, c *cobra.Command, args []string) (runtime.Runtime, *core.Job) {
+	if len(args) < 1 {
+		c.Usage()
+		sess.Exit(cli.StatusUsageError)
+		return nil, nil
+	}
+	rt, err := core.JobRuntime(sess.Context())
+	if err != nil {
+		fmt.Fprintln(sess.Stderr(), err.Error())
+		sess.Exit(cli.StatusInternalError)
+		return nil, nil
+	}
+	job, err := core.GetJob(sess.Context(), rt, sess.User(), args[0])
+	if err == core.ErrJobNotFound {
+		fmt.Fprintln(sess.Stderr(), "Job not found:", args[0])
+		sess.Exit(cli.StatusError)
+		return nil, nil
+	}
+	if err != nil {
+		fmt.Fprintln(sess.Stderr(), err.Error())
+		sess.Exit(cli.StatusInternalError)
+		return nil, nil
+	}
+	return rt, job
+}
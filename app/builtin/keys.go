@@ -0,0 +1,156 @@
+package builtin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gliderlabs/comlab/pkg/log"
+	"github.com/spf13/cobra"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/lib/cli"
+)
+
+var keysCmd = func(sess cli.Session) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage SSH keys",
+		RunE: func(c *cobra.Command, args []string) error {
+			c.Help()
+			return nil
+		},
+	}
+	cli.AddCommand(cmd, keysListCmd, sess)
+	cli.AddCommand(cmd, keysAddCmd, sess)
+	cli.AddCommand(cmd, keysRemoveCmd, sess)
+	return cmd
+}
+
+// keysAllowed checks the session is not using a token, since keys belong to
+// users.
+func keysAllowed(sess cli.Session) bool {
+	if core.ContextToken(sess.Context()) != nil {
+		fmt.Fprintln(sess.Stderr(), "Not allowed with a token")
+		sess.Exit(cli.StatusNoPerm)
+		return false
+	}
+	return true
+}
+
+var keysListCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "ls",
+		Short: "List SSH keys",
+		RunE: func(c *cobra.Command, args []string) error {
+			if !keysAllowed(sess) {
+				return nil
+			}
+			keys, err := store.Selected().ListKeys(sess.User())
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			cli.Header(sess, "Keys")
+			w := cli.NewTable(sess)
+			fmt.Fprintln(w, "  FINGERPRINT\tCOMMENT\tADDED")
+			for _, key := range keys {
+				fmt.Fprintf(w, "  %s\t%s\t%s\n",
+					key.Fingerprint,
+					key.Comment,
+					key.Created.Format("2006-01-02 15:04:05"))
+			}
+			w.Flush()
+			fmt.Fprintln(sess, "")
+			return nil
+		},
+	}
+}
+
+var keysAddCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "add [<key>]",
+		Short: "Add an SSH key",
+		Long: `Add an SSH key.
+
+	The key is in authorized_keys format, given as arguments or read from stdin.`,
+		Example: `  ssh cmd.io :keys add < ~/.ssh/id_ed25519.pub`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if !keysAllowed(sess) {
+				return nil
+			}
+			args = c.Flags().Args()
+			line := strings.Join(args, " ")
+			if line == "" {
+				b, err := ioutil.ReadAll(sess)
+				if err != nil {
+					fmt.Fprintln(sess.Stderr(), err.Error())
+					sess.Exit(cli.StatusDataError)
+					return nil
+				}
+				line = string(b)
+			}
+			key, err := core.NewKey(sess.User(), []byte(line))
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), "Invalid key:", err.Error())
+				sess.Exit(cli.StatusDataError)
+				return nil
+			}
+			cli.Status(sess, fmt.Sprintf("Adding key %s", cli.Bright(key.Fingerprint)))
+			if err := store.Selected().PutKey(key); err != nil {
+				log.Info(sess, key, err)
+				cli.StatusErr(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			cli.StatusDone(sess)
+			return nil
+		},
+	}
+}
+
+var keysRemoveCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rm <fingerprint>",
+		Short: "Remove an SSH key",
+		RunE: func(c *cobra.Command, args []string) error {
+			if !keysAllowed(sess) {
+				return nil
+			}
+			args = c.Flags().Args()
+			if len(args) < 1 {
+				fmt.Fprintln(sess.Stderr(), "Fingerprint is required")
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
+			keys, err := store.Selected().ListKeys(sess.User())
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			var key *core.Key
+			for _, k := range keys {
+				if k.Fingerprint == args[0] {
+					key = k
+				}
+			}
+			if key == nil {
+				fmt.Fprintln(sess.Stderr(), "Key not found")
+				sess.Exit(cli.StatusError)
+				return nil
+			}
+			cli.Status(sess, fmt.Sprintf("Removing key %s", cli.Bright(key.Fingerprint)))
+			if err := store.Selected().DeleteKey(key.User, key.Fingerprint); err != nil {
+				log.Info(sess, key, err)
+				cli.StatusErr(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			cli.StatusDone(sess)
+			return nil
+		},
+	}
+}
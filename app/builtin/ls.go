@@ -30,6 +30,6 @@ var listCmd = func(sess cli.Session) *cobra.Command {
 			return nil
 		},
 	}
-	cli.AddFlag(cmd, cli.Flag{"json", false, "output in JSON", "j", "bool"})
+	cli.AddFlag(cmd, cli.Flag{Name: "json", Value: false, Usage: "output in JSON", Shorthand: "j", Kind: "bool"})
 	return cmd
 }
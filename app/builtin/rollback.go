@@ -0,0 +1,83 @@
+package builtin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gliderlabs/comlab/pkg/log"
+	"github.com/spf13/cobra"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/lib/cli"
+)
+
+var rollbackCmd = func(sess cli.Session) *cobra.Command {
+	retval := &cobra.Command{
+		Use:   "rollback <cmd> <rev>",
+		Short: "Restore command source from a revision",
+		Long: `Restore command source from a revision.
+
+	Environment values are not recorded in revisions and are left unchanged.
+	The rollback is itself recorded as a new revision.`,
+		RunE: func(c *cobra.Command, args []string) error {
+			args = c.Flags().Args()
+			if len(args) < 2 {
+				c.Usage()
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
+			cmd, err := LookupCmd(sess.User(), args[0])
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusError)
+				return nil
+			}
+			if !cmd.IsAdmin(sess.User()) {
+				fmt.Fprintln(sess.Stderr(), "Not allowed")
+				sess.Exit(cli.StatusNoPerm)
+				return nil
+			}
+			rev, err := lookupRevision(cmd, args[1])
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusError)
+				return nil
+			}
+			cli.Status(sess, fmt.Sprintf(
+				"Rolling back %s to revision %d", cli.Bright(cmd.Name), rev.Revision))
+			cmd.Source = rev.Source
+			quiet, _ := c.Flags().GetBool("quiet")
+			if err := cmd.Build(buildOutput(sess, quiet)); err != nil {
+				log.Info(err)
+				cli.StatusErr(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusCreateError)
+				return nil
+			}
+			if err := putCmd(cmd, core.RevisionRollback, sess.User()); err != nil {
+				log.Info(sess, cmd, err)
+				cli.StatusErr(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			cli.StatusDone(sess)
+			return nil
+		},
+	}
+	cli.AddFlag(retval, cli.Flag{Name: "quiet", Value: false, Usage: "suppress build output", Shorthand: "q", Kind: "bool"})
+	return retval
+}
+
+// lookupRevision of cmd by revision number given as an argument.
+func lookupRevision(cmd *core.Command, arg string) (*core.Revision, error) {
+	revision, err := strconv.Atoi(strings.TrimPrefix(arg, "r"))
+	if err != nil || revision < 1 {
+		return nil, fmt.Errorf("Invalid revision: %s", arg)
+	}
+	rev, err := store.Selected().GetRevision(cmd.User, cmd.Name, revision)
+	if err != nil || rev == nil {
+		return nil, fmt.Errorf("Revision not found: %d", revision)
+	}
+	return rev, nil
+}
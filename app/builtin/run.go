@@ -0,0 +1,84 @@
+package builtin
+
+import (
+	"fmt"
+
+	"github.com/gliderlabs/comlab/pkg/log"
+	"github.com/gliderlabs/ssh"
+	"github.com/spf13/cobra"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/lib/cli"
+)
+
+var runCmd = func(sess cli.Session) *cobra.Command {
+	retval := &cobra.Command{
+		Use:   "run <cmd> [args...]",
+		Short: "Run a command, optionally detached as a job",
+		Long: `Run a command, optionally detached as a job.
+
+	When detached the job ID is printed and the command keeps running after
+	the session ends. Use :jobs, :logs, :attach and :kill to manage jobs.`,
+		Example: `  # Run command with name "cmd" in the background
+	  ssh cmd.io :run --detach cmd arg1 arg2`,
+		RunE: func(c *cobra.Command, args []string) error {
+			args = c.Flags().Args()
+			if len(args) < 1 {
+				c.Usage()
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
+			sshSess, ok := sess.(*session)
+			if !ok {
+				fmt.Fprintln(sess.Stderr(), "Unsupported session")
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			cmd, err := LookupCmd(sess.User(), args[0])
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusError)
+				return nil
+			}
+			if !cmd.HasAccess(sess.User()) {
+				fmt.Fprintln(sess.Stderr(), "Not allowed")
+				sess.Exit(cli.StatusNoPerm)
+				return nil
+			}
+			rs := &runSession{sshSess.Session, args}
+			if detach, _ := c.Flags().GetBool("detach"); !detach {
+				rec := core.NewRunRecorder(cmd, rs, core.RunViaSSH, args[1:])
+				status := cmd.Run(rec, args[1:])
+				if err := store.Selected().PutRun(rec.Finish(status)); err != nil {
+					log.Info(sess, cmd, err)
+				}
+				sess.Exit(status)
+				return nil
+			}
+			job, err := cmd.RunDetached(rs, args[1:])
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusUnavailable)
+				return nil
+			}
+			fmt.Fprintln(sess, job.ID)
+			return nil
+		},
+	}
+	// stop parsing flags at the command name so they are passed to it
+	retval.Flags().SetInterspersed(false)
+	cli.AddFlag(retval, cli.Flag{Name: "detach", Value: false, Usage: "run in the background as a job", Shorthand: "d", Kind: "bool"})
+	return retval
+}
+
+// runSession presents a session running a command through a builtin as if
+// the command was run directly.
+type runSession struct {
+	ssh.Session
+	cmd []string
+}
+
+func (s *runSession) Command() []string {
+	return append([]string(nil), s.cmd...)
+}
@@ -0,0 +1,97 @@
+package builtin
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/lib/cli"
+)
+
+var runsCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "runs <cmd> [id]",
+		Short: "Show run history of a command",
+		Long: `Show run history of a command.
+
+	Without an ID the most recent runs are listed. With an ID the run is shown
+	with the end of its captured output.`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				c.Usage()
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
+			cmd, err := LookupCmd(sess.User(), args[0])
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusError)
+				return nil
+			}
+			if !cmd.IsAdmin(sess.User()) {
+				fmt.Fprintln(sess.Stderr(), "Not allowed")
+				sess.Exit(cli.StatusNoPerm)
+				return nil
+			}
+			if len(args) > 1 {
+				return showRun(sess, cmd.User, cmd.Name, args[1])
+			}
+			runs, err := store.Selected().ListRuns(cmd.User, cmd.Name, 20)
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			if len(runs) == 0 {
+				fmt.Fprintln(sess, "No runs recorded for this command.")
+				return nil
+			}
+			cli.Header(sess, "Runs of "+cmd.Name)
+			w := cli.NewTable(sess)
+			fmt.Fprintln(w, "  ID\tSTARTED\tVIA\tCALLER\tSTATUS\tDURATION\tIN\tOUT")
+			for _, run := range runs {
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%d\t%s\t%d\t%d\n",
+					run.ID,
+					run.Started.Format("2006-01-02 15:04:05"),
+					run.Via,
+					run.Caller,
+					run.Status,
+					run.Duration().Round(time.Millisecond),
+					run.BytesIn,
+					run.BytesOut)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func showRun(sess cli.Session, owner, name, id string) error {
+	run, err := store.Selected().GetRun(owner, name, id)
+	if err != nil || run == nil {
+		fmt.Fprintln(sess.Stderr(), "Run not found:", id)
+		sess.Exit(cli.StatusError)
+		return nil
+	}
+	cli.PrintFields(sess, map[string]interface{}{
+		cli.Bright("Caller"):   run.Caller,
+		cli.Bright("Via"):      run.Via,
+		cli.Bright("Args"):     strings.Join(run.Args, " "),
+		cli.Bright("Started"):  run.Started.Format(time.RFC3339),
+		cli.Bright("Duration"): run.Duration().Round(time.Millisecond),
+		cli.Bright("Status"):   run.Status,
+		cli.Bright("Bytes"):    fmt.Sprintf("%d in, %d out", run.BytesIn, run.BytesOut),
+	}, true)
+	if run.Stdout != "" {
+		cli.Header(sess, "Stdout")
+		fmt.Fprint(sess, run.Stdout)
+	}
+	if run.Stderr != "" {
+		cli.Header(sess, "Stderr")
+		fmt.Fprint(sess, run.Stderr)
+	}
+	return nil
+}
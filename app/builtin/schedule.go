@@ -0,0 +1,200 @@
+package builtin
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gliderlabs/comlab/pkg/log"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/lib/cli"
+)
+
+var scheduleCmd = func(sess cli.Session) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schedule <cmd> <spec> [args...]",
+		Short: "Run a command on a schedule",
+		Long: `Run a command on a schedule.
+
+	The spec is a standard 5 field cron spec in UTC, or a descriptor such as
+	@hourly, @daily or "@every 2h". Quote the spec so it is a single argument.`,
+		Example: `  # Run command with name "cmd" every 15 minutes
+	  ssh cmd.io :schedule cmd "*/15 * * * *" arg1 arg2`,
+		RunE: func(c *cobra.Command, args []string) error {
+			args = c.Flags().Args()
+			if len(args) < 2 {
+				c.Usage()
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
+			cmd, err := LookupCmd(sess.User(), args[0])
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusError)
+				return nil
+			}
+			if !cmd.HasAccess(sess.User()) {
+				fmt.Fprintln(sess.Stderr(), "Not allowed")
+				sess.Exit(cli.StatusNoPerm)
+				return nil
+			}
+			// scheduled runs have no address to check a token's CIDRs against
+			if token := core.ContextToken(sess.Context()); token != nil &&
+				(len(token.CIDRs) > 0 || !token.AllowsCmd(cmd.User, cmd.Name)) {
+				fmt.Fprintln(sess.Stderr(), "Not allowed: token is scoped")
+				sess.Exit(cli.StatusNoPerm)
+				return nil
+			}
+			now := time.Now().UTC()
+			schedule := &core.Schedule{
+				ID:      uuid.NewV4().String(),
+				User:    sess.User(),
+				Owner:   cmd.User,
+				Cmd:     cmd.Name,
+				Spec:    args[1],
+				Args:    args[2:],
+				Created: now,
+			}
+			if err := schedule.Next(now); err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
+			cli.Status(sess, fmt.Sprintf(
+				"Scheduling %s as %s", cli.Bright(cmd.Name), cli.Bright(schedule.ID)))
+			if err := store.Selected().PutSchedule(schedule); err != nil {
+				log.Info(sess, schedule, err)
+				cli.StatusErr(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			cli.StatusDone(sess)
+			return nil
+		},
+	}
+	// stop parsing flags at the command name so they are passed to it
+	cmd.Flags().SetInterspersed(false)
+	cli.AddCommand(cmd, scheduleListCmd, sess)
+	cli.AddCommand(cmd, scheduleShowCmd, sess)
+	cli.AddCommand(cmd, scheduleRemoveCmd, sess)
+	return cmd
+}
+
+var scheduleListCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "ls",
+		Short: "List schedules with their last result",
+		RunE: func(c *cobra.Command, args []string) error {
+			schedules, err := store.Selected().ListSchedules(sess.User())
+			if err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			if len(schedules) == 0 {
+				fmt.Fprintln(sess, "No schedules.")
+				return nil
+			}
+			cli.Header(sess, "Schedules")
+			w := cli.NewTable(sess)
+			fmt.Fprintln(w, "  ID\tCMD\tSPEC\tNEXT RUN\tLAST RUN\tSTATUS")
+			for _, schedule := range schedules {
+				lastRun, status := "-", "-"
+				if !schedule.LastRun.IsZero() {
+					lastRun = schedule.LastRun.Format("2006-01-02 15:04")
+					status = fmt.Sprint(schedule.LastStatus)
+				}
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n",
+					schedule.ID,
+					strings.Join(append([]string{scheduleCmdName(sess, schedule)}, schedule.Args...), " "),
+					schedule.Spec,
+					schedule.NextRun.Format("2006-01-02 15:04"),
+					lastRun,
+					status)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+var scheduleShowCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show a schedule and output of its last run",
+		RunE: func(c *cobra.Command, args []string) error {
+			schedule := lookupSchedule(sess, c, args)
+			if schedule == nil {
+				return nil
+			}
+			fields := map[string]interface{}{
+				cli.Bright("Cmd"):      scheduleCmdName(sess, schedule),
+				cli.Bright("Args"):     strings.Join(schedule.Args, " "),
+				cli.Bright("Spec"):     schedule.Spec,
+				cli.Bright("Next run"): schedule.NextRun.Format(time.RFC3339),
+			}
+			if !schedule.LastRun.IsZero() {
+				fields[cli.Bright("Last run")] = schedule.LastRun.Format(time.RFC3339)
+				fields[cli.Bright("Status")] = schedule.LastStatus
+			}
+			cli.PrintFields(sess, fields, true)
+			if schedule.LastOutput != "" {
+				cli.Header(sess, "Output")
+				fmt.Fprint(sess, schedule.LastOutput)
+			}
+			return nil
+		},
+	}
+}
+
+var scheduleRemoveCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:     "rm <id>",
+		Aliases: []string{"remove"},
+		Short:   "Remove a schedule",
+		RunE: func(c *cobra.Command, args []string) error {
+			schedule := lookupSchedule(sess, c, args)
+			if schedule == nil {
+				return nil
+			}
+			cli.Status(sess, fmt.Sprintf("Removing schedule %s", cli.Bright(schedule.ID)))
+			if err := store.Selected().DeleteSchedule(schedule.ID); err != nil {
+				cli.StatusErr(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			cli.StatusDone(sess)
+			return nil
+		},
+	}
+}
+
+// lookupSchedule given as the first argument for the session user. On
+// failure the session exit status is set and nil returned.
+func lookupSchedule(sess cli.Session, c *cobra.Command, args []string) *core.Schedule {
+	if len(args) < 1 {
+		c.Usage()
+		sess.Exit(cli.StatusUsageError)
+		return nil
+	}
+	schedule, err := store.Selected().GetSchedule(args[0])
+	if err != nil || schedule == nil || schedule.User != sess.User() {
+		fmt.Fprintln(sess.Stderr(), "Schedule not found:", args[0])
+		sess.Exit(cli.StatusError)
+		return nil
+	}
+	return schedule
+}
+
+// scheduleCmdName returns the name of the scheduled command as the session
+// user would run it.
+func scheduleCmdName(sess cli.Session, schedule *core.Schedule) string {
+	if schedule.Owner == sess.User() {
+		return schedule.Cmd
+	}
+	return schedule.Owner + "/" + schedule.Cmd
+}
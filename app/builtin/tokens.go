@@ -2,6 +2,8 @@ package builtin
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/gliderlabs/comlab/pkg/log"
 	uuid "github.com/satori/go.uuid"
@@ -24,37 +26,69 @@ var tokensCmd = func(sess cli.Session) *cobra.Command {
 	cli.AddCommand(cmd, tokensListCmd, sess)
 	cli.AddCommand(cmd, tokensNew, sess)
 	cli.AddCommand(cmd, tokensDelete, sess)
+	cli.AddCommand(cmd, tokensSecret, sess)
 	return cmd
 }
 
 var tokensListCmd = func(sess cli.Session) *cobra.Command {
-	return &cobra.Command{
+	retval := &cobra.Command{
 		Use:   "ls",
 		Short: "List tokens",
 		RunE: func(c *cobra.Command, args []string) error {
+			staleDays := c.Flags().Lookup("stale").Value.String()
+			days, err := strconv.Atoi(staleDays)
+			if err != nil || days < 1 {
+				fmt.Fprintln(sess.Stderr(), "Invalid number of days:", staleDays)
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
 			cli.Header(sess, "Tokens")
 			tokens, err := store.Selected().ListTokens(sess.User())
 			if err != nil {
-				fmt.Fprintf(sess.Stderr(), err.Error())
+				fmt.Fprintln(sess.Stderr(), err.Error())
 				sess.Exit(cli.StatusInternalError)
 				return nil
 			}
+			w := cli.NewTable(sess)
+			fmt.Fprintln(w, "  KEY\tDESCRIPTION\tUSES\tLAST USED\tLAST IP\t")
 			for _, token := range tokens {
-				fmt.Fprintf(sess, "  %-10s  %s %s\n", token.Key, token.Description, token.LastUsedOn)
+				lastUsed := "never"
+				if !token.LastUsedOn.IsZero() {
+					lastUsed = token.LastUsedOn.Format("2006-01-02 15:04:05")
+				}
+				var stale string
+				if token.UnusedFor(time.Duration(days) * 24 * time.Hour) {
+					stale = "stale"
+				}
+				fmt.Fprintf(w, "  %s\t%s\t%d\t%s\t%s\t%s\n",
+					token.Key,
+					token.Description,
+					token.Uses,
+					lastUsed,
+					token.LastUsedIP,
+					stale)
+				if scope := token.Scope(); scope != "" {
+					fmt.Fprintf(w, "  \t%s\t\t\t\t\n", scope)
+				}
 			}
+			w.Flush()
 			fmt.Fprintln(sess, "")
 			return nil
 		},
 	}
+	cli.AddFlag(retval, cli.Flag{Name: "stale", Value: "30", Usage: "flag tokens unused for this many days", Shorthand: "s", Kind: "string"})
+	return retval
 }
 
 var tokensNew = func(sess cli.Session) *cobra.Command {
-	return &cobra.Command{
+	retval := &cobra.Command{
 		Use:   "new <description>",
 		Short: "Create a token",
 		RunE: func(c *cobra.Command, args []string) error {
+			flags := c.Flags()
+			args = flags.Args()
 			var desc string
-			if len(args) > 1 {
+			if len(args) > 0 {
 				desc = args[0]
 			}
 
@@ -62,11 +96,29 @@ var tokensNew = func(sess cli.Session) *cobra.Command {
 				Key:         uuid.NewV4().String(),
 				User:        sess.User(),
 				Description: desc,
+				Created:     time.Now().UTC(),
+				Cmds:        splitList(flags.Lookup("cmds").Value.String()),
+				CIDRs:       splitList(flags.Lookup("cidrs").Value.String()),
+			}
+			token.NoStdin, _ = flags.GetBool("no-stdin")
+			if ttl := flags.Lookup("ttl").Value.String(); ttl != "" {
+				d, err := time.ParseDuration(ttl)
+				if err != nil || d <= 0 {
+					fmt.Fprintln(sess.Stderr(), "Invalid TTL:", ttl)
+					sess.Exit(cli.StatusUsageError)
+					return nil
+				}
+				token.ExpiresOn = time.Now().UTC().Add(d)
+			}
+			if err := token.Validate(); err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusUsageError)
+				return nil
 			}
 
 			if err := store.Selected().PutToken(token); err != nil {
 				log.Info(sess, token, err)
-				fmt.Fprintf(sess.Stderr(), err.Error())
+				fmt.Fprintln(sess.Stderr(), err.Error())
 				sess.Exit(cli.StatusInternalError)
 				return nil
 			}
@@ -74,6 +126,11 @@ var tokensNew = func(sess cli.Session) *cobra.Command {
 			return nil
 		},
 	}
+	cli.AddFlag(retval, cli.Flag{Name: "ttl", Value: "", Usage: "expire after duration (ex. 24h)", Shorthand: "t", Kind: "string"})
+	cli.AddFlag(retval, cli.Flag{Name: "cmds", Value: "", Usage: "comma separated commands allowed to run", Shorthand: "c", Kind: "string"})
+	cli.AddFlag(retval, cli.Flag{Name: "cidrs", Value: "", Usage: "comma separated addresses allowed to use token", Kind: "string"})
+	cli.AddFlag(retval, cli.Flag{Name: "no-stdin", Value: false, Usage: "do not send stdin to commands", Kind: "bool"})
+	return retval
 }
 
 var tokensDelete = func(sess cli.Session) *cobra.Command {
@@ -95,7 +152,7 @@ var tokensDelete = func(sess cli.Session) *cobra.Command {
 
 			if err := store.Selected().DeleteToken(token.Key); err != nil {
 				log.Info(sess, token, err)
-				fmt.Fprintf(sess.Stderr(), err.Error())
+				fmt.Fprintln(sess.Stderr(), err.Error())
 				sess.Exit(cli.StatusInternalError)
 				return nil
 			}
@@ -104,3 +161,29 @@ var tokensDelete = func(sess cli.Session) *cobra.Command {
 		},
 	}
 }
+
+var tokensSecret = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "secret <key>",
+		Short: "Show the secret for signing requests with a token",
+		RunE: func(c *cobra.Command, args []string) error {
+			args = c.Flags().Args()
+			if len(args) < 1 {
+				fmt.Fprintf(sess.Stderr(), "Key name is required")
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
+			token, _ := store.Selected().GetToken(args[0])
+			if token == nil || token.User != sess.User() {
+				fmt.Fprintf(sess.Stderr(), "Token not found")
+				sess.Exit(cli.StatusError)
+				return nil
+			}
+			cli.PrintFields(sess, map[string]interface{}{
+				"Credential": token.User + "/" + token.ID(),
+				"Secret":     token.SigningSecret(),
+			}, true)
+			return nil
+		},
+	}
+}
@@ -2,10 +2,12 @@ package builtin
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/gliderlabs/cmd/app/core"
 	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/lib/cli"
 )
 
 func LookupCmd(owner, name string) (*core.Command, error) {
@@ -24,3 +26,60 @@ func LookupCmd(owner, name string) (*core.Command, error) {
 	}
 	return cmd, nil
 }
+
+// putCmd records a revision of the change made by author and stores cmd.
+// The revision is recorded first so no stored change is missing from the
+// history.
+func putCmd(cmd *core.Command, action, author string) error {
+	if err := store.Selected().PutRevision(cmd.NewRevision(action, author)); err != nil {
+		return err
+	}
+	return store.Selected().Put(cmd.User, cmd.Name, cmd)
+}
+
+// buildOutput returns a writer streaming image build output to the session
+// stderr, or nil to discard build output when quiet.
+func buildOutput(sess cli.Session, quiet bool) io.Writer {
+	if quiet {
+		return nil
+	}
+	return &statusBreakWriter{w: sess.Stderr()}
+}
+
+// statusBreakWriter ends a pending status line before the first write.
+type statusBreakWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (sw *statusBreakWriter) Write(p []byte) (int, error) {
+	if !sw.written {
+		sw.written = true
+		if _, err := io.WriteString(sw.w, "\n"); err != nil {
+			return 0, err
+		}
+	}
+	return sw.w.Write(p)
+}
+
+// splitList splits a comma separated list ignoring empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// checkSubjects returns an error for any group subject without a namespace
+// and name, such as @team:ops.
+func checkSubjects(subjects []string) error {
+	for _, subject := range subjects {
+		if core.IsGroup(subject) && !core.ValidGroup(subject) {
+			return fmt.Errorf("Invalid group: %s (expected @<namespace>:<name>)", subject)
+		}
+	}
+	return nil
+}
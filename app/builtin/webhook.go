@@ -0,0 +1,165 @@
+package builtin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/gliderlabs/comlab/pkg/log"
+	"github.com/spf13/cobra"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/lib/cli"
+	"github.com/gliderlabs/cmd/lib/crypto"
+	"github.com/gliderlabs/cmd/lib/release"
+)
+
+// lookupWebhookCmd looks up a command the session user administers.
+func lookupWebhookCmd(sess cli.Session, name string) *core.Command {
+	cmd, err := LookupCmd(sess.User(), name)
+	if err != nil {
+		fmt.Fprintln(sess.Stderr(), err.Error())
+		sess.Exit(cli.StatusError)
+		return nil
+	}
+	if !cmd.IsAdmin(sess.User()) {
+		fmt.Fprintln(sess.Stderr(), "Not allowed")
+		sess.Exit(cli.StatusNoPerm)
+		return nil
+	}
+	return cmd
+}
+
+var webhookShowFn = func(sess cli.Session, c *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		c.Usage()
+		sess.Exit(cli.StatusUsageError)
+		return nil
+	}
+	cmd := lookupWebhookCmd(sess, args[0])
+	if cmd == nil {
+		return nil
+	}
+	if cmd.Webhook == nil {
+		fmt.Fprintln(sess, "No webhook set for this command.")
+		return nil
+	}
+	cli.PrintFields(sess, map[string]interface{}{
+		"Provider": cmd.Webhook.Provider,
+		"URL":      fmt.Sprintf("https://%s/hook/%s/%s", release.Hostname(), cmd.User, cmd.Name),
+		"Secret":   crypto.Decrypt(cmd.Webhook.Secret),
+	}, true)
+	return nil
+}
+
+var webhookCmd = func(sess cli.Session) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "webhook <cmd>",
+		Short: "Manage command webhook",
+		Long: `Without a subcommand, webhook will run "show" by default.
+
+	Verified webhook deliveries run the command with the payload as stdin.`,
+		RunE: func(c *cobra.Command, args []string) error {
+			return webhookShowFn(sess, c, args)
+		},
+	}
+	argCmd := cli.ArgumentCommand(cmd, sess)
+	cli.AddCommand(argCmd, webhookShowCmd, sess)
+	cli.AddCommand(argCmd, webhookSetCmd, sess)
+	cli.AddCommand(argCmd, webhookRemoveCmd, sess)
+	return cmd
+}
+
+var webhookShowCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "Show command webhook",
+		RunE: func(c *cobra.Command, args []string) error {
+			return webhookShowFn(sess, c, args)
+		},
+	}
+}
+
+var webhookSetCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <provider> [secret]",
+		Short: "Set command webhook",
+		Long: `Set command webhook.
+
+	The provider is github, stripe or generic. A secret is generated unless
+	given, except for stripe which requires the endpoint signing secret.`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) < 2 {
+				fmt.Fprintln(sess.Stderr(), "Provider is required")
+				c.Usage()
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
+			cmd := lookupWebhookCmd(sess, args[0])
+			if cmd == nil {
+				return nil
+			}
+			var secret string
+			if len(args) > 2 {
+				secret = args[2]
+			} else if args[1] != core.WebhookStripe {
+				b := make([]byte, 20)
+				if _, err := rand.Read(b); err != nil {
+					fmt.Fprintln(sess.Stderr(), err.Error())
+					sess.Exit(cli.StatusInternalError)
+					return nil
+				}
+				secret = hex.EncodeToString(b)
+			}
+			webhook := &core.Webhook{Provider: args[1]}
+			if secret != "" {
+				webhook.Secret, _ = crypto.Encrypt(secret)
+			}
+			if err := webhook.Validate(); err != nil {
+				fmt.Fprintln(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
+			cmd.Webhook = webhook
+			cli.Status(sess, fmt.Sprintf(
+				"Setting %s webhook on %s", cli.Bright(webhook.Provider), cli.Bright(cmd.Name)))
+			if err := store.Selected().Put(cmd.User, cmd.Name, cmd); err != nil {
+				log.Info(sess, cmd, err)
+				cli.StatusErr(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			cli.StatusDone(sess)
+			return webhookShowFn(sess, c, args[:1])
+		},
+	}
+}
+
+var webhookRemoveCmd = func(sess cli.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rm",
+		Short: "Remove command webhook",
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				c.Usage()
+				sess.Exit(cli.StatusUsageError)
+				return nil
+			}
+			cmd := lookupWebhookCmd(sess, args[0])
+			if cmd == nil {
+				return nil
+			}
+			cmd.Webhook = nil
+			cli.Status(sess, fmt.Sprintf("Removing webhook from %s", cli.Bright(cmd.Name)))
+			if err := store.Selected().Put(cmd.User, cmd.Name, cmd); err != nil {
+				log.Info(sess, cmd, err)
+				cli.StatusErr(sess.Stderr(), err.Error())
+				sess.Exit(cli.StatusInternalError)
+				return nil
+			}
+			cli.StatusDone(sess)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/anmitsu/go-shlex"
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/log"
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/gliderlabs/cmd/app/console"
+	"github.com/gliderlabs/cmd/app/core"
+)
+
+const (
+	forceCommandOption   = "force-command"
+	sourceAddressOption  = "source-address"
+	permitPtyExtension   = "permit-pty"
+	permitAgentExtension = "permit-agent-forwarding"
+)
+
+// userAuthorities returns the CA keys trusted to sign user certificates,
+// read from the file set by the user_ca_keys option.
+func userAuthorities() ([]ssh.PublicKey, error) {
+	path := com.GetString("user_ca_keys")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []ssh.PublicKey
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CA key in %s: %v", path, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// checkCert returns an error unless cert is a user certificate signed by one
+// of authorities, valid now for user and used from an address allowed by its
+// source-address option.
+func checkCert(cert *gossh.Certificate, user string, addr net.Addr, authorities []ssh.PublicKey, now time.Time) error {
+	if cert.CertType != gossh.UserCert {
+		return fmt.Errorf("cert has type %d", cert.CertType)
+	}
+	if len(cert.ValidPrincipals) == 0 {
+		return errors.New("cert has no principals")
+	}
+	checker := gossh.CertChecker{
+		IsUserAuthority: func(auth gossh.PublicKey) bool {
+			for _, k := range authorities {
+				if ssh.KeysEqual(auth, k) {
+					return true
+				}
+			}
+			return false
+		},
+		SupportedCriticalOptions: []string{forceCommandOption},
+		Clock:                    func() time.Time { return now },
+	}
+	if !checker.IsUserAuthority(cert.SignatureKey) {
+		return errors.New("cert signed by unrecognized authority")
+	}
+	if err := checker.CheckCert(user, cert); err != nil {
+		return err
+	}
+	if command, ok := cert.CriticalOptions[forceCommandOption]; ok {
+		if _, err := shlex.Split(command, true); err != nil {
+			return fmt.Errorf("cert force-command invalid: %v", err)
+		}
+	}
+	return checkSourceAddress(cert, addr)
+}
+
+// checkSourceAddress returns an error if cert has a source-address option
+// not including addr.
+func checkSourceAddress(cert *gossh.Certificate, addr net.Addr) error {
+	sources, ok := cert.CriticalOptions[sourceAddressOption]
+	if !ok {
+		return nil
+	}
+	ip := core.AddrIP(addr)
+	for _, source := range strings.Split(sources, ",") {
+		source = strings.TrimSpace(source)
+		if !strings.Contains(source, "/") {
+			if sourceIP := net.ParseIP(source); sourceIP != nil && sourceIP.Equal(ip) {
+				return nil
+			}
+			continue
+		}
+		_, n, err := net.ParseCIDR(source)
+		if err != nil {
+			return fmt.Errorf("cert source-address invalid: %s", source)
+		}
+		if ip != nil && n.Contains(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("cert not allowed from %s", addr)
+}
+
+// authCert authenticates the user of ctx with a certificate, trusting the CA
+// instead of looking up their keys.
+func (c *Component) authCert(ctx ssh.Context, cert *gossh.Certificate) bool {
+	user := ctx.User()
+	authorities, err := userAuthorities()
+	if err == nil {
+		err = checkCert(cert, user, ctx.RemoteAddr(), authorities, time.Now())
+	}
+	if err != nil {
+		log.Info(user, cert.KeyId, err)
+		return false
+	}
+	usr, err := console.LookupNickname(user)
+	if err != nil {
+		log.Info(user, err)
+	}
+	ctx.SetValue("user", &usr)
+	ctx.SetValue("plan", usr.Account.Plan)
+	ctx.SetValue("cert", cert)
+	return true
+}
+
+// contextCert returns the certificate a session authenticated with, if any.
+func contextCert(ctx context.Context) *gossh.Certificate {
+	cert, _ := ctx.Value("cert").(*gossh.Certificate)
+	return cert
+}
+
+// agentPermitted returns true unless the session of ctx authenticated with a
+// certificate without the permit-agent-forwarding extension.
+func agentPermitted(ctx context.Context) bool {
+	cert := contextCert(ctx)
+	if cert == nil {
+		return true
+	}
+	_, permitted := cert.Extensions[permitAgentExtension]
+	return permitted
+}
+
+// certSession returns sess restricted by the force-command option and
+// permit-pty extension of cert.
+func certSession(sess ssh.Session, cert *gossh.Certificate) ssh.Session {
+	_, permitPty := cert.Extensions[permitPtyExtension]
+	s := &restrictedSession{
+		Session: sess,
+		command: sess.Command(),
+		noPty:   !permitPty,
+	}
+	if command, ok := cert.CriticalOptions[forceCommandOption]; ok {
+		// already checked by checkCert
+		s.command, _ = shlex.Split(command, true)
+	}
+	return s
+}
+
+type restrictedSession struct {
+	ssh.Session
+	command []string
+	noPty   bool
+}
+
+func (s *restrictedSession) Command() []string {
+	return append([]string(nil), s.command...)
+}
+
+func (s *restrictedSession) Pty() (ssh.Pty, <-chan ssh.Window, bool) {
+	if s.noPty {
+		return ssh.Pty{}, nil, false
+	}
+	return s.Session.Pty()
+}
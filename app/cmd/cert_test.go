@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ed25519"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func newSigner(t *testing.T) gossh.Signer {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestCheckCert(t *testing.T) {
+	ca, other := newSigner(t), newSigner(t)
+	authorities := []ssh.PublicKey{ca.PublicKey()}
+	now := time.Date(2017, 1, 31, 18, 0, 0, 0, time.UTC)
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 2222}
+
+	newCert := func(fn func(*gossh.Certificate), authority gossh.Signer) *gossh.Certificate {
+		cert := &gossh.Certificate{
+			Key:             newSigner(t).PublicKey(),
+			CertType:        gossh.UserCert,
+			ValidPrincipals: []string{"user"},
+			ValidAfter:      uint64(now.Add(-time.Hour).Unix()),
+			ValidBefore:     uint64(now.Add(time.Hour).Unix()),
+			Permissions: gossh.Permissions{
+				CriticalOptions: map[string]string{},
+				Extensions:      map[string]string{},
+			},
+		}
+		if fn != nil {
+			fn(cert)
+		}
+		if err := cert.SignCert(rand.Reader, authority); err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	assert.NoError(t, checkCert(newCert(nil, ca), "user", addr, authorities, now))
+	assert.Error(t, checkCert(newCert(nil, ca), "other", addr, authorities, now),
+		"Principal should match user")
+	assert.Error(t, checkCert(newCert(nil, other), "user", addr, authorities, now),
+		"Cert should be signed by a trusted CA")
+	assert.Error(t, checkCert(newCert(nil, ca), "user", addr, nil, now),
+		"Certs should be rejected without a CA")
+	assert.Error(t, checkCert(newCert(nil, ca), "user", addr, authorities, now.Add(2*time.Hour)),
+		"Expired cert should be rejected")
+	assert.Error(t, checkCert(newCert(func(c *gossh.Certificate) {
+		c.ValidPrincipals = nil
+	}, ca), "user", addr, authorities, now), "Cert without principals should be rejected")
+	assert.Error(t, checkCert(newCert(func(c *gossh.Certificate) {
+		c.CriticalOptions["verify-required"] = ""
+	}, ca), "user", addr, authorities, now), "Unknown critical options should be rejected")
+
+	sourceCert := newCert(func(c *gossh.Certificate) {
+		c.CriticalOptions[sourceAddressOption] = "198.51.100.0/24,192.0.2.1"
+	}, ca)
+	assert.NoError(t, checkCert(sourceCert, "user", addr, authorities, now))
+	assert.Error(t, checkCert(sourceCert, "user", &net.TCPAddr{IP: net.ParseIP("203.0.113.1")}, authorities, now))
+}
+
+type fakeSession struct {
+	ssh.Session
+	command []string
+}
+
+func (s *fakeSession) Command() []string { return s.command }
+func (s *fakeSession) Pty() (ssh.Pty, <-chan ssh.Window, bool) {
+	return ssh.Pty{Term: "xterm"}, nil, true
+}
+
+func TestCertSession(t *testing.T) {
+	sess := &fakeSession{command: []string{"cmd", "arg"}}
+	cert := &gossh.Certificate{Permissions: gossh.Permissions{
+		Extensions: map[string]string{permitPtyExtension: ""},
+	}}
+	s := certSession(sess, cert)
+	assert.Equal(t, []string{"cmd", "arg"}, s.Command())
+	_, _, isPty := s.Pty()
+	assert.True(t, isPty)
+
+	cert = &gossh.Certificate{Permissions: gossh.Permissions{
+		CriticalOptions: map[string]string{forceCommandOption: "deploy 'a b'"},
+	}}
+	s = certSession(sess, cert)
+	assert.Equal(t, []string{"deploy", "a b"}, s.Command(), "Forced command should replace command")
+	_, _, isPty = s.Pty()
+	assert.False(t, isPty, "Pty should require permit-pty")
+}
+
+func TestAgentPermitted(t *testing.T) {
+	assert.True(t, agentPermitted(context.Background()), "Sessions without a cert should permit the agent")
+
+	cert := &gossh.Certificate{Permissions: gossh.Permissions{
+		Extensions: map[string]string{permitAgentExtension: ""},
+	}}
+	assert.True(t, agentPermitted(context.WithValue(context.Background(), "cert", cert)))
+
+	cert = &gossh.Certificate{}
+	assert.False(t, agentPermitted(context.WithValue(context.Background(), "cert", cert)),
+		"Agent forwarding should require permit-agent-forwarding")
+}
@@ -2,16 +2,22 @@ package cmd
 
 import (
 	"sort"
+	"sync"
 
 	"github.com/gliderlabs/comlab/pkg/com"
 	"github.com/gliderlabs/ssh"
 )
 
 func init() {
-	com.Register("cmd", &Component{})
+	com.Register("cmd", &Component{},
+		com.Option("reap_interval", "1m", "interval between reaping expired jobs"),
+		com.Option("user_ca_keys", "", "path to file of CA keys trusted to sign user certificates"))
 }
 
-type Component struct{}
+type Component struct {
+	once sync.Once
+	stop chan struct{}
+}
 
 type Preprocessor interface {
 	PreprocessOrder() uint
@@ -28,3 +34,17 @@ func Preprocessors() []Preprocessor {
 	})
 	return processors
 }
+
+// KeyProvider looks up the SSH public keys a user may authenticate with.
+// Users with no keys return none rather than an error.
+type KeyProvider interface {
+	UserKeys(user string) ([]ssh.PublicKey, error)
+}
+
+func KeyProviders() []KeyProvider {
+	var providers []KeyProvider
+	for _, com := range com.Enabled(new(KeyProvider), nil) {
+		providers = append(providers, com.(KeyProvider))
+	}
+	return providers
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/log"
+
+	"github.com/gliderlabs/cmd/app/core"
+)
+
+// Serve reaps expired jobs until stopped.
+func (c *Component) Serve() {
+	interval, err := time.ParseDuration(com.GetString("reap_interval"))
+	if err != nil {
+		log.Info(err)
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopped():
+			return
+		case <-ticker.C:
+			c.reapJobs()
+		}
+	}
+}
+
+// Stop reaping jobs.
+func (c *Component) Stop() {
+	close(c.stopped())
+}
+
+// stopped returns the channel closed by Stop, made on first use since Stop
+// may be called before Serve.
+func (c *Component) stopped() chan struct{} {
+	c.once.Do(func() {
+		c.stop = make(chan struct{})
+	})
+	return c.stop
+}
+
+func (c *Component) reapJobs() {
+	ctx := context.Background()
+	rt, err := core.JobRuntime(ctx)
+	if err != nil {
+		log.Info(err)
+		return
+	}
+	if err := core.ReapJobs(ctx, rt, time.Now()); err != nil {
+		log.Info(err)
+	}
+}
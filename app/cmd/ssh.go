@@ -1,9 +1,7 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
-	"net/http"
 	"strings"
 	"time"
 
@@ -11,6 +9,7 @@ import (
 	"github.com/gliderlabs/ssh"
 	"github.com/patrickmn/go-cache"
 	"github.com/satori/go.uuid"
+	gossh "golang.org/x/crypto/ssh"
 
 	"github.com/gliderlabs/cmd/app/builtin"
 	"github.com/gliderlabs/cmd/app/console"
@@ -37,9 +36,16 @@ func (c *Component) HandleSSH(s ssh.Session) {
 		cmdName  = ""
 	)
 	defer func() {
-		log.Info(s, cmd, time.Since(start), msg, log.Fields{"docker": cmd.Docker().Host})
+		log.Info(s, cmd, time.Since(start), msg, log.Fields{"docker": cmd.RuntimeHost()})
 	}()
 
+	if ctx, ok := s.Context().(ssh.Context); ok {
+		ctx.SetValue("agent", agentPermitted(ctx))
+	}
+	if cert := contextCert(s.Context()); cert != nil {
+		s = certSession(s, cert)
+	}
+
 	var cont bool
 	for _, preprocessor := range Preprocessors() {
 		cont, msg = preprocessor.PreprocessSession(s)
@@ -49,9 +55,20 @@ func (c *Component) HandleSSH(s ssh.Session) {
 		}
 	}
 
+	token := core.ContextToken(s.Context())
+	if token != nil && token.NoStdin {
+		s = core.WithoutStdin(s)
+	}
+
 	// TODO: make builtins also a preprocessor!
 	args := s.Command()
 	if len(args) == 0 || strings.HasPrefix(args[0], ":") {
+		if token != nil && len(token.Cmds) > 0 {
+			msg = "builtin access denied"
+			fmt.Fprintln(s.Stderr(), "Not allowed")
+			s.Exit(1)
+			return
+		}
 		if err := builtin.Execute(s); err != nil {
 			s.Exit(255)
 		}
@@ -68,7 +85,13 @@ func (c *Component) HandleSSH(s ssh.Session) {
 			path, ok := c.Environment["io.cmd.git-receive"]
 			if ok && strings.HasPrefix(args[1], path) {
 				cmd = c
-				c.Run(s, args)
+				if token != nil && !token.AllowsCmd(c.User, c.Name) {
+					msg = "token cmd access denied"
+					fmt.Fprintln(s.Stderr(), "Not allowed")
+					s.Exit(1)
+					return
+				}
+				runRecorded(c, s, core.RunViaGit, args)
 				return
 			}
 		}
@@ -87,13 +110,23 @@ func (c *Component) HandleSSH(s ssh.Session) {
 		s.Exit(1)
 		return
 	}
-	if !cmd.HasAccess(userName) {
+	if !cmd.HasAccess(userName) || (token != nil && !token.AllowsCmd(cmd.User, cmd.Name)) {
 		msg = "cmd access denied"
 		fmt.Fprintln(s.Stderr(), "Not allowed")
 		s.Exit(1)
 		return
 	}
-	cmd.Run(s, args[1:])
+	runRecorded(cmd, s, core.RunViaSSH, args[1:])
+}
+
+// runRecorded runs cmd for the session and records the run in its history.
+func runRecorded(cmd *core.Command, s ssh.Session, via string, args []string) int {
+	rec := core.NewRunRecorder(cmd, s, via, args)
+	status := cmd.Run(rec, args)
+	if err := store.Selected().PutRun(rec.Finish(status)); err != nil {
+		log.Info(s, cmd, err)
+	}
+	return status
 }
 
 func (c *Component) HandleAuth(ctx ssh.Context, key ssh.PublicKey) bool {
@@ -101,36 +134,34 @@ func (c *Component) HandleAuth(ctx ssh.Context, key ssh.PublicKey) bool {
 	if tok := uuid.FromStringOrNil(user); tok != uuid.Nil {
 		token, _ := store.Selected().GetToken(tok.String())
 		if token != nil && token.Key == user {
+			if err := token.Authorize(core.AddrIP(ctx.RemoteAddr())); err != nil {
+				log.Info(user, err)
+				return false
+			}
+			ctx.SetValue("token", token)
+			store.RecordTokenUse(token.Key, core.AddrIP(ctx.RemoteAddr()))
 			return true
 		}
 		log.Info("no match found for token: " + user)
 	}
 
+	if cert, ok := key.(*gossh.Certificate); ok {
+		return c.authCert(ctx, cert)
+	}
+
 	var u cachedUser
 	cu, ok := authCache.Get(user)
 	if ok {
 		u = cu.(cachedUser)
 	} else {
-		resp, err := http.Get(fmt.Sprintf("https://github.com/%s.keys", user))
-		if err != nil {
-			log.Info(user, err)
-			return false
-		}
-		if resp.StatusCode == http.StatusNotFound {
-			log.Info(fmt.Sprintf("github user '%s' not found", user), key)
-			return false
-		}
-		defer resp.Body.Close()
-		scanner := bufio.NewScanner(resp.Body)
-		scanner.Split(bufio.ScanLines)
 		var keys []ssh.PublicKey
-		for scanner.Scan() {
-			k, _, _, _, err := ssh.ParseAuthorizedKey(scanner.Bytes())
+		for _, provider := range KeyProviders() {
+			k, err := provider.UserKeys(user)
 			if err != nil {
 				log.Info(user, err)
 				continue
 			}
-			keys = append(keys, k)
+			keys = append(keys, k...)
 		}
 		usr, err := console.LookupNickname(user)
 		if err != nil {
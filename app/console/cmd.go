@@ -20,9 +20,9 @@ func (c *Component) PreprocessSession(sess ssh.Session) (cont bool, msg string)
 			fmt.Fprintf(sess, cli.Bright("\nWelcome, %s!\n\n"), sess.User())
 			fmt.Fprintln(sess, "We noticed this is your first login. So far so good!")
 			fmt.Fprintln(sess, "Would you mind logging in via the web interface?")
-			fmt.Fprintln(sess, "This way we can properly set up your account:\n")
+			fmt.Fprint(sess, "This way we can properly set up your account:\n\n")
 			fmt.Fprintf(sess, cli.Bright("https://%s/login\n\n"), release.Hostname())
-			fmt.Fprintln(sess, "Then you can come back and use SSH as usual. Thanks!\n")
+			fmt.Fprint(sess, "Then you can come back and use SSH as usual. Thanks!\n\n")
 			return false, "first time login"
 		}
 	}
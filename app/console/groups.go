@@ -0,0 +1,42 @@
+package console
+
+import (
+	"strings"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// TeamNamespace is the namespace of groups kept in the Groups of a user's
+// app metadata, so @team:ops is a user with an "ops" group.
+const TeamNamespace = "team"
+
+var userGroups = cache.New(time.Minute, 5*time.Minute)
+
+// InGroup resolves team groups of user. Unknown users have no groups.
+func (c *Component) InGroup(user, group string) (bool, error) {
+	if !strings.HasPrefix(group, TeamNamespace+":") {
+		return false, nil
+	}
+	groups, err := lookupGroups(user)
+	if err != nil {
+		return false, err
+	}
+	_, ok := groups[strings.TrimPrefix(group, TeamNamespace+":")]
+	return ok, nil
+}
+
+func lookupGroups(nickname string) (map[string]Account, error) {
+	if groups, ok := userGroups.Get(nickname); ok {
+		return groups.(map[string]Account), nil
+	}
+	user, err := LookupNickname(nickname)
+	if err == errNicknameNotFound {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	userGroups.Set(nickname, user.Account.Groups, cache.DefaultExpiration)
+	return user.Account.Groups, nil
+}
@@ -2,6 +2,7 @@ package console
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -79,6 +80,8 @@ func LookupUser(uid string) (User, error) {
 	return user, nil
 }
 
+var errNicknameNotFound = errors.New("nickname not found")
+
 func LookupNickname(nickname string) (User, error) {
 	users, err := auth0.DefaultClient().SearchUsers(
 		fmt.Sprintf("nickname:%s", nickname))
@@ -86,7 +89,7 @@ func LookupNickname(nickname string) (User, error) {
 		return User{}, err
 	}
 	if len(users) < 1 {
-		return User{}, fmt.Errorf("nickname not found")
+		return User{}, errNicknameNotFound
 	}
 	var user User
 	err = mapstructure.Decode(users[0], &user)
@@ -5,15 +5,13 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"strings"
 	"time"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-units"
 	"github.com/gliderlabs/comlab/pkg/log"
 	"github.com/gliderlabs/ssh"
@@ -22,32 +20,12 @@ import (
 	"github.com/gliderlabs/cmd/app/billing"
 	"github.com/gliderlabs/cmd/lib/agentproxy"
 	"github.com/gliderlabs/cmd/lib/crypto"
-	"github.com/gliderlabs/cmd/lib/dockerbox"
 	"github.com/gliderlabs/cmd/lib/release"
+	"github.com/gliderlabs/cmd/lib/runtime"
 )
 
 const ServerSoftware = "cmd.io"
 
-// Token used to provide access to non-github users
-type Token struct {
-	Key         string
-	Description string
-	User        string
-	LastUsedIP  string
-	LastUsedOn  time.Time
-}
-
-func (t *Token) Validate() error {
-	if t.Key == "" {
-		return fmt.Errorf("token Key required")
-	}
-
-	if t.User == "" {
-		return fmt.Errorf("token User required")
-	}
-	return nil
-}
-
 // Command is a the definition for a runnable command on cmd
 type Command struct {
 	Name        string
@@ -57,22 +35,33 @@ type Command struct {
 	ACL         []string          `dynamodbav:",stringset,omitempty"`
 	Admins      []string          `dynamodbav:",stringset,omitempty"`
 	Description string            `dynamodbav:",omitempty"`
+	Webhook     *Webhook          `dynamodbav:",omitempty"`
 
 	Changed bool `dynamodbav:"-"`
 
-	docker *dockerbox.Client
+	runtime runtime.Runtime
 }
 
-// Docker will return a configured docker client
-func (c *Command) Docker() *dockerbox.Client {
-	if c.docker == nil {
+// Runtime returns the runtime to run the command with, selecting where to
+// run it the first time.
+func (c *Command) Runtime() runtime.Runtime {
+	if c.runtime == nil {
 		var err error
-		c.docker, err = dockerbox.GetBackend()
+		c.runtime, err = runtime.Selected().Runtime(context.Background(), c.image())
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
-	return c.docker
+	return c.runtime
+}
+
+// RuntimeHost returns the host of the runtime selected for the command, or
+// an empty string if none has been selected.
+func (c *Command) RuntimeHost() string {
+	if c.runtime == nil {
+		return ""
+	}
+	return c.runtime.Host()
 }
 
 // SetEnv for command
@@ -103,32 +92,30 @@ func (c *Command) HasAccess(user string) bool {
 	if c.User == user {
 		return true
 	}
-	for _, u := range c.ACL {
-		if u == user {
-			return true
-		}
-	}
-	for _, u := range c.Admins {
-		if u == user {
-			return true
-		}
-	}
-	return false
+	return hasSubject(c.ACL, user) || hasSubject(c.Admins, user)
 }
 
 func (c *Command) IsAdmin(user string) bool {
 	if c.User == user {
 		return true
 	}
-	for _, u := range c.Admins {
-		if u == user {
-			return true
-		}
-	}
-	return false
+	return hasSubject(c.Admins, user)
 }
 
+// IsSource returns true if command is built from source rather than imported
+// from a Docker image.
+func (c *Command) IsSource() bool {
+	return strings.HasPrefix(c.Source, "#!")
+}
+
+// image returns the image tag for command. Images for source commands are
+// tagged with a hash of the source so an image is only built once for any
+// given source.
 func (c *Command) image() string {
+	if c.IsSource() {
+		sum := sha256.Sum256([]byte(c.Source))
+		return fmt.Sprintf("cmd-source:%x", sum)
+	}
 	return fmt.Sprintf("%s-%s", c.User, c.Name)
 }
 
@@ -155,16 +142,14 @@ func parseSource(src []byte) (img string, pkgs []string, body []byte, err error)
 
 func getBuildCtx(img string, pkgs []string, body []byte) (ctx map[string][]byte, err error) {
 	ctx = map[string][]byte{}
-	if img != "alpine" {
-		err = errors.Errorf("unsupported image: currently alpine is the only supported image")
+	from, err := dockerfileFrom(img, pkgs)
+	if err != nil {
 		return
 	}
 
 	var dockerfile bytes.Buffer
-	fmt.Fprintln(&dockerfile, "FROM", img)
-	if len(pkgs) != 0 {
-		fmt.Fprintln(&dockerfile,
-			"RUN apk --no-cache add", strings.Join(pkgs, " "))
+	for _, instruction := range from {
+		fmt.Fprintln(&dockerfile, instruction)
 	}
 	adv, entrypoint, _ := bufio.ScanLines(body, false)
 	if entrypoint == nil {
@@ -183,7 +168,38 @@ func getBuildCtx(img string, pkgs []string, body []byte) (ctx map[string][]byte,
 	return
 }
 
-func (c *Command) Build() error {
+// BuildError is returned when the runtime reports a failed build step.
+type BuildError = runtime.BuildError
+
+// Build image for command unless an image for the current source already
+// exists. Build output is written to out, which may be nil to discard it.
+func (c *Command) Build(out io.Writer) error {
+	ctx := context.Background()
+	exists, err := c.Runtime().ImageExists(ctx, c.image())
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	if out == nil {
+		out = ioutil.Discard
+	}
+	if err := c.build(ctx, out); err != nil {
+		return err
+	}
+	// guard against builds which ended without reporting an error
+	exists, err = c.Runtime().ImageExists(ctx, c.image())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &BuildError{Message: "no image was produced"}
+	}
+	return nil
+}
+
+func (c *Command) build(ctx context.Context, out io.Writer) error {
 	img, pkgs, body, err := parseSource([]byte(c.Source))
 	if err != nil {
 		return err
@@ -212,54 +228,41 @@ func (c *Command) Build() error {
 		return err
 	}
 
-	ctx := context.Background()
-	r := bytes.NewReader(buf.Bytes())
-	resp, err := c.Docker().ImageBuild(ctx, r, types.ImageBuildOptions{
-		Dockerfile: "Dockerfile",
-		Tags:       []string{c.image()},
-	})
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	// read and discard all output to ensure we don't return before the image
-	// is built and tagged
-	io.Copy(ioutil.Discard, resp.Body)
-	return nil
+	return c.Runtime().BuildImage(ctx, buf, c.image(), out)
 }
 
 // Pull and tag image for command
 func (c *Command) Pull(ctx context.Context) error {
-	res, err := c.Docker().ImagePull(ctx, c.Source, types.ImagePullOptions{})
-	if err != nil {
+	rt := c.Runtime()
+	if err := rt.PullImage(ctx, c.Source); err != nil {
 		return err
 	}
-	io.Copy(ioutil.Discard, res)
-	res.Close()
 
-	img, _, err := c.Docker().ImageInspectWithRaw(ctx, c.Source)
+	size, err := rt.ImageSize(ctx, c.Source)
 	if err != nil {
 		return err
 	}
 
-	if maxSize := billing.ContextPlan(ctx).ImageSize; img.Size > maxSize {
-		c.Docker().ImageRemove(ctx, c.Source, types.ImageRemoveOptions{}) // Do something with error
+	if maxSize := billing.ContextPlan(ctx).ImageSize; size > maxSize {
+		rt.RemoveImage(ctx, c.Source) // Do something with error
 		return errors.Errorf("image size excedes plan limit of: %s with: %s",
 			units.BytesSize(float64(maxSize)),
-			units.BytesSize(float64(img.Size)))
+			units.BytesSize(float64(size)))
 	}
-	return c.Docker().ImageTag(ctx, c.Source, c.image())
+	return rt.TagImage(ctx, c.Source, c.image())
+}
+
+// prepare builds or pulls the image for command
+func (c *Command) prepare(ctx context.Context) error {
+	if c.IsSource() {
+		return c.Build(nil)
+	}
+	return c.Pull(ctx)
 }
 
 // Run a command in a container attaching input/output to ssh session
 func (c *Command) Run(sess ssh.Session, args []string) int {
-	var err error
-	if strings.HasPrefix(c.Source, "#!") {
-		err = c.Build()
-	} else {
-		err = c.Pull(sess.Context())
-	}
-	if err != nil {
+	if err := c.prepare(sess.Context()); err != nil {
 		fmt.Fprintln(sess.Stderr(), err.Error())
 		return 255
 	}
@@ -273,9 +276,10 @@ func (c *Command) Run(sess ssh.Session, args []string) int {
 	return status
 }
 
-func (c *Command) run(sess ssh.Session, args []string) (int, error) {
-	pty, winCh, isPty := sess.Pty()
-	client := c.Docker()
+// containerConfig returns the container used to run command for session
+// with args, limited by the session plan.
+func (c *Command) containerConfig(sess ssh.Session, args []string) *runtime.Container {
+	pty, _, isPty := sess.Pty()
 	env := append([]string{
 		"REMOTE_ADDR=" + sess.RemoteAddr().String(),
 		"USER=" + sess.User(),
@@ -285,18 +289,42 @@ func (c *Command) run(sess ssh.Session, args []string) (int, error) {
 	if isPty {
 		env = append([]string{fmt.Sprintf("TERM=%s", pty.Term)}, env...)
 	}
+	p := billing.ContextPlan(sess.Context())
+	return &runtime.Container{
+		Image:        c.image(),
+		Env:          env,
+		Cmd:          args,
+		Tty:          isPty,
+		Stdin:        true,
+		AutoRemove:   true,
+		CPUPeriod:    p.CPUPeriod,
+		CPUQuota:     p.CPUQuota,
+		Memory:       p.Memory,
+		DockerSocket: p.DinD,
+	}
+}
+
+// AgentPermitted returns true if agent forwarding was permitted for the
+// session of ctx when it was accepted.
+func AgentPermitted(ctx context.Context) bool {
+	permitted, _ := ctx.Value("agent").(bool)
+	return permitted
+}
+
+func (c *Command) run(sess ssh.Session, args []string) (int, error) {
+	_, winCh, isPty := sess.Pty()
+	rt := c.Runtime()
 	ctx := sess.Context()
 	p := billing.ContextPlan(ctx)
-	hostConf := &container.HostConfig{
-		AutoRemove: true,
-		Resources: container.Resources{
-			CPUPeriod: p.CPUPeriod,
-			CPUQuota:  p.CPUQuota,
-			Memory:    p.Memory,
-		},
-	}
-	if ssh.AgentRequested(sess) {
-		proxy, err := agentproxy.NewAgentProxy(client, sess)
+	conf := c.containerConfig(sess, args)
+	agent := ssh.AgentRequested(sess) && AgentPermitted(ctx)
+	if agent && !runtime.SharesVolumes(rt) {
+		// run without the agent rather than failing the command
+		log.Info(sess.User(), "agent forwarding not supported on", rt.Host())
+		agent = false
+	}
+	if agent {
+		proxy, err := agentproxy.NewAgentProxy(rt, sess)
 		if err != nil {
 			return 255, err
 		}
@@ -304,60 +332,30 @@ func (c *Command) run(sess ssh.Session, args []string) (int, error) {
 			return 255, err
 		}
 		defer proxy.Shutdown()
-		env = append(env, fmt.Sprintf("SSH_AUTH_SOCK=%s", proxy.SocketPath))
-		hostConf.VolumesFrom = []string{proxy.ContainerID}
+		conf.Env = append(conf.Env, fmt.Sprintf("SSH_AUTH_SOCK=%s", proxy.SocketPath))
+		conf.VolumesFrom = []string{proxy.ContainerID}
 	}
-	conf := &container.Config{
-		Image:        c.image(),
-		Env:          env,
-		Cmd:          args,
-		Tty:          isPty,
-		OpenStdin:    true,
-		AttachStderr: true,
-		AttachStdin:  true,
-		AttachStdout: true,
-		StdinOnce:    true,
-		Volumes:      make(map[string]struct{}),
-	}
-
-	if p.DinD {
-		// TODO: actual feature, maybe: https://github.com/gliderlabs/cmd/issues/40
-		conf.Volumes["/var/run/docker.sock"] = struct{}{}
-		hostConf.Binds = []string{"/var/run/docker.sock:/var/run/docker.sock"}
-	}
-	res, err := client.ContainerCreate(ctx, conf, hostConf, nil, "")
+	id, err := rt.Create(ctx, conf)
 	if err != nil {
 		return 255, err
 	}
-	defer client.ContainerRemove(ctx, res.ID, types.ContainerRemoveOptions{Force: true})
-	containerStream, err := client.ContainerAttach(ctx, res.ID,
-		types.ContainerAttachOptions{
-			Stdin:  true,
-			Stdout: true,
-			Stderr: true,
-			Stream: true,
-		})
+	defer rt.Remove(ctx, id)
+	containerStream, err := rt.Attach(ctx, id, isPty)
 	if err != nil {
 		return 255, err
 	}
 	defer containerStream.Close()
 	receiveStream := make(chan error, 1)
 	go func() {
-		var err error
-		if isPty {
-			_, err = io.Copy(sess, containerStream.Reader)
-		} else {
-			_, err = stdcopy.StdCopy(sess, sess.Stderr(), containerStream.Reader)
-		}
-		receiveStream <- err
+		receiveStream <- containerStream.Output(sess, sess.Stderr())
 	}()
 
 	go func() {
 		defer containerStream.CloseWrite()
-		io.Copy(containerStream.Conn, sess)
+		io.Copy(containerStream, sess)
 	}()
 
-	err = client.ContainerStart(ctx, res.ID, types.ContainerStartOptions{})
+	err = rt.Start(ctx, id)
 	if err != nil {
 		return 255, err
 	}
@@ -365,10 +363,7 @@ func (c *Command) run(sess ssh.Session, args []string) (int, error) {
 	if isPty {
 		go func() {
 			for win := range winCh {
-				err := client.ContainerResize(ctx, res.ID, types.ResizeOptions{
-					Height: uint(win.Height),
-					Width:  uint(win.Width),
-				})
+				err := rt.Resize(ctx, id, win.Width, win.Height)
 				if err != nil {
 					log.Info(errors.Wrap(err, "failed to resize pty"))
 					break
@@ -377,9 +372,9 @@ func (c *Command) run(sess ssh.Session, args []string) (int, error) {
 		}()
 	}
 
-	statusChan := make(chan int64, 1)
+	statusChan := make(chan int, 1)
 	go func() {
-		s, err := client.ContainerWait(ctx, res.ID)
+		s, err := rt.Wait(ctx, id)
 		if err != nil {
 			log.Info(errors.Wrap(err, "container wait failed"))
 		}
@@ -397,5 +392,5 @@ func (c *Command) run(sess ssh.Session, args []string) (int, error) {
 	}
 
 	status := <-statusChan
-	return int(status), nil
+	return status, nil
 }
@@ -1,15 +1,24 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"io/ioutil"
+	"net"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/gliderlabs/cmd/lib/dockerbox"
 	mock_client "github.com/gliderlabs/cmd/lib/mock/docker/docker/client"
+	"github.com/gliderlabs/cmd/lib/runtime"
+	"github.com/gliderlabs/cmd/lib/runtime/docker"
+	"github.com/gliderlabs/cmd/lib/runtime/fake"
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/ssh"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 
@@ -70,6 +79,41 @@ func TestHasAccess(t *testing.T) {
 		assert.Equal(t, test.Expected, actual)
 	}
 }
+
+const testTokenKey = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+type fakeGroups map[string][]string
+
+func (g fakeGroups) InGroup(user, group string) (bool, error) {
+	for _, member := range g[group] {
+		if member == user {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func init() {
+	com.Register("core.fakegroups", fakeGroups{"team:ops": {"ops", testTokenKey}})
+}
+
+func TestGroupAccess(t *testing.T) {
+	cmd := &Command{User: "owner", ACL: []string{"@team:ops"}, Admins: []string{"@team:admins"}}
+	assert.True(t, cmd.HasAccess("ops"), "Group members should have access")
+	assert.False(t, cmd.HasAccess("nobody"))
+	assert.False(t, cmd.IsAdmin("ops"))
+	assert.False(t, cmd.HasAccess(testTokenKey), "Groups should not be resolved for tokens")
+	cmd.ACL = append(cmd.ACL, testTokenKey)
+	assert.True(t, cmd.HasAccess(testTokenKey), "Tokens should have access when listed")
+	assert.False(t, (&Command{ACL: []string{"team:ops"}}).HasAccess("ops"),
+		"Subjects without prefix should not be groups")
+
+	assert.True(t, ValidGroup("@gh:org/team"))
+	assert.False(t, ValidGroup("@ops"))
+	assert.False(t, ValidGroup("@team:"))
+	assert.False(t, ValidGroup("team:ops"))
+}
+
 func TestParseSource(t *testing.T) {
 	var testCases = []struct {
 		Source      []byte
@@ -200,6 +244,38 @@ ENTRYPOINT ["/bin/entrypoint"]
 				"entrypoint": []byte("#!/usr/bin/bash\necho"),
 			},
 		},
+		{
+			Image: "debian",
+			Pkgs:  []string{"curl", "jq"},
+			Body:  []byte("#!/usr/bin/curl\n"),
+			ExpectCtx: map[string][]byte{
+				"Dockerfile": []byte(`FROM debian:stretch-slim
+RUN apt-get update && apt-get install -y --no-install-recommends curl jq && rm -rf /var/lib/apt/lists/*
+WORKDIR /cmd
+ENTRYPOINT ["/usr/bin/curl"]
+`),
+			},
+		},
+		{
+			Image: "python",
+			Pkgs:  []string{"pip:requests", "git", "pip:click"},
+			Body:  []byte("#!/usr/local/bin/python\n"),
+			ExpectCtx: map[string][]byte{
+				"Dockerfile": []byte(`FROM python:3-slim
+RUN apt-get update && apt-get install -y --no-install-recommends git && rm -rf /var/lib/apt/lists/*
+RUN pip install --no-cache-dir requests click
+WORKDIR /cmd
+ENTRYPOINT ["/usr/local/bin/python"]
+`),
+			},
+		},
+		{
+			Image:     "alpine", // Unsupported package prefix
+			Pkgs:      []string{"npm:left-pad"},
+			Body:      []byte("#!/usr/bin/bash\n"),
+			ExpectErr: true,
+			ExpectCtx: map[string][]byte{},
+		},
 	}
 
 	for _, test := range testCases {
@@ -226,7 +302,7 @@ func TestCmdPull(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		client := mock_client.NewMockAPIClient(ctrl)
-		cmd.docker = &dockerbox.Client{client, "test"}
+		cmd.runtime = docker.New(&dockerbox.Client{APIClient: client, Host: "test"})
 		pullRes := ioutil.NopCloser(strings.NewReader(""))
 		client.EXPECT().
 			ImagePull(gomock.Any(), cmd.Source, types.ImagePullOptions{}).
@@ -247,7 +323,7 @@ func TestCmdPull(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		client := mock_client.NewMockAPIClient(ctrl)
-		cmd.docker = &dockerbox.Client{client, "test"}
+		cmd.runtime = docker.New(&dockerbox.Client{APIClient: client, Host: "test"})
 		pullRes := ioutil.NopCloser(strings.NewReader(""))
 		client.EXPECT().
 			ImagePull(gomock.Any(), cmd.Source, types.ImagePullOptions{}).
@@ -263,3 +339,395 @@ func TestCmdPull(t *testing.T) {
 	})
 
 }
+
+func TestCmdRun(t *testing.T) {
+	cmd := &Command{
+		Source: "alpine",
+		Name:   "cmd",
+		User:   "user",
+	}
+	var ran *runtime.Container
+	rt := &fake.Runtime{
+		Registry: map[string]int64{"alpine": 1},
+		Run: func(p *fake.Process) int {
+			ran = p.Container
+			in, _ := ioutil.ReadAll(p.Stdin)
+			p.Stdout.Write(bytes.ToUpper(in))
+			io.WriteString(p.Stderr, "done")
+			return 3
+		},
+	}
+	cmd.runtime = rt
+	sess := &fakeSession{in: strings.NewReader("input")}
+
+	assert.Equal(t, 3, cmd.Run(sess, []string{"arg"}))
+	assert.Equal(t, "INPUT", sess.stdout.String())
+	assert.Equal(t, "done", sess.stderr.String())
+	if assert.NotNil(t, ran) {
+		assert.Equal(t, cmd.image(), ran.Image)
+		assert.Equal(t, []string{"arg"}, ran.Cmd)
+		assert.Contains(t, ran.Env, "USER=caller")
+		assert.Equal(t, billing.Plans[billing.DefaultPlan].Memory, ran.Memory)
+	}
+	exists, _ := rt.ImageExists(context.Background(), cmd.image())
+	assert.True(t, exists, "Pulled image should be tagged for command")
+}
+
+type imageNotFoundError struct{}
+
+func (imageNotFoundError) Error() string  { return "image not found" }
+func (imageNotFoundError) NotFound() bool { return true }
+
+func TestCmdBuild(t *testing.T) {
+	cmd := &Command{
+		Source: "#!cmd alpine bash\n#!/bin/bash\necho hello",
+		Name:   "hello",
+		User:   "nobody",
+	}
+
+	t.Run("ImageTag", func(t *testing.T) {
+		edited := *cmd
+		edited.Source = "#!cmd alpine bash\n#!/bin/bash\necho edited"
+		assert.NotEqual(t, cmd.image(), edited.image(),
+			"Image tag should change with source")
+		renamed := *cmd
+		renamed.Name = "renamed"
+		assert.Equal(t, cmd.image(), renamed.image(),
+			"Image tag should only depend on source")
+	})
+
+	t.Run("Cached", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		client := mock_client.NewMockAPIClient(ctrl)
+		cmd.runtime = docker.New(&dockerbox.Client{APIClient: client, Host: "test"})
+		client.EXPECT().
+			ImageInspectWithRaw(gomock.Any(), cmd.image()).
+			Return(types.ImageInspect{}, []byte{}, nil)
+
+		assert.NoError(t, cmd.Build(nil))
+	})
+
+	t.Run("NotCached", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		client := mock_client.NewMockAPIClient(ctrl)
+		cmd.runtime = docker.New(&dockerbox.Client{APIClient: client, Host: "test"})
+		gomock.InOrder(
+			client.EXPECT().
+				ImageInspectWithRaw(gomock.Any(), cmd.image()).
+				Return(types.ImageInspect{}, nil, imageNotFoundError{}),
+			client.EXPECT().
+				ImageBuild(gomock.Any(), gomock.Any(), types.ImageBuildOptions{
+					Dockerfile: "Dockerfile",
+					Tags:       []string{cmd.image()},
+				}).
+				Return(types.ImageBuildResponse{
+					Body: ioutil.NopCloser(strings.NewReader(
+						`{"stream":"Step 1/4 : FROM alpine\n"}` +
+							`{"status":"Pulling fs layer","id":"abc"}` +
+							`{"stream":"Successfully built abc\n"}`)),
+				}, nil),
+			client.EXPECT().
+				ImageInspectWithRaw(gomock.Any(), cmd.image()).
+				Return(types.ImageInspect{}, []byte{}, nil),
+		)
+
+		var out bytes.Buffer
+		assert.NoError(t, cmd.Build(&out))
+		assert.Equal(t, "Step 1/4 : FROM alpine\nSuccessfully built abc\n", out.String())
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		client := mock_client.NewMockAPIClient(ctrl)
+		cmd.runtime = docker.New(&dockerbox.Client{APIClient: client, Host: "test"})
+		client.EXPECT().
+			ImageInspectWithRaw(gomock.Any(), cmd.image()).
+			Return(types.ImageInspect{}, nil, imageNotFoundError{})
+		client.EXPECT().
+			ImageBuild(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(types.ImageBuildResponse{
+				Body: ioutil.NopCloser(strings.NewReader(
+					`{"stream":"Step 2/4 : RUN apk --no-cache add nope\n"}` +
+						`{"errorDetail":{"code":1,"message":"The command returned a non-zero code: 1"},` +
+						`"error":"The command returned a non-zero code: 1"}`)),
+			}, nil)
+
+		err := cmd.Build(nil)
+		if assert.IsType(t, &BuildError{}, err) {
+			assert.Equal(t, "The command returned a non-zero code: 1", err.(*BuildError).Message)
+		}
+	})
+
+	t.Run("NoImage", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		client := mock_client.NewMockAPIClient(ctrl)
+		cmd.runtime = docker.New(&dockerbox.Client{APIClient: client, Host: "test"})
+		client.EXPECT().
+			ImageInspectWithRaw(gomock.Any(), cmd.image()).
+			Return(types.ImageInspect{}, nil, imageNotFoundError{}).
+			Times(2)
+		client.EXPECT().
+			ImageBuild(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(types.ImageBuildResponse{
+				Body: ioutil.NopCloser(strings.NewReader("")),
+			}, nil)
+
+		assert.IsType(t, &BuildError{}, cmd.Build(nil))
+	})
+}
+
+func TestNewRevision(t *testing.T) {
+	cmd := &Command{
+		User:        "user",
+		Name:        "cmd",
+		Source:      "#!cmd alpine\necho hello",
+		Environment: map[string]string{"FOO": "secret", "BAR": "secret"},
+	}
+	rev := cmd.NewRevision(RevisionEdit, "author")
+	assert.Equal(t, "user/cmd", rev.Cmd)
+	assert.Equal(t, 0, rev.Revision, "Revision numbers are assigned by the store")
+	assert.Equal(t, RevisionEdit, rev.Action)
+	assert.Equal(t, cmd.Source, rev.Source)
+	assert.Equal(t, []string{"BAR", "FOO"}, rev.EnvKeys)
+	assert.Equal(t, "author", rev.Author)
+	assert.False(t, rev.Created.IsZero())
+}
+
+func TestJobs(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	rt := &fake.Runtime{
+		Run: func(p *fake.Process) int {
+			if p.Cmd[0] == "exit" {
+				return 3
+			}
+			<-p.Killed
+			return 137
+		},
+	}
+	rt.AddImage("image", 0)
+	start := func(cmd, user string, deadline time.Time) string {
+		id, err := rt.Create(ctx, &runtime.Container{
+			Image: "image",
+			Cmd:   []string{cmd},
+			Labels: map[string]string{
+				LabelJob:         "true",
+				LabelJobUser:     user,
+				LabelJobCmd:      "owner/cmd",
+				LabelJobDeadline: deadline.Format(time.RFC3339),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rt.Start(ctx, id)
+		return shortID(id)
+	}
+	running := start("sleep", "user", now.Add(time.Hour))
+	expired := start("sleep", "user", now.Add(-time.Minute))
+	finished := start("exit", "user", now.Add(time.Hour))
+	start("sleep", "other", now.Add(time.Hour))
+	rt.Wait(ctx, finished)
+
+	jobs, err := ListJobs(ctx, rt, "user")
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 3, "Jobs should be listed by user")
+
+	job, err := GetJob(ctx, rt, "user", finished)
+	assert.NoError(t, err)
+	if assert.NotNil(t, job) {
+		assert.Equal(t, "owner/cmd", job.Cmd)
+		assert.False(t, job.Running())
+		assert.Equal(t, 3, job.ExitCode)
+	}
+	_, err = GetJob(ctx, rt, "other", finished)
+	assert.Equal(t, ErrJobNotFound, err,
+		"Jobs should only be visible to the user who started them")
+
+	assert.NoError(t, ReapJobs(ctx, rt, now))
+	rt.Wait(ctx, expired)
+	job, _ = GetJob(ctx, rt, "user", expired)
+	assert.False(t, job.Running(), "Jobs past their deadline should be killed")
+	job, _ = GetJob(ctx, rt, "user", running)
+	assert.True(t, job.Running())
+	_, err = GetJob(ctx, rt, "user", finished)
+	assert.NoError(t, err, "Finished jobs should be kept for their logs")
+
+	assert.NoError(t, ReapJobs(ctx, rt, now.Add(JobRetention+time.Hour)))
+	_, err = GetJob(ctx, rt, "user", finished)
+	assert.Equal(t, ErrJobNotFound, err, "Finished jobs should be removed after JobRetention")
+}
+
+func TestCheckJobLimit(t *testing.T) {
+	ctx := context.Background()
+	rt := &fake.Runtime{}
+	rt.AddImage("image", 0)
+	create := func(user string) string {
+		id, _ := rt.Create(ctx, &runtime.Container{
+			Image:  "image",
+			Labels: map[string]string{LabelJob: "true", LabelJobUser: user},
+		})
+		return id
+	}
+	first, second, third := create("user"), create("user"), create("user")
+	create("other")
+
+	assert.NoError(t, checkJobLimit(ctx, rt, "user", first, 2))
+	assert.NoError(t, checkJobLimit(ctx, rt, "user", second, 2))
+	assert.Equal(t, billing.ErrMaxJobsExceded, checkJobLimit(ctx, rt, "user", third, 2),
+		"Jobs created past the limit should be rejected")
+	rt.Remove(ctx, first)
+	assert.NoError(t, checkJobLimit(ctx, rt, "user", third, 2))
+}
+
+func TestParseSchedule(t *testing.T) {
+	for _, spec := range []string{"*/5 * * * *", "0 9 * * MON-FRI", "@hourly", "@every 2h"} {
+		_, err := ParseSchedule(spec)
+		assert.NoError(t, err, spec)
+	}
+	for _, spec := range []string{"", "not a spec", "* * * * * *", "@every 10s", "0 0 30 2 *"} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+
+	schedule := &Schedule{Spec: "@hourly"}
+	now := time.Date(2017, 1, 31, 18, 30, 0, 0, time.UTC)
+	assert.False(t, schedule.Due(now), "Schedule without NextRun is never due")
+	assert.NoError(t, schedule.Next(now))
+	assert.Equal(t, time.Date(2017, 1, 31, 19, 0, 0, 0, time.UTC), schedule.NextRun)
+	assert.False(t, schedule.Due(now))
+	assert.True(t, schedule.Due(schedule.NextRun))
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &TailBuffer{Max: 4}
+	b.Write([]byte("abc"))
+	b.Write([]byte("def"))
+	assert.Equal(t, "cdef", b.String())
+}
+
+type fakeSession struct {
+	ssh.Session
+	in             io.Reader
+	stdout, stderr bytes.Buffer
+	ctx            context.Context
+}
+
+func (s *fakeSession) User() string                { return "caller" }
+func (s *fakeSession) Read(p []byte) (int, error)  { return s.in.Read(p) }
+func (s *fakeSession) Write(p []byte) (int, error) { return s.stdout.Write(p) }
+func (s *fakeSession) Stderr() io.ReadWriter       { return &s.stderr }
+func (s *fakeSession) Context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
+func (s *fakeSession) Command() []string    { return []string{"cmd"} }
+func (s *fakeSession) Environ() []string    { return nil }
+func (s *fakeSession) RemoteAddr() net.Addr { return &net.TCPAddr{IP: net.ParseIP("192.0.2.1")} }
+func (s *fakeSession) Pty() (ssh.Pty, <-chan ssh.Window, bool) {
+	return ssh.Pty{}, nil, false
+}
+
+func TestRunRecorder(t *testing.T) {
+	defer func(max int) { MaxRunOutput = max }(MaxRunOutput)
+	MaxRunOutput = 4
+	sess := &fakeSession{in: strings.NewReader("input")}
+	cmd := &Command{User: "user", Name: "cmd"}
+	rec := NewRunRecorder(cmd, sess, RunViaSSH, []string{"arg"})
+
+	ioutil.ReadAll(rec)
+	io.WriteString(rec, "hello world")
+	io.WriteString(rec.Stderr(), "oops")
+	run := rec.Finish(3)
+
+	assert.Equal(t, "hello world", sess.stdout.String(), "Output should pass through")
+	assert.Equal(t, "oops", sess.stderr.String())
+	assert.Equal(t, "user/cmd", run.Cmd)
+	assert.NotEmpty(t, run.ID)
+	assert.Equal(t, "caller", run.Caller)
+	assert.Equal(t, RunViaSSH, run.Via)
+	assert.Equal(t, []string{"arg"}, run.Args)
+	assert.Equal(t, 3, run.Status)
+	assert.Equal(t, int64(5), run.BytesIn)
+	assert.Equal(t, int64(15), run.BytesOut)
+	assert.Equal(t, "orld", run.Stdout, "Only the end of output should be kept")
+	assert.Equal(t, "oops", run.Stderr)
+	assert.False(t, run.Ended.Before(run.Started))
+
+	token := &Token{Key: testTokenKey, User: "user"}
+	sess = &fakeSession{in: strings.NewReader(""), ctx: context.WithValue(context.Background(), "token", token)}
+	run = NewRunRecorder(cmd, sess, RunViaHTTP, nil).Finish(0)
+	assert.Equal(t, "user (token "+token.ID()+")", run.Caller)
+	assert.NotContains(t, run.Caller, testTokenKey, "Token keys should not be recorded")
+}
+
+func TestTokenScope(t *testing.T) {
+	token := &Token{Key: "key", User: "user"}
+	assert.NoError(t, token.Authorize(net.ParseIP("192.0.2.1")))
+	assert.True(t, token.AllowsCmd("user", "cmd"))
+	assert.Equal(t, "", token.Scope())
+
+	token.ExpiresOn = time.Now().Add(-time.Minute)
+	assert.Equal(t, ErrTokenExpired, token.Authorize(nil))
+	token.ExpiresOn = time.Now().Add(time.Minute)
+	assert.NoError(t, token.Authorize(nil))
+
+	token.CIDRs = []string{"10.0.0.0/8", "192.0.2.1"}
+	assert.NoError(t, token.Validate())
+	assert.NoError(t, token.Authorize(net.ParseIP("10.1.2.3")))
+	assert.NoError(t, token.Authorize(net.ParseIP("192.0.2.1")))
+	assert.Equal(t, ErrTokenIPDenied, token.Authorize(net.ParseIP("192.0.2.2")))
+	assert.Equal(t, ErrTokenIPDenied, token.Authorize(nil))
+	token.CIDRs = []string{"10.0.0.0/33"}
+	assert.Error(t, token.Validate())
+
+	token.Cmds = []string{"cmd", "other/shared"}
+	assert.True(t, token.AllowsCmd("user", "cmd"))
+	assert.True(t, token.AllowsCmd("other", "shared"))
+	assert.False(t, token.AllowsCmd("user", "shared"))
+	assert.False(t, token.AllowsCmd("other", "cmd"),
+		"Commands scoped by name should only match commands of the token user")
+
+	token.NoStdin = true
+	assert.Contains(t, token.Scope(), "cmds cmd,other/shared")
+	assert.Contains(t, token.Scope(), "no stdin")
+
+	assert.Equal(t, "10.1.2.3", AddrIP(&net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 22}).String())
+}
+
+func TestTokenUnusedFor(t *testing.T) {
+	token := &Token{Created: time.Now().Add(-48 * time.Hour)}
+	assert.True(t, token.UnusedFor(24*time.Hour))
+	assert.False(t, token.UnusedFor(72*time.Hour))
+	token.LastUsedOn = time.Now().Add(-time.Hour)
+	assert.False(t, token.UnusedFor(24*time.Hour))
+	assert.True(t, (&Token{}).UnusedFor(24*time.Hour), "Tokens without dates should be unused")
+}
+
+func TestNewKey(t *testing.T) {
+	key, err := NewKey("alice", []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIEQHcbZocqKZjWeDb5r7hKDGxP88IuBzN3B59v23CxU2 alice@laptop\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "alice", key.User)
+	assert.Equal(t, "SHA256:6NoL47fTsc1HQluZBEGKojap+oVXjPlTRTO5aa//UwU", key.Fingerprint)
+	assert.Equal(t, "alice@laptop", key.Comment)
+	assert.NoError(t, key.Validate())
+	_, err = key.PublicKey()
+	assert.NoError(t, err)
+
+	_, err = NewKey("alice", []byte("not a key"))
+	assert.Error(t, err)
+}
+
+func TestAgentPermitted(t *testing.T) {
+	assert.False(t, AgentPermitted(context.Background()), "Agent should only be permitted explicitly")
+	assert.True(t, AgentPermitted(context.WithValue(context.Background(), "agent", true)))
+	assert.False(t, AgentPermitted(context.WithValue(context.Background(), "agent", false)))
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/log"
+)
+
+// GroupPrefix marks ACL subjects that are groups rather than users, such as
+// @team:ops or @gh:org/team.
+const GroupPrefix = "@"
+
+// GroupResolver resolves membership of groups in the namespaces it knows.
+// Groups are named <namespace>:<name>, and groups in other namespaces are
+// not members.
+type GroupResolver interface {
+	InGroup(user, group string) (bool, error)
+}
+
+func GroupResolvers() []GroupResolver {
+	var resolvers []GroupResolver
+	for _, com := range com.Enabled(new(GroupResolver), nil) {
+		resolvers = append(resolvers, com.(GroupResolver))
+	}
+	return resolvers
+}
+
+// IsGroup returns true if subject is a group.
+func IsGroup(subject string) bool {
+	return strings.HasPrefix(subject, GroupPrefix)
+}
+
+// ValidGroup returns true if subject is a group with a namespace and name.
+func ValidGroup(subject string) bool {
+	parts := strings.SplitN(strings.TrimPrefix(subject, GroupPrefix), ":", 2)
+	return IsGroup(subject) && len(parts) == 2 && parts[0] != "" && parts[1] != ""
+}
+
+// InGroup returns true if any enabled resolver has user as a member of group.
+func InGroup(user, group string) bool {
+	group = strings.TrimPrefix(group, GroupPrefix)
+	for _, resolver := range GroupResolvers() {
+		member, err := resolver.InGroup(user, group)
+		if err != nil {
+			log.Info(user, group, err)
+			continue
+		}
+		if member {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSubject returns true if subjects includes user, or a group user is a
+// member of. Groups are only resolved when user isn't listed directly, and
+// never for tokens so their keys aren't sent to resolvers.
+func hasSubject(subjects []string, user string) bool {
+	for _, s := range subjects {
+		if s == user {
+			return true
+		}
+	}
+	if IsTokenKey(user) {
+		return false
+	}
+	for _, s := range subjects {
+		if IsGroup(s) && InGroup(user, s) {
+			return true
+		}
+	}
+	return false
+}
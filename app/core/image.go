@@ -0,0 +1,110 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Installer returns a Dockerfile instruction installing pkgs.
+type Installer func(pkgs []string) string
+
+// BaseImage describes an image usable in the `#!cmd <image>` source header.
+type BaseImage struct {
+	Image    string               // Docker image used in FROM
+	Packages Installer            // installs system packages
+	Extras   map[string]Installer // installs packages prefixed with key, eg: "pip:requests"
+}
+
+// BaseImages supported by source commands keyed by header name.
+var BaseImages = map[string]BaseImage{
+	"alpine": {
+		Image:    "alpine",
+		Packages: apkInstall,
+	},
+	"debian": {
+		Image:    "debian:stretch-slim",
+		Packages: aptInstall,
+	},
+	"ubuntu": {
+		Image:    "ubuntu:xenial",
+		Packages: aptInstall,
+	},
+	"python": {
+		Image:    "python:3-slim",
+		Packages: aptInstall,
+		Extras:   map[string]Installer{"pip": pipInstall},
+	},
+	"node": {
+		Image:    "node:8-slim",
+		Packages: aptInstall,
+		Extras:   map[string]Installer{"npm": npmInstall},
+	},
+}
+
+func apkInstall(pkgs []string) string {
+	return "RUN apk --no-cache add " + strings.Join(pkgs, " ")
+}
+
+func aptInstall(pkgs []string) string {
+	return "RUN apt-get update" +
+		" && apt-get install -y --no-install-recommends " + strings.Join(pkgs, " ") +
+		" && rm -rf /var/lib/apt/lists/*"
+}
+
+func pipInstall(pkgs []string) string {
+	return "RUN pip install --no-cache-dir " + strings.Join(pkgs, " ")
+}
+
+func npmInstall(pkgs []string) string {
+	return "RUN npm install -g " + strings.Join(pkgs, " ")
+}
+
+func baseImageNames() []string {
+	var names []string
+	for name := range BaseImages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dockerfileFrom returns the FROM and package install instructions for the
+// named base image.
+func dockerfileFrom(name string, pkgs []string) ([]string, error) {
+	base, ok := BaseImages[name]
+	if !ok {
+		return nil, errors.Errorf("unsupported image: %s, supported images are: %s",
+			name, strings.Join(baseImageNames(), ", "))
+	}
+	var (
+		system []string
+		extras = map[string][]string{}
+		order  []string
+	)
+	for _, pkg := range pkgs {
+		parts := strings.SplitN(pkg, ":", 2)
+		if len(parts) < 2 {
+			system = append(system, pkg)
+			continue
+		}
+		if _, ok := base.Extras[parts[0]]; !ok {
+			return nil, errors.Errorf("unsupported package: %s, %s packages are not supported by image: %s",
+				pkg, parts[0], name)
+		}
+		if _, ok := extras[parts[0]]; !ok {
+			order = append(order, parts[0])
+		}
+		extras[parts[0]] = append(extras[parts[0]], parts[1])
+	}
+	instructions := []string{fmt.Sprintf("FROM %s", base.Image)}
+	if len(system) != 0 {
+		instructions = append(instructions, base.Packages(system))
+	}
+	for _, kind := range order {
+		instructions = append(instructions, base.Extras[kind](extras[kind]))
+	}
+	return instructions, nil
+}
@@ -0,0 +1,241 @@
+package core
+
+import (
+	"context"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/pkg/errors"
+
+	"github.com/gliderlabs/cmd/app/billing"
+	"github.com/gliderlabs/cmd/lib/runtime"
+)
+
+// Labels identifying job containers
+const (
+	LabelJob         = "io.cmd.job"
+	LabelJobUser     = "io.cmd.job.user"
+	LabelJobCmd      = "io.cmd.job.cmd"
+	LabelJobDeadline = "io.cmd.job.deadline"
+)
+
+// JobRetention is how long finished jobs are kept for their logs.
+var JobRetention = 24 * time.Hour
+
+// ErrJobNotFound returned when a job does not exist or belongs to another user
+var ErrJobNotFound = errors.New("job not found")
+
+// Job is a command run in a container detached from the session that
+// started it. Jobs are tracked by labels on their container, and the job ID
+// is the short container ID.
+type Job struct {
+	ID       string
+	User     string
+	Cmd      string
+	State    string
+	ExitCode int
+	Created  time.Time
+	Deadline time.Time
+}
+
+// Running returns true until the job container has exited.
+func (j *Job) Running() bool {
+	return j.State == "created" || j.State == "running"
+}
+
+// RunDetached starts command in a container for session with args and returns
+// without waiting for it to finish. Output is kept with the job and standard
+// input is not attached.
+func (c *Command) RunDetached(sess ssh.Session, args []string) (*Job, error) {
+	ctx := sess.Context()
+	p := billing.ContextPlan(ctx)
+	jobRuntime, err := JobRuntime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := ListJobs(ctx, jobRuntime, sess.User())
+	if err != nil {
+		return nil, err
+	}
+	var running int
+	for _, job := range jobs {
+		if job.Running() {
+			running++
+		}
+	}
+	if running >= p.MaxJobs {
+		return nil, billing.ErrMaxJobsExceded
+	}
+	if err := c.prepare(ctx); err != nil {
+		return nil, err
+	}
+
+	conf := c.containerConfig(sess, args)
+	conf.Tty = false
+	conf.Stdin = false
+	conf.AutoRemove = false
+	deadline := time.Now().Add(p.MaxJobRuntime).UTC()
+	conf.Labels = map[string]string{
+		LabelJob:         "true",
+		LabelJobUser:     sess.User(),
+		LabelJobCmd:      c.User + "/" + c.Name,
+		LabelJobDeadline: deadline.Format(time.RFC3339),
+	}
+	rt := c.Runtime()
+	id, err := rt.Create(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	// concurrent runs can all pass the check above, so check again now the
+	// job is visible to them
+	if err := checkJobLimit(ctx, jobRuntime, sess.User(), id, p.MaxJobs); err != nil {
+		rt.Remove(ctx, id)
+		return nil, err
+	}
+	if err := rt.Start(ctx, id); err != nil {
+		rt.Remove(ctx, id)
+		return nil, err
+	}
+	return &Job{
+		ID:       shortID(id),
+		User:     sess.User(),
+		Cmd:      conf.Labels[LabelJobCmd],
+		State:    "running",
+		Created:  time.Now().UTC(),
+		Deadline: deadline,
+	}, nil
+}
+
+// checkJobLimit returns ErrMaxJobsExceded unless job id is one of the max
+// oldest running jobs of user. Runs racing to start jobs agree on the order,
+// so only the jobs past the limit are rejected.
+func checkJobLimit(ctx context.Context, rt runtime.Runtime, user, id string, max int) error {
+	jobs, err := ListJobs(ctx, rt, user)
+	if err != nil {
+		return err
+	}
+	var running []*Job
+	for _, job := range jobs {
+		if job.Running() {
+			running = append(running, job)
+		}
+	}
+	sort.Slice(running, func(i, j int) bool {
+		if running[i].Created.Equal(running[j].Created) {
+			return running[i].ID < running[j].ID
+		}
+		return running[i].Created.Before(running[j].Created)
+	})
+	for i, job := range running {
+		if job.ID == shortID(id) {
+			if i >= max {
+				return billing.ErrMaxJobsExceded
+			}
+			return nil
+		}
+	}
+	if len(running) >= max {
+		return billing.ErrMaxJobsExceded
+	}
+	return nil
+}
+
+// JobRuntime returns the runtime managing jobs wherever they run.
+func JobRuntime(ctx context.Context) (runtime.Runtime, error) {
+	return runtime.Selected().Runtime(ctx, "")
+}
+
+// ListJobs returns all jobs started by user, or by anyone if user is empty,
+// newest first.
+func ListJobs(ctx context.Context, rt runtime.Runtime, user string) ([]*Job, error) {
+	labels := map[string]string{LabelJob: "true"}
+	if user != "" {
+		labels[LabelJobUser] = user
+	}
+	containers, err := rt.List(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	for _, container := range containers {
+		jobs = append(jobs, newJob(container))
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].Created.After(jobs[j].Created)
+	})
+	return jobs, nil
+}
+
+// GetJob returns job id if it was started by user.
+func GetJob(ctx context.Context, rt runtime.Runtime, user, id string) (*Job, error) {
+	container, err := rt.Inspect(ctx, id)
+	if err != nil {
+		return nil, ErrJobNotFound
+	}
+	labels := container.Labels
+	if labels[LabelJob] == "" || labels[LabelJobUser] != user {
+		return nil, ErrJobNotFound
+	}
+	return newJob(container), nil
+}
+
+// JobLogs writes output of job to stdout and stderr. When follow is set
+// output is streamed until the job exits.
+func JobLogs(ctx context.Context, rt runtime.Runtime, id string, follow bool, stdout, stderr io.Writer) error {
+	return rt.Logs(ctx, id, follow, stdout, stderr)
+}
+
+// KillJob stops a running job. The job is kept for its logs until reaped.
+func KillJob(ctx context.Context, rt runtime.Runtime, id string) error {
+	return rt.Kill(ctx, id)
+}
+
+// ReapJobs kills jobs running past their deadline and removes finished jobs
+// started more than JobRetention ago.
+func ReapJobs(ctx context.Context, rt runtime.Runtime, now time.Time) error {
+	jobs, err := ListJobs(ctx, rt, "")
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		switch {
+		case job.Running() && !job.Deadline.IsZero() && now.After(job.Deadline):
+			if err := KillJob(ctx, rt, job.ID); err != nil {
+				return errors.Wrapf(err, "unable to kill job: %s", job.ID)
+			}
+		case !job.Running() && now.Sub(job.Created) > JobRetention:
+			if err := rt.Remove(ctx, job.ID); err != nil {
+				return errors.Wrapf(err, "unable to remove job: %s", job.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func newJob(c *runtime.ContainerInfo) *Job {
+	return &Job{
+		ID:       shortID(c.ID),
+		User:     c.Labels[LabelJobUser],
+		Cmd:      c.Labels[LabelJobCmd],
+		State:    c.State,
+		ExitCode: c.ExitCode,
+		Created:  c.Created,
+		Deadline: parseDeadline(c.Labels[LabelJobDeadline]),
+	}
+}
+
+// shortID abbreviates full Docker container IDs like the Docker CLI. IDs of
+// other runtimes are kept as is.
+func shortID(id string) string {
+	if len(id) == 64 {
+		return id[:12]
+	}
+	return id
+}
+
+func parseDeadline(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
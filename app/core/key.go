@@ -0,0 +1,48 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Key is an SSH public key a user may authenticate with, stored in the
+// authorized_keys format.
+type Key struct {
+	User        string
+	Fingerprint string
+	Key         string
+	Comment     string `dynamodbav:",omitempty"`
+	Created     time.Time
+}
+
+// NewKey parses an authorized_keys line as a key for user.
+func NewKey(user string, line []byte) (*Key, error) {
+	pk, comment, _, _, err := ssh.ParseAuthorizedKey(line)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		User:        user,
+		Fingerprint: gossh.FingerprintSHA256(pk),
+		Key:         strings.TrimSpace(string(gossh.MarshalAuthorizedKey(pk))),
+		Comment:     comment,
+		Created:     time.Now().UTC(),
+	}, nil
+}
+
+func (k *Key) Validate() error {
+	if k.User == "" || k.Fingerprint == "" || k.Key == "" {
+		return errors.New("key User, Fingerprint and Key required")
+	}
+	return nil
+}
+
+// PublicKey parses the key.
+func (k *Key) PublicKey() (ssh.PublicKey, error) {
+	pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.Key))
+	return pk, err
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// Revision actions recorded for changes to a command
+const (
+	RevisionCreate   = "create"
+	RevisionEdit     = "edit"
+	RevisionEnv      = "env"
+	RevisionRollback = "rollback"
+)
+
+// Revision is an immutable record of a change made to a command. Environment
+// values are secret so only the set of keys is recorded.
+type Revision struct {
+	Cmd      string // key of the command, see RevisionKey
+	Revision int
+	Action   string
+	Source   string
+	EnvKeys  []string `dynamodbav:",stringset,omitempty"`
+	Author   string
+	Created  time.Time
+}
+
+// RevisionKey returns the key revisions of a command are stored under.
+func RevisionKey(user, name string) string {
+	return user + "/" + name
+}
+
+// NewRevision returns a snapshot of the command as changed by author. The
+// revision number is assigned by the store when recorded.
+func (c *Command) NewRevision(action, author string) *Revision {
+	var keys []string
+	for k := range c.Environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return &Revision{
+		Cmd:     RevisionKey(c.User, c.Name),
+		Action:  action,
+		Source:  c.Source,
+		EnvKeys: keys,
+		Author:  author,
+		Created: time.Now().UTC(),
+	}
+}
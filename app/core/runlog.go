@@ -0,0 +1,160 @@
+package core
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+)
+
+// Ways a command can be invoked
+const (
+	RunViaSSH     = "ssh"
+	RunViaHTTP    = "http"
+	RunViaGit     = "git"
+	RunViaWebhook = "webhook"
+)
+
+// MaxRunOutput is the number of bytes kept from the end of each of stdout and
+// stderr of a recorded run.
+var MaxRunOutput = 8192
+
+// RunRecord is the history of a single invocation of a command.
+type RunRecord struct {
+	Cmd      string // key of the command, see RevisionKey
+	ID       string
+	Caller   string
+	Via      string
+	Args     []string `dynamodbav:",omitempty"`
+	Started  time.Time
+	Ended    time.Time
+	Status   int
+	BytesIn  int64
+	BytesOut int64
+	Stdout   string `dynamodbav:",omitempty"`
+	Stderr   string `dynamodbav:",omitempty"`
+}
+
+// Duration of the run.
+func (r *RunRecord) Duration() time.Duration {
+	return r.Ended.Sub(r.Started)
+}
+
+// newRunID returns an ID for a run started at t. IDs sort in the order runs
+// were started.
+func newRunID(t time.Time) string {
+	b := make([]byte, 2)
+	rand.Read(b)
+	return fmt.Sprintf("%016x%x", t.UnixNano(), b)
+}
+
+// RunRecorder is a session recording the run of a command. Input and output
+// are counted and the tail of output is captured as it passes through.
+type RunRecorder struct {
+	ssh.Session
+	record   *RunRecord
+	stdout   *TailBuffer
+	stderr   *TailBuffer
+	bytesIn  int64
+	bytesOut int64
+}
+
+// NewRunRecorder returns a session recording a run of cmd with args by the
+// user of sess via the given means.
+func NewRunRecorder(cmd *Command, sess ssh.Session, via string, args []string) *RunRecorder {
+	now := time.Now().UTC()
+	return &RunRecorder{
+		Session: sess,
+		record: &RunRecord{
+			Cmd:     RevisionKey(cmd.User, cmd.Name),
+			ID:      newRunID(now),
+			Caller:  caller(sess),
+			Via:     via,
+			Args:    append([]string(nil), args...),
+			Started: now,
+		},
+		stdout: &TailBuffer{Max: MaxRunOutput},
+		stderr: &TailBuffer{Max: MaxRunOutput},
+	}
+}
+
+// caller returns who is running a command in sess. Tokens are recorded by
+// their owner and ID, since the key is a secret.
+func caller(sess ssh.Session) string {
+	if token := ContextToken(sess.Context()); token != nil {
+		return fmt.Sprintf("%s (token %s)", token.User, token.ID())
+	}
+	return sess.User()
+}
+
+func (r *RunRecorder) Read(p []byte) (int, error) {
+	n, err := r.Session.Read(p)
+	atomic.AddInt64(&r.bytesIn, int64(n))
+	return n, err
+}
+
+func (r *RunRecorder) Write(p []byte) (int, error) {
+	n, err := r.Session.Write(p)
+	atomic.AddInt64(&r.bytesOut, int64(n))
+	r.stdout.Write(p[:n])
+	return n, err
+}
+
+func (r *RunRecorder) Stderr() io.ReadWriter {
+	stderr := r.Session.Stderr()
+	return &struct {
+		io.Reader
+		io.Writer
+	}{stderr, writerFunc(func(p []byte) (int, error) {
+		n, err := stderr.Write(p)
+		atomic.AddInt64(&r.bytesOut, int64(n))
+		r.stderr.Write(p[:n])
+		return n, err
+	})}
+}
+
+// Finish returns the record of the run once it has exited with status.
+func (r *RunRecorder) Finish(status int) *RunRecord {
+	record := *r.record
+	record.Ended = time.Now().UTC()
+	record.Status = status
+	record.BytesIn = atomic.LoadInt64(&r.bytesIn)
+	record.BytesOut = atomic.LoadInt64(&r.bytesOut)
+	record.Stdout = r.stdout.String()
+	record.Stderr = r.stderr.String()
+	return &record
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (fn writerFunc) Write(p []byte) (int, error) {
+	return fn(p)
+}
+
+// TailBuffer keeps the last Max bytes written to it.
+type TailBuffer struct {
+	Max int
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *TailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.Max {
+		b.buf = append([]byte(nil), b.buf[len(b.buf)-b.Max:]...)
+	}
+	return len(p), nil
+}
+
+func (b *TailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
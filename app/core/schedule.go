@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// MinScheduleInterval is the shortest time allowed between scheduled runs.
+var MinScheduleInterval = time.Minute
+
+// Schedule runs a command with args at times given by a cron spec. Only the
+// result of the most recent run is kept.
+type Schedule struct {
+	ID      string
+	User    string // user who created the schedule
+	Owner   string // owner of the command
+	Cmd     string // name of the command
+	Spec    string
+	Args    []string `dynamodbav:",omitempty"`
+	Created time.Time
+	NextRun time.Time
+
+	LastRun    time.Time
+	LastStatus int
+	LastOutput string `dynamodbav:",omitempty"`
+}
+
+// Validate the schedule and its spec.
+func (s *Schedule) Validate() error {
+	if s.ID == "" {
+		return fmt.Errorf("schedule ID required")
+	}
+	if s.User == "" || s.Owner == "" || s.Cmd == "" {
+		return fmt.Errorf("schedule User, Owner and Cmd required")
+	}
+	_, err := ParseSchedule(s.Spec)
+	return err
+}
+
+// Next sets NextRun to the first time after t the schedule is due.
+func (s *Schedule) Next(t time.Time) error {
+	sched, err := ParseSchedule(s.Spec)
+	if err != nil {
+		return err
+	}
+	s.NextRun = sched.Next(t).UTC()
+	return nil
+}
+
+// Due returns true if the schedule should run at t.
+func (s *Schedule) Due(t time.Time) bool {
+	return !s.NextRun.IsZero() && !t.Before(s.NextRun)
+}
+
+// ParseSchedule parses a standard 5 field cron spec or descriptor such as
+// "@hourly", rejecting specs that run more often than MinScheduleInterval.
+func ParseSchedule(spec string) (cron.Schedule, error) {
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %s", spec, err)
+	}
+	next := sched.Next(time.Now())
+	if next.IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q: never runs", spec)
+	}
+	if sched.Next(next).Sub(next) < MinScheduleInterval {
+		return nil, fmt.Errorf("invalid schedule %q: runs more than once every %s",
+			spec, MinScheduleInterval)
+	}
+	return sched, nil
+}
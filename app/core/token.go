@@ -0,0 +1,207 @@
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Errors returned when a token is used outside of its scope
+var (
+	ErrTokenExpired   = errors.New("token expired")
+	ErrTokenIPDenied  = errors.New("token not allowed from this address")
+	ErrTokenCmdDenied = errors.New("token not allowed to run this command")
+)
+
+// IsTokenKey returns true if subject is the key of a token rather than a
+// user.
+func IsTokenKey(subject string) bool {
+	return uuid.FromStringOrNil(subject) != uuid.Nil
+}
+
+// Token used to provide access to non-github users. Tokens can be scoped
+// with an expiry, the commands they may run, the addresses they may be used
+// from and whether commands receive stdin. Empty scopes are unrestricted.
+type Token struct {
+	Key         string
+	Description string
+	User        string
+	LastUsedIP  string
+	LastUsedOn  time.Time
+	Uses        int64
+	Created     time.Time
+	ExpiresOn   time.Time
+	Cmds        []string `dynamodbav:",stringset,omitempty"` // names or owner/name
+	CIDRs       []string `dynamodbav:",stringset,omitempty"`
+	NoStdin     bool
+}
+
+func (t *Token) Validate() error {
+	if t.Key == "" {
+		return fmt.Errorf("token Key required")
+	}
+
+	if t.User == "" {
+		return fmt.Errorf("token User required")
+	}
+	for _, cidr := range t.CIDRs {
+		if parseCIDR(cidr) == nil {
+			return fmt.Errorf("token CIDR invalid: %s", cidr)
+		}
+	}
+	return nil
+}
+
+// UnusedFor returns true if the token has not been used within d. Tokens
+// never used count from when they were created.
+func (t *Token) UnusedFor(d time.Duration) bool {
+	last := t.LastUsedOn
+	if last.IsZero() {
+		last = t.Created
+	}
+	return time.Since(last) > d
+}
+
+// Expired returns true if the token has an expiry that has passed.
+func (t *Token) Expired() bool {
+	return !t.ExpiresOn.IsZero() && time.Now().After(t.ExpiresOn)
+}
+
+// AllowsIP returns true if the token may be used from ip.
+func (t *Token) AllowsIP(ip net.IP) bool {
+	if len(t.CIDRs) == 0 {
+		return true
+	}
+	for _, cidr := range t.CIDRs {
+		if n := parseCIDR(cidr); n != nil && ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsCmd returns true if the token may run the command name of owner.
+// Commands scoped by name alone are commands of the token user.
+func (t *Token) AllowsCmd(owner, name string) bool {
+	if len(t.Cmds) == 0 {
+		return true
+	}
+	for _, cmd := range t.Cmds {
+		if cmd == owner+"/"+name || (cmd == name && owner == t.User) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize returns an error if the token has expired or may not be used
+// from ip.
+func (t *Token) Authorize(ip net.IP) error {
+	if t.Expired() {
+		return ErrTokenExpired
+	}
+	if !t.AllowsIP(ip) {
+		return ErrTokenIPDenied
+	}
+	return nil
+}
+
+// Scope describes the restrictions of the token.
+func (t *Token) Scope() string {
+	var scope []string
+	if !t.ExpiresOn.IsZero() {
+		scope = append(scope, "expires "+t.ExpiresOn.Format(time.RFC3339))
+	}
+	if len(t.Cmds) > 0 {
+		scope = append(scope, "cmds "+strings.Join(t.Cmds, ","))
+	}
+	if len(t.CIDRs) > 0 {
+		scope = append(scope, "from "+strings.Join(t.CIDRs, ","))
+	}
+	if t.NoStdin {
+		scope = append(scope, "no stdin")
+	}
+	return strings.Join(scope, "; ")
+}
+
+// AddrIP returns the IP of a network address, or nil if it has none.
+func AddrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	case nil:
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
+
+// parseCIDR parses a CIDR or a single IP address as a network.
+func parseCIDR(s string) *net.IPNet {
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+}
+
+// ContextToken returns the token a session authenticated with, if any.
+func ContextToken(ctx context.Context) *Token {
+	token, _ := ctx.Value("token").(*Token)
+	return token
+}
+
+// WithoutStdin returns sess with stdin always at EOF.
+func WithoutStdin(sess ssh.Session) ssh.Session {
+	return &noStdinSession{sess}
+}
+
+type noStdinSession struct {
+	ssh.Session
+}
+
+func (s *noStdinSession) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+// ID returns the public identifier of the token, used with its signing
+// secret to sign requests without sending the key.
+func (t *Token) ID() string {
+	return t.derive("id")[:16]
+}
+
+// SigningSecret returns the secret used to sign requests with the token.
+// The key can't be recovered from it.
+func (t *Token) SigningSecret() string {
+	return t.derive("signing")
+}
+
+func (t *Token) derive(purpose string) string {
+	mac := hmac.New(sha256.New, []byte(t.Key))
+	mac.Write([]byte("cmd.io token " + purpose))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,31 @@
+package core
+
+import (
+	"fmt"
+)
+
+// Webhook providers, which determine how deliveries are verified
+const (
+	WebhookGitHub  = "github"
+	WebhookStripe  = "stripe"
+	WebhookGeneric = "generic"
+)
+
+// Webhook configures a command to receive webhook deliveries. Verified
+// deliveries run the command with the payload as stdin.
+type Webhook struct {
+	Provider string
+	Secret   string // encrypted, see crypto.Encrypt
+}
+
+func (w *Webhook) Validate() error {
+	switch w.Provider {
+	case WebhookGitHub, WebhookStripe, WebhookGeneric:
+	default:
+		return fmt.Errorf("unsupported webhook provider: %s", w.Provider)
+	}
+	if w.Secret == "" {
+		return fmt.Errorf("webhook Secret required")
+	}
+	return nil
+}
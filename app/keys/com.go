@@ -0,0 +1,21 @@
+package keys
+
+import (
+	"github.com/gliderlabs/comlab/pkg/com"
+)
+
+func init() {
+	com.Register("keys", &Component{},
+		com.Option("github_url", "https://github.com", "GitHub URL to look up user keys, empty to disable"),
+		com.Option("github_prefix", "", "prefix of cmd users that are GitHub users"),
+		com.Option("gitlab_url", "", "GitLab URL to look up user keys, empty to disable"),
+		com.Option("gitlab_prefix", "gitlab.", "prefix of cmd users that are GitLab users"),
+		com.Option("authorized_keys", "", "path to authorized_keys file with the user of each key as its comment"))
+	com.Register("keys.github", &GitHub{})
+	com.Register("keys.gitlab", &GitLab{})
+	com.Register("keys.file", &File{})
+	com.Register("keys.store", &Store{})
+}
+
+// Component holds options for the key providers.
+type Component struct{}
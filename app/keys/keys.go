@@ -0,0 +1,166 @@
+// Package keys provides the SSH public keys users authenticate with. Each
+// provider is a component that can be disabled, and the keys of all enabled
+// providers are accepted. GitHub and GitLab users are namespaced by a prefix
+// so each cmd user is owned by at most one of them.
+package keys
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/log"
+	"github.com/gliderlabs/ssh"
+
+	"github.com/gliderlabs/cmd/app/store"
+)
+
+// GitHub provides the keys of GitHub users from <github_url>/<user>.keys for
+// cmd users <github_prefix><user>.
+type GitHub struct{}
+
+func (p *GitHub) UserKeys(user string) ([]ssh.PublicKey, error) {
+	name, ok := remoteUser(remotes(), "github", user)
+	if !ok {
+		return nil, nil
+	}
+	return fetchKeys(com.GetString("github_url"), name)
+}
+
+// GitLab provides the keys of GitLab users from <gitlab_url>/<user>.keys for
+// cmd users <gitlab_prefix><user>.
+type GitLab struct{}
+
+func (p *GitLab) UserKeys(user string) ([]ssh.PublicKey, error) {
+	name, ok := remoteUser(remotes(), "gitlab", user)
+	if !ok {
+		return nil, nil
+	}
+	return fetchKeys(com.GetString("gitlab_url"), name)
+}
+
+// remote is a site users are looked up on, owning the cmd users with its
+// prefix.
+type remote struct {
+	name   string
+	prefix string
+}
+
+// remotes returns the configured sites with a URL.
+func remotes() []remote {
+	var sites []remote
+	if com.GetString("github_url") != "" {
+		sites = append(sites, remote{"github", com.GetString("github_prefix")})
+	}
+	if com.GetString("gitlab_url") != "" {
+		sites = append(sites, remote{"gitlab", com.GetString("gitlab_prefix")})
+	}
+	return sites
+}
+
+// remoteUser returns the name on site of cmd user, and whether site owns
+// user. A user is owned by the site with the longest matching prefix, so the
+// same name on two sites can't log in as the same cmd user.
+func remoteUser(sites []remote, site, user string) (string, bool) {
+	owner := -1
+	for i, r := range sites {
+		if !strings.HasPrefix(user, r.prefix) {
+			continue
+		}
+		if owner < 0 || len(r.prefix) > len(sites[owner].prefix) {
+			owner = i
+		}
+	}
+	if owner < 0 || sites[owner].name != site {
+		return "", false
+	}
+	return strings.TrimPrefix(user, sites[owner].prefix), true
+}
+
+// File provides keys from an authorized_keys file, where the comment of each
+// key is the user it belongs to.
+type File struct{}
+
+func (p *File) UserKeys(user string) ([]ssh.PublicKey, error) {
+	path := com.GetString("authorized_keys")
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseKeys(f, func(comment string) bool {
+		return comment == user
+	}), nil
+}
+
+// Store provides keys added with the :keys builtin.
+type Store struct{}
+
+func (p *Store) UserKeys(user string) ([]ssh.PublicKey, error) {
+	keys, err := store.Selected().ListKeys(user)
+	if err != nil {
+		return nil, err
+	}
+	var pks []ssh.PublicKey
+	for _, key := range keys {
+		pk, err := key.PublicKey()
+		if err != nil {
+			log.Info(user, err)
+			continue
+		}
+		pks = append(pks, pk)
+	}
+	return pks, nil
+}
+
+// fetchKeys gets the keys of user from a GitHub style <baseURL>/<user>.keys
+// endpoint. Unknown users have no keys.
+func fetchKeys(baseURL, user string) ([]ssh.PublicKey, error) {
+	if baseURL == "" || user == "" {
+		return nil, nil
+	}
+	resp, err := http.Get(fmt.Sprintf("%s/%s.keys",
+		strings.TrimSuffix(baseURL, "/"), url.PathEscape(user)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("keys request to %s failed: %s", baseURL, resp.Status)
+	}
+	return parseKeys(resp.Body, nil), nil
+}
+
+// parseKeys parses authorized_keys lines, keeping keys with comments
+// matching filter if not nil.
+func parseKeys(r io.Reader, filter func(comment string) bool) []ssh.PublicKey {
+	var keys []ssh.PublicKey
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			log.Info(err)
+			continue
+		}
+		if filter != nil && !filter(comment) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
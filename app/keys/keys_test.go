@@ -0,0 +1,96 @@
+package keys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	aliceKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIEQHcbZocqKZjWeDb5r7hKDGxP88IuBzN3B59v23CxU2 alice"
+	bobKey   = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIELCQqqKHg90gNspCB4Tl52Jj6hJqBhpo2tBHJZ9C9r5 bob"
+)
+
+func parseKey(t *testing.T, line string) ssh.PublicKey {
+	k, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestFetchKeys(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/alice.keys":
+			w.Write([]byte(aliceKey + "\n" + bobKey + "\n"))
+		case "/broken.keys":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	keys, err := fetchKeys(srv.URL, "alice")
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.True(t, ssh.KeysEqual(parseKey(t, aliceKey), keys[0]))
+	}
+
+	keys, err = fetchKeys(srv.URL+"/", "missing")
+	assert.NoError(t, err, "Unknown users should have no keys")
+	assert.Empty(t, keys)
+
+	_, err = fetchKeys(srv.URL, "broken")
+	assert.Error(t, err)
+
+	keys, err = fetchKeys("", "alice")
+	assert.NoError(t, err, "Providers without a URL should have no keys")
+	assert.Empty(t, keys)
+}
+
+func TestParseKeys(t *testing.T) {
+	file := strings.Join([]string{
+		"# comment",
+		aliceKey,
+		"",
+		"not a key",
+		bobKey,
+	}, "\n")
+	keys := parseKeys(strings.NewReader(file), func(comment string) bool {
+		return comment == "bob"
+	})
+	if assert.Len(t, keys, 1) {
+		assert.True(t, ssh.KeysEqual(parseKey(t, bobKey), keys[0]))
+	}
+	assert.Len(t, parseKeys(strings.NewReader(file), nil), 2)
+}
+
+func TestRemoteUser(t *testing.T) {
+	sites := []remote{{"github", ""}, {"gitlab", "gitlab."}}
+
+	name, ok := remoteUser(sites, "github", "alice")
+	assert.True(t, ok)
+	assert.Equal(t, "alice", name)
+	_, ok = remoteUser(sites, "gitlab", "alice")
+	assert.False(t, ok, "GitLab users should not log in as GitHub users")
+
+	name, ok = remoteUser(sites, "gitlab", "gitlab.alice")
+	assert.True(t, ok)
+	assert.Equal(t, "alice", name)
+	_, ok = remoteUser(sites, "github", "gitlab.alice")
+	assert.False(t, ok, "GitHub users should not log in as GitLab users")
+
+	name, ok = remoteUser(sites[1:], "gitlab", "gitlab.alice")
+	assert.True(t, ok)
+	assert.Equal(t, "alice", name)
+	_, ok = remoteUser(sites[1:], "gitlab", "alice")
+	assert.False(t, ok, "Users without the prefix should not be owned")
+	_, ok = remoteUser(nil, "github", "alice")
+	assert.False(t, ok, "Disabled sites should own no users")
+}
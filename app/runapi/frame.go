@@ -0,0 +1,98 @@
+package runapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// FramedProtocol is the WebSocket subprotocol clients request to receive
+// output as frames tagged with their channel rather than plain messages.
+const FramedProtocol = "cmd.io.framed.v1"
+
+// Frame channels
+const (
+	ChannelStdin  = "stdin"
+	ChannelStdout = "stdout"
+	ChannelStderr = "stderr"
+	ChannelExit   = "exit"
+	ChannelResize = "resize"
+)
+
+// Frame is a message of the framed protocol used over WebSocket and NDJSON
+// streams. Data is set for stdin, stdout and stderr, Status for exit, and
+// Width and Height for resize. Data is base64 encoded in JSON so output that
+// isn't valid UTF-8 is sent unchanged.
+type Frame struct {
+	Channel string `json:"channel"`
+	Data    []byte `json:"data,omitempty"`
+	Status  *int   `json:"status,omitempty"`
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+}
+
+type frameWriter interface {
+	WriteFrame(f Frame) error
+}
+
+// channelWriter writes data as frames for a channel.
+type channelWriter struct {
+	frames  frameWriter
+	channel string
+}
+
+func (cw *channelWriter) Write(p []byte) (int, error) {
+	if err := cw.frames.WriteFrame(Frame{Channel: cw.channel, Data: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// wsFramedWriter writes output as WebSocket frames, ending with an exit frame
+// before the close frame.
+type wsFramedWriter struct {
+	*gorillaWSAdapter
+}
+
+func (fw *wsFramedWriter) Write(p []byte) (int, error) {
+	return (&channelWriter{fw, ChannelStdout}).Write(p)
+}
+
+func (fw *wsFramedWriter) Stderr() *channelWriter {
+	return &channelWriter{fw, ChannelStderr}
+}
+
+func (fw *wsFramedWriter) WriteExit(status int) error {
+	if err := fw.WriteFrame(Frame{Channel: ChannelExit, Status: &status}); err != nil {
+		return err
+	}
+	return fw.gorillaWSAdapter.WriteExit(status)
+}
+
+// ndjsonWriter streams output as newline delimited JSON frames, ending with
+// an exit frame. The exit status is also sent as a trailer.
+type ndjsonWriter struct {
+	sync.Mutex
+	*flushWriter
+	header http.Header
+}
+
+func (nw *ndjsonWriter) WriteFrame(f Frame) error {
+	nw.Lock()
+	defer nw.Unlock()
+	return json.NewEncoder(nw.flushWriter).Encode(f)
+}
+
+func (nw *ndjsonWriter) Write(p []byte) (int, error) {
+	return (&channelWriter{nw, ChannelStdout}).Write(p)
+}
+
+func (nw *ndjsonWriter) Stderr() *channelWriter {
+	return &channelWriter{nw, ChannelStderr}
+}
+
+func (nw *ndjsonWriter) WriteExit(status int) error {
+	nw.header.Set(ExitStatusHeader, strconv.Itoa(status))
+	return nw.WriteFrame(Frame{Channel: ChannelExit, Status: &status})
+}
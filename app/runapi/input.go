@@ -0,0 +1,201 @@
+package runapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/gorilla/websocket"
+)
+
+// Default terminal for WebSocket sessions with a TTY
+const (
+	defaultTerm   = "xterm"
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// parsePty returns the PTY requested with the tty query parameter, sized
+// by the cols and rows query parameters.
+func parsePty(r *http.Request) (*ssh.Pty, bool) {
+	q := r.URL.Query()
+	if _, ok := q["tty"]; !ok {
+		return nil, false
+	}
+	pty := &ssh.Pty{
+		Term:   defaultTerm,
+		Window: ssh.Window{Width: defaultWidth, Height: defaultHeight},
+	}
+	if term := q.Get("term"); term != "" {
+		pty.Term = term
+	}
+	if cols, err := strconv.Atoi(q.Get("cols")); err == nil && cols > 0 {
+		pty.Window.Width = cols
+	}
+	if rows, err := strconv.Atoi(q.Get("rows")); err == nil && rows > 0 {
+		pty.Window.Height = rows
+	}
+	return pty, true
+}
+
+// maxStdinQueue is the number of bytes of stdin queued for a command before
+// the WebSocket is closed, since reading messages never waits for the
+// command to read stdin.
+var maxStdinQueue = 1 << 20
+
+// wsInput reads stdin and resize messages from a WebSocket. Without framing
+// every message is stdin. With framing stdin and resize frames are accepted
+// and an empty stdin frame ends stdin. Stdin is queued and written
+// separately, so resize and close frames are read while the command is not
+// reading stdin.
+type wsInput struct {
+	conn   *websocket.Conn
+	framed bool
+	stdin  *io.PipeWriter
+	winch  chan ssh.Window
+
+	mu     sync.Mutex
+	queue  [][]byte // nil ends stdin
+	queued int
+	closed bool
+	ready  chan struct{}
+}
+
+// newWSInput returns a wsInput for conn and the reader of its stdin.
+func newWSInput(conn *websocket.Conn, framed bool) (*wsInput, io.ReadCloser) {
+	pr, pw := io.Pipe()
+	return &wsInput{
+		conn:   conn,
+		framed: framed,
+		stdin:  pw,
+		winch:  make(chan ssh.Window, 1),
+		ready:  make(chan struct{}, 1),
+	}, pr
+}
+
+// serve reads messages until the connection is closed, which ends stdin once
+// queued stdin is written. The connection is closed if more than
+// maxStdinQueue bytes of stdin are waiting to be read.
+func (in *wsInput) serve() {
+	go in.writeStdin()
+	defer close(in.winch)
+	defer in.close()
+	for {
+		_, msg, err := in.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		data := msg
+		if in.framed {
+			var f Frame
+			if err := json.Unmarshal(msg, &f); err != nil {
+				continue
+			}
+			switch f.Channel {
+			case ChannelStdin:
+				// an empty frame ends stdin
+				data = f.Data
+				if len(data) == 0 {
+					data = nil
+				}
+			case ChannelResize:
+				if f.Width > 0 && f.Height > 0 {
+					in.resize(ssh.Window{Width: f.Width, Height: f.Height})
+				}
+				continue
+			default:
+				continue
+			}
+		}
+		if !in.push(data) {
+			in.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "stdin not read"),
+				time.Now().Add(time.Second))
+			return
+		}
+	}
+}
+
+// push queues p for stdin, returning false if the queue is full.
+func (in *wsInput) push(p []byte) bool {
+	in.mu.Lock()
+	if in.queued+len(p) > maxStdinQueue {
+		in.mu.Unlock()
+		return false
+	}
+	in.queue = append(in.queue, p)
+	in.queued += len(p)
+	in.mu.Unlock()
+	in.signal()
+	return true
+}
+
+// close ends stdin once the queue is written.
+func (in *wsInput) close() {
+	in.mu.Lock()
+	in.closed = true
+	in.mu.Unlock()
+	in.signal()
+}
+
+func (in *wsInput) signal() {
+	select {
+	case in.ready <- struct{}{}:
+	default:
+	}
+}
+
+// writeStdin writes queued stdin until the queue is closed.
+func (in *wsInput) writeStdin() {
+	defer in.stdin.Close()
+	for {
+		in.mu.Lock()
+		for len(in.queue) == 0 && !in.closed {
+			in.mu.Unlock()
+			<-in.ready
+			in.mu.Lock()
+		}
+		if len(in.queue) == 0 {
+			in.mu.Unlock()
+			return
+		}
+		p := in.queue[0]
+		in.queue = in.queue[1:]
+		in.queued -= len(p)
+		in.mu.Unlock()
+		if p == nil {
+			in.stdin.Close()
+			continue
+		}
+		in.stdin.Write(p)
+	}
+}
+
+// discardInput reads and discards messages until conn is closed, so control
+// frames are handled for connections without stdin.
+func discardInput(conn *websocket.Conn) {
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+// resize queues win replacing any size not yet applied.
+func (in *wsInput) resize(win ssh.Window) {
+	for {
+		select {
+		case in.winch <- win:
+			return
+		default:
+		}
+		select {
+		case <-in.winch:
+		default:
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package runapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePty(t *testing.T) {
+	r := httptest.NewRequest("GET", "/run/user/cmd", nil)
+	_, isPty := parsePty(r)
+	assert.False(t, isPty)
+
+	r = httptest.NewRequest("GET", "/run/user/cmd?tty", nil)
+	pty, isPty := parsePty(r)
+	if assert.True(t, isPty) {
+		assert.Equal(t, defaultTerm, pty.Term)
+		assert.Equal(t, ssh.Window{Width: defaultWidth, Height: defaultHeight}, pty.Window)
+	}
+
+	r = httptest.NewRequest("GET", "/run/user/cmd?tty&term=xterm-256color&cols=120&rows=40", nil)
+	pty, isPty = parsePty(r)
+	if assert.True(t, isPty) {
+		assert.Equal(t, "xterm-256color", pty.Term)
+		assert.Equal(t, ssh.Window{Width: 120, Height: 40}, pty.Window)
+	}
+}
+
+// serveInput starts a WebSocket server reading input with wsInput and
+// returns a connected client.
+func serveInput(t *testing.T, framed bool, done func(stdin string, windows []ssh.Window)) (*websocket.Conn, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		input, stdin := newWSInput(conn, framed)
+		go input.serve()
+		b, _ := ioutil.ReadAll(stdin)
+		var windows []ssh.Window
+		if framed {
+			windows = append(windows, <-input.winch)
+		}
+		done(string(b), windows)
+	}))
+	dialer := &websocket.Dialer{}
+	if framed {
+		dialer.Subprotocols = []string{FramedProtocol}
+	}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return conn, func() {
+		conn.Close()
+		srv.Close()
+	}
+}
+
+func TestWSInput(t *testing.T) {
+	t.Run("Framed", func(t *testing.T) {
+		result := make(chan string, 1)
+		var windows []ssh.Window
+		conn, cleanup := serveInput(t, true, func(stdin string, w []ssh.Window) {
+			windows = w
+			result <- stdin
+		})
+		defer cleanup()
+		conn.WriteJSON(Frame{Channel: ChannelResize, Width: 100, Height: 30})
+		conn.WriteJSON(Frame{Channel: ChannelStdin, Data: []byte("hello ")})
+		conn.WriteJSON(Frame{Channel: ChannelStdin, Data: []byte("world")})
+		conn.WriteJSON(Frame{Channel: ChannelStdin})
+		assert.Equal(t, "hello world", <-result)
+		assert.Equal(t, []ssh.Window{{Width: 100, Height: 30}}, windows)
+	})
+
+	t.Run("Unframed", func(t *testing.T) {
+		result := make(chan string, 1)
+		conn, cleanup := serveInput(t, false, func(stdin string, _ []ssh.Window) {
+			result <- stdin
+		})
+		defer cleanup()
+		conn.WriteMessage(websocket.TextMessage, []byte("hello "))
+		conn.WriteMessage(websocket.BinaryMessage, []byte("world"))
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		assert.Equal(t, "hello world", <-result)
+	})
+}
+
+func TestWSInputUnread(t *testing.T) {
+	windows := make(chan ssh.Window, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		input, _ := newWSInput(conn, true)
+		go input.serve()
+		// stdin is never read
+		for win := range input.winch {
+			windows <- win
+		}
+		close(windows)
+	}))
+	defer srv.Close()
+	dialer := &websocket.Dialer{Subprotocols: []string{FramedProtocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(Frame{Channel: ChannelStdin, Data: []byte("unread")})
+	conn.WriteJSON(Frame{Channel: ChannelStdin, Data: []byte("unread")})
+	conn.WriteJSON(Frame{Channel: ChannelResize, Width: 100, Height: 30})
+	assert.Equal(t, ssh.Window{Width: 100, Height: 30}, <-windows,
+		"Resize should not wait for stdin to be read")
+	conn.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	_, ok := <-windows
+	assert.False(t, ok, "Input should end when the connection is closed")
+}
+
+func TestDiscardInput(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		discardInput(conn)
+		close(done)
+	}))
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	conn.WriteMessage(websocket.TextMessage, []byte("ignored"))
+	conn.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure),
+		"Close should be answered: %v", err)
+	<-done
+}
+
+func TestWSInputResize(t *testing.T) {
+	in := &wsInput{winch: make(chan ssh.Window, 1)}
+	in.resize(ssh.Window{Width: 80, Height: 24})
+	in.resize(ssh.Window{Width: 120, Height: 40})
+	assert.Equal(t, ssh.Window{Width: 120, Height: 40}, <-in.winch,
+		"Only the latest size should be queued")
+}
+
+func TestWSInputFull(t *testing.T) {
+	defer func(max int) { maxStdinQueue = max }(maxStdinQueue)
+	maxStdinQueue = 8
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		input, _ := newWSInput(conn, false)
+		// stdin is never read
+		input.serve()
+	}))
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	conn.WriteMessage(websocket.TextMessage, []byte("unread"))
+	conn.WriteMessage(websocket.TextMessage, []byte("unread"))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation),
+		"Connection should be closed once queued stdin is full")
+}
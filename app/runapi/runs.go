@@ -0,0 +1,58 @@
+package runapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gliderlabs/cmd/app/store"
+)
+
+const runsPrefix = "/runs/"
+
+// serveRuns lists the run history of a command at /runs/<owner>/<cmd>, or
+// returns a single run at /runs/<owner>/<cmd>/<id>. The token must be an
+// admin of the command and allowed to run it.
+func (c *Component) serveRuns(w http.ResponseWriter, r *http.Request) {
+	token := lookupToken(r)
+	if token == nil || token.Authorize(remoteIP(r)) != nil {
+		http.Error(w, "unauthorized token", http.StatusUnauthorized)
+		return
+	}
+	store.RecordTokenUse(token.Key, remoteIP(r))
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, runsPrefix), "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	cmd := store.Selected().Get(parts[0], parts[1])
+	if cmd == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if !cmd.IsAdmin(token.Key) || !token.AllowsCmd(cmd.User, cmd.Name) {
+		http.Error(w, "unauthorized token", http.StatusUnauthorized)
+		return
+	}
+
+	var v interface{}
+	if len(parts) > 2 && parts[2] != "" {
+		run, err := store.Selected().GetRun(cmd.User, cmd.Name, parts[2])
+		if err != nil || run == nil {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		v = run
+	} else {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		runs, err := store.Selected().ListRuns(cmd.User, cmd.Name, limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		v = runs
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
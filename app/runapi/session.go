@@ -21,16 +21,23 @@ const (
 type httpSession struct {
 	req         *http.Request
 	wc          io.WriteCloser
+	stdin       io.ReadCloser
+	pty         *ssh.Pty
+	winch       <-chan ssh.Window
 	token       string
 	isWebSocket bool
 	ctx         context.Context
 	cmd         []string
+	env         []string
 }
 
 func (sess *httpSession) Write(p []byte) (n int, err error) {
 	return sess.wc.Write(p)
 }
 func (sess *httpSession) Read(data []byte) (int, error) {
+	if sess.stdin != nil {
+		return sess.stdin.Read(data)
+	}
 	return sess.req.Body.Read(data)
 }
 func (sess *httpSession) PublicKey() ssh.PublicKey {
@@ -49,7 +56,7 @@ func (sess *httpSession) User() string {
 	return sess.token
 }
 func (sess *httpSession) RemoteAddr() net.Addr {
-	return &net.IPAddr{IP: net.ParseIP(sess.req.RemoteAddr)}
+	return &net.IPAddr{IP: remoteIP(sess.req)}
 }
 func (sess *httpSession) Environ() []string {
 	if sess.req == nil {
@@ -60,7 +67,7 @@ func (sess *httpSession) Environ() []string {
 	if len(sh) > 1 {
 		port = sh[1]
 	}
-	return []string{
+	return append([]string{
 		fmt.Sprintf("SERVER_NAME=%s", release.Hostname()),
 		fmt.Sprintf("SERVER_PROTOCOL=%s", ServerProtocol),
 		fmt.Sprintf("HTTP_HOST=%s", release.Hostname()),
@@ -73,7 +80,7 @@ func (sess *httpSession) Environ() []string {
 		fmt.Sprintf("SERVER_PORT=%s", port),
 		fmt.Sprintf("CONTENT_TYPE=%s", sess.req.Header.Get("Content-Type")),
 		fmt.Sprintf("CONTENT_LENGTH=%s", strconv.Itoa(int(sess.req.ContentLength))),
-	}
+	}, sess.env...)
 }
 
 func (sess *httpSession) Command() []string {
@@ -81,22 +88,17 @@ func (sess *httpSession) Command() []string {
 }
 
 func (sess *httpSession) Pty() (ssh.Pty, <-chan ssh.Window, bool) {
-	var winch chan ssh.Window
-	//if sess.isWebSocket {
-	if false {
-		win := ssh.Window{Width: 80, Height: 40}
-		winch = make(chan ssh.Window, 1)
-		winch <- win
-		return ssh.Pty{
-			Term:   "xterm",
-			Window: win,
-		}, winch, true
+	if sess.isWebSocket && sess.pty != nil {
+		return *sess.pty, sess.winch, true
 	}
-	return ssh.Pty{}, winch, false
+	return ssh.Pty{}, nil, false
 }
 
 func (sess *httpSession) Close() error {
 	sess.req.Body.Close()
+	if sess.stdin != nil {
+		sess.stdin.Close()
+	}
 	return sess.wc.Close()
 }
 func (sess *httpSession) CloseWrite() error {
@@ -105,9 +107,18 @@ func (sess *httpSession) CloseWrite() error {
 func (sess *httpSession) SendRequest(name string, wantReply bool, payload []byte) (bool, error) {
 	return false, nil
 }
+
+// Stderr is written to the same writer as stdout unless it frames output by
+// channel.
 func (sess *httpSession) Stderr() io.ReadWriter {
+	var stderr io.Writer = sess.wc
+	if fw, ok := sess.wc.(interface {
+		Stderr() *channelWriter
+	}); ok {
+		stderr = fw.Stderr()
+	}
 	return &struct {
 		io.Reader
 		io.Writer
-	}{strings.NewReader(""), sess.wc}
+	}{strings.NewReader(""), stderr}
 }
@@ -0,0 +1,183 @@
+package runapi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+)
+
+// Signed requests carry an Authorization header with the SignatureScheme
+// and the time they were signed in the TimestampHeader.
+//
+//	Authorization: CMD-HMAC-SHA256 Credential=<user>/<token id>, Signature=<hex>
+//	X-Cmd-Timestamp: <unix seconds>
+//
+// The signature is the hex HMAC-SHA256 of the string to sign, keyed with the
+// signing secret of the token. See stringToSign.
+const (
+	SignatureScheme = "CMD-HMAC-SHA256"
+	TimestampHeader = "X-Cmd-Timestamp"
+)
+
+// MaxSignatureAge is how far the timestamp of a signed request may be from
+// the time it is received.
+var MaxSignatureAge = 5 * time.Minute
+
+// MaxSignedBody is the largest body a signed request may have, since the
+// body is read to be hashed before running the command.
+var MaxSignedBody int64 = 10 << 20
+
+// Errors returned for signed requests that can't be verified
+var (
+	ErrSignatureInvalid   = errors.New("invalid signature")
+	ErrSignatureExpired   = errors.New("signature timestamp out of range")
+	ErrSignatureReplayed  = errors.New("signature already used")
+	ErrSignedBodyTooLarge = errors.New("signed request body too large")
+)
+
+// SignRequest signs r with the token identified by user and id using its
+// signing secret, as returned by core.Token.ID and SigningSecret. The body
+// of r is read and replaced.
+func SignRequest(r *http.Request, user, id, secret string, t time.Time) error {
+	body, err := readBody(r, -1)
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(t.Unix(), 10)
+	r.Header.Set(TimestampHeader, ts)
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, Signature=%s",
+		SignatureScheme, user, id, sign(secret, stringToSign(r, ts, body))))
+	return nil
+}
+
+// isSigned returns true if r has a signature to verify rather than a token.
+func isSigned(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Authorization"), SignatureScheme+" ")
+}
+
+// verifySignature returns the token from backend that signed r. The body of
+// r is read and replaced. Signatures are recorded in backend while their
+// timestamp is in range, so a signed request can't be replayed.
+func verifySignature(r *http.Request, backend store.Backend) (*core.Token, error) {
+	user, id, signature, err := parseSignature(r.Header.Get("Authorization"))
+	if err != nil {
+		return nil, err
+	}
+	ts := r.Header.Get(TimestampHeader)
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrSignatureInvalid
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > MaxSignatureAge || age < -MaxSignatureAge {
+		return nil, ErrSignatureExpired
+	}
+	tokens, err := backend.ListTokens(user)
+	if err != nil {
+		return nil, err
+	}
+	var token *core.Token
+	for _, t := range tokens {
+		if hmac.Equal([]byte(t.ID()), []byte(id)) {
+			token = t
+			break
+		}
+	}
+	if token == nil {
+		return nil, ErrSignatureInvalid
+	}
+	body, err := readBody(r, MaxSignedBody)
+	if err != nil {
+		return nil, err
+	}
+	expected := sign(token.SigningSecret(), stringToSign(r, ts, body))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, ErrSignatureInvalid
+	}
+	used, err := backend.UseSignature(signature, time.Unix(unix, 0).Add(MaxSignatureAge))
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrSignatureReplayed
+	}
+	return token, nil
+}
+
+// parseSignature parses the credential and signature of an Authorization
+// header value.
+func parseSignature(auth string) (user, id, signature string, err error) {
+	params := strings.TrimPrefix(auth, SignatureScheme+" ")
+	for _, param := range strings.Split(params, ",") {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Credential":
+			cred := strings.SplitN(kv[1], "/", 2)
+			if len(cred) == 2 {
+				user, id = cred[0], cred[1]
+			}
+		case "Signature":
+			signature = kv[1]
+		}
+	}
+	if user == "" || id == "" || signature == "" {
+		return "", "", "", ErrSignatureInvalid
+	}
+	return user, id, signature, nil
+}
+
+// stringToSign returns the string signed for r: the scheme, method, path,
+// query, timestamp and hex SHA-256 of the body, separated by newlines.
+func stringToSign(r *http.Request, timestamp string, body []byte) string {
+	hash := sha256.Sum256(body)
+	return strings.Join([]string{
+		SignatureScheme,
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		timestamp,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+}
+
+func sign(secret, s string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	io.WriteString(mac, s)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// readBody reads the body of r, up to limit bytes unless limit is negative,
+// and replaces it so it can be read again.
+func readBody(r *http.Request, limit int64) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	var body io.Reader = r.Body
+	if limit >= 0 {
+		body = io.LimitReader(r.Body, limit+1)
+	}
+	b, err := ioutil.ReadAll(body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if limit >= 0 && int64(len(b)) > limit {
+		return nil, ErrSignedBodyTooLarge
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
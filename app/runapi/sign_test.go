@@ -0,0 +1,92 @@
+package runapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store/memory"
+)
+
+func TestVerifySignature(t *testing.T) {
+	backend := &memory.Component{}
+	token := &core.Token{Key: "e0a6f4b6-5b1c-4c5e-9a8e-3f1f7a2c9d10", User: "user"}
+	assert.NoError(t, backend.PutToken(token))
+
+	newRequest := func(body string, at time.Time) (*http.Request, error) {
+		r := httptest.NewRequest("POST", "/run/user/cmd?args=a+b", strings.NewReader(body))
+		err := SignRequest(r, token.User, token.ID(), token.SigningSecret(), at)
+		return r, err
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		req, err := newRequest("input", time.Now())
+		assert.NoError(t, err)
+		assert.True(t, isSigned(req))
+		verified, err := verifySignature(req, backend)
+		assert.NoError(t, err)
+		if assert.NotNil(t, verified) {
+			assert.Equal(t, token.Key, verified.Key)
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, "input", string(body), "Body should be readable after verifying")
+	})
+
+	t.Run("Replayed", func(t *testing.T) {
+		req, _ := newRequest("replayed", time.Now())
+		replay := httptest.NewRequest("POST", req.URL.String(), strings.NewReader("replayed"))
+		replay.Header = req.Header
+		_, err := verifySignature(req, backend)
+		assert.NoError(t, err)
+		_, err = verifySignature(replay, backend)
+		assert.Equal(t, ErrSignatureReplayed, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		req, _ := newRequest("input", time.Now().Add(-2*MaxSignatureAge))
+		_, err := verifySignature(req, backend)
+		assert.Equal(t, ErrSignatureExpired, err)
+	})
+
+	t.Run("TamperedBody", func(t *testing.T) {
+		req, _ := newRequest("input", time.Now())
+		req.Body = ioutil.NopCloser(strings.NewReader("tampered"))
+		_, err := verifySignature(req, backend)
+		assert.Equal(t, ErrSignatureInvalid, err)
+	})
+
+	t.Run("TamperedQuery", func(t *testing.T) {
+		req, _ := newRequest("input", time.Now())
+		req.URL.RawQuery = "args=rm+-rf"
+		_, err := verifySignature(req, backend)
+		assert.Equal(t, ErrSignatureInvalid, err)
+	})
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/run/user/cmd", strings.NewReader("input"))
+		assert.NoError(t, SignRequest(r, token.User, token.ID(), token.Key, time.Now()))
+		_, err := verifySignature(r, backend)
+		assert.Equal(t, ErrSignatureInvalid, err)
+	})
+
+	t.Run("UnknownToken", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/run/user/cmd", strings.NewReader("input"))
+		assert.NoError(t, SignRequest(r, token.User, "unknown", token.SigningSecret(), time.Now()))
+		_, err := verifySignature(r, backend)
+		assert.Equal(t, ErrSignatureInvalid, err)
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		defer func(n int64) { MaxSignedBody = n }(MaxSignedBody)
+		MaxSignedBody = 4
+		req, _ := newRequest("input", time.Now())
+		_, err := verifySignature(req, backend)
+		assert.Equal(t, ErrSignedBodyTooLarge, err)
+	})
+}
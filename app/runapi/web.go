@@ -1,28 +1,44 @@
 package runapi
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/gliderlabs/cmd/app/billing"
 	"github.com/gliderlabs/cmd/app/console"
+	"github.com/gliderlabs/cmd/app/core"
 	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/lib/cli"
+	"github.com/gliderlabs/comlab/pkg/log"
 	"github.com/gorilla/websocket"
 )
 
 const runPrefix = "/run/"
 
+// ExitStatusHeader carries the exit status of a command, as a header for
+// buffered responses or a trailer for streamed responses.
+const ExitStatusHeader = "X-Cmd-Exit-Status"
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 	CheckOrigin:     func(r *http.Request) bool { return true },
+	Subprotocols:    []string{FramedProtocol},
 }
 
 func (c *Component) MatchHTTP(r *http.Request) bool {
-	return strings.HasPrefix(r.URL.Path, runPrefix)
+	return strings.HasPrefix(r.URL.Path, runPrefix) ||
+		strings.HasPrefix(r.URL.Path, runsPrefix) ||
+		strings.HasPrefix(r.URL.Path, hookPrefix)
 }
 
 func parseToken(r *http.Request) string {
@@ -33,6 +49,30 @@ func parseToken(r *http.Request) string {
 	return user
 }
 
+// lookupToken returns the token of a signed request or the token given by
+// the request, or nil if there is none.
+func lookupToken(r *http.Request) *core.Token {
+	if isSigned(r) {
+		token, err := verifySignature(r, store.Selected())
+		if err != nil {
+			log.Info(r, err)
+			return nil
+		}
+		return token
+	}
+	token, _ := store.Selected().GetToken(parseToken(r))
+	return token
+}
+
+// remoteIP returns the IP address of the client making request r.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
 func parseArgs(r *http.Request) (string, string, []string) {
 	path := strings.TrimPrefix(r.URL.Path, runPrefix)
 	parts := strings.SplitN(path, "/", 3)
@@ -49,8 +89,16 @@ func parseArgs(r *http.Request) (string, string, []string) {
 
 func (c *Component) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "no-cache")
+	if strings.HasPrefix(r.URL.Path, runsPrefix) {
+		c.serveRuns(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, hookPrefix) {
+		c.serveWebhook(w, r)
+		return
+	}
 	w.Header().Set("Connection", "keep-alive")
-	token, _ := store.Selected().GetToken(parseToken(r))
+	token := lookupToken(r)
 	if token == nil {
 		http.Error(w, "unauthorized token", http.StatusUnauthorized)
 		return
@@ -69,45 +117,178 @@ func (c *Component) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized token", http.StatusUnauthorized)
 		return
 	}
+	if err := token.Authorize(remoteIP(r)); err != nil {
+		log.Info(r, token.Key, err)
+		http.Error(w, "unauthorized token", http.StatusUnauthorized)
+		return
+	}
+	if !token.AllowsCmd(cmd.User, cmd.Name) {
+		http.Error(w, "unauthorized token", http.StatusUnauthorized)
+		return
+	}
+	store.RecordTokenUse(token.Key, remoteIP(r))
 
-	var wc io.WriteCloser
-	var isWebSocket bool
+	ctx := context.WithValue(context.Background(), "token", token)
+	u, err := console.LookupNickname(token.User)
+	if err == nil {
+		ctx = context.WithValue(ctx, "plan", u.Account.Plan)
+	}
+
+	if _, async := r.URL.Query()["async"]; async {
+		c.serveAsync(w, &httpSession{
+			req:   r,
+			wc:    &flushWriter{nil, ioutil.Discard},
+			token: token.Key,
+			ctx:   ctx,
+			cmd:   append([]string{cmdName}, args...),
+		}, cmd, args)
+		return
+	}
+
+	session := &httpSession{
+		req:   r,
+		token: token.Key,
+		ctx:   ctx,
+		cmd:   append([]string{cmdName}, args...),
+	}
+	var wc exitWriteCloser
 	if websocket.IsWebSocketUpgrade(r) {
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			// upgrader has already responded with an error
 			return
 		}
-		wc = &gorillaWSAdapter{sync.Mutex{}, conn}
-		isWebSocket = true
-	} else {
-		_, shouldStream := r.URL.Query()["stream"]
-		if f, ok := w.(http.Flusher); ok && shouldStream {
-			wc = &flushWriter{f, w}
+		framed := conn.Subprotocol() == FramedProtocol
+		ws := &gorillaWSAdapter{Conn: conn}
+		if framed {
+			wc = &wsFramedWriter{ws}
 		} else {
-			wc = &flushWriter{nil, w}
+			wc = ws
+		}
+		session.isWebSocket = true
+		pty, isPty := parsePty(r)
+		if _, interactive := r.URL.Query()["stdin"]; (interactive || isPty) && !token.NoStdin {
+			input, stdin := newWSInput(conn, framed)
+			if isPty {
+				// resize to the initial window once the container starts
+				input.resize(pty.Window)
+			}
+			go input.serve()
+			session.stdin = stdin
+			session.pty = pty
+			session.winch = input.winch
+		} else {
+			go discardInput(conn)
+		}
+	} else {
+		stream, shouldStream := r.URL.Query()["stream"]
+		_, mapStatus := r.URL.Query()["http_status"]
+		f, canFlush := w.(http.Flusher)
+		switch {
+		case shouldStream && stream[0] == "ndjson":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Trailer", ExitStatusHeader)
+			wc = &ndjsonWriter{flushWriter: &flushWriter{f, w}, header: w.Header()}
+		case shouldStream && canFlush:
+			w.Header().Set("Trailer", ExitStatusHeader)
+			wc = &trailerWriter{&flushWriter{f, w}, w.Header()}
+		default:
+			wc = &bufferedWriter{w: w, mapStatus: mapStatus}
 		}
 	}
-	ctx := context.Background()
-	u, err := console.LookupNickname(token.User)
-	if err == nil {
-		ctx = context.WithValue(ctx, "plan", u.Account.Plan)
-	}
-	session := &httpSession{
-		req:         r,
-		wc:          wc,
-		token:       token.Key,
-		isWebSocket: isWebSocket,
-		ctx:         ctx,
-		cmd:         append([]string{cmdName}, args...),
+	if token.NoStdin {
+		session.stdin = ioutil.NopCloser(strings.NewReader(""))
 	}
+	session.wc = wc
 	defer session.Close()
 
-	// TODO: put exit status in resp headers / stream trailers
-	if status := cmd.Run(session, args); status != 0 {
-		fmt.Fprintf(session, "exit status: %d", status)
+	rec := core.NewRunRecorder(cmd, session, core.RunViaHTTP, args)
+	status := cmd.Run(rec, args)
+	if err := store.Selected().PutRun(rec.Finish(status)); err != nil {
+		log.Info(r, cmd, err)
+	}
+	if err := wc.WriteExit(status); err != nil {
+		log.Info(r, cmd, err)
+	}
+}
+
+// exitWriteCloser is written output of a command and then its exit status.
+type exitWriteCloser interface {
+	io.WriteCloser
+	WriteExit(status int) error
+}
+
+// httpStatus maps a non-zero exit status to an HTTP status code.
+func httpStatus(exit int) int {
+	switch exit {
+	case 0:
+		return http.StatusOK
+	case cli.StatusUsageError, cli.StatusDataError:
+		return http.StatusBadRequest
+	case cli.StatusNoPerm:
+		return http.StatusForbidden
+	case 255:
+		// failed to start or exceeded plan limits
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// serveAsync runs cmd detached as a job responding with the job ID instead
+// of waiting for output. The run is recorded once the job exits.
+func (c *Component) serveAsync(w http.ResponseWriter, session *httpSession, cmd *core.Command, args []string) {
+	rec := core.NewRunRecorder(cmd, session, core.RunViaHTTP, args)
+	job, err := cmd.RunDetached(rec, args)
+	if err != nil {
+		fmt.Fprintln(rec.Stderr(), err.Error())
+		if err := store.Selected().PutRun(rec.Finish(255)); err != nil {
+			log.Info(session.req, cmd, err)
+		}
+		http.Error(w, err.Error(), asyncStatus(err))
 		return
 	}
+	go recordJob(session.Context(), rec, job)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":       job.ID,
+		"cmd":      job.Cmd,
+		"deadline": job.Deadline,
+	})
+}
+
+// asyncStatus maps an error starting a job to an HTTP status code.
+func asyncStatus(err error) int {
+	if err == billing.ErrMaxJobsExceded {
+		return http.StatusTooManyRequests
+	}
+	if _, ok := err.(*core.BuildError); ok {
+		return http.StatusBadRequest
+	}
+	return http.StatusServiceUnavailable
+}
+
+// recordJob follows the output of job into rec and records the run once the
+// job has exited.
+func recordJob(ctx context.Context, rec *core.RunRecorder, job *core.Job) {
+	status := 255
+	rt, err := core.JobRuntime(ctx)
+	if err == nil {
+		err = core.JobLogs(ctx, rt, job.ID, true, rec, rec.Stderr())
+	}
+	var exited *core.Job
+	if err == nil {
+		exited, err = core.GetJob(ctx, rt, job.User, job.ID)
+	}
+	if err == nil {
+		status = exited.ExitCode
+	} else {
+		log.Info("job", job.ID, err)
+	}
+	if err := store.Selected().PutRun(rec.Finish(status)); err != nil {
+		log.Info("job", job.ID, err)
+	}
 }
 
 type flushWriter struct {
@@ -127,9 +308,80 @@ func (fw *flushWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
+// trailerWriter streams output sending the exit status as a trailer.
+type trailerWriter struct {
+	*flushWriter
+	header http.Header
+}
+
+func (tw *trailerWriter) WriteExit(status int) error {
+	tw.header.Set(ExitStatusHeader, strconv.Itoa(status))
+	return nil
+}
+
+// maxBufferedOutput is the output held by a bufferedWriter before it starts
+// streaming.
+const maxBufferedOutput = 10 << 20
+
+// bufferedWriter holds output until the command exits so the exit status can
+// be sent as a header, and optionally as the response status code. Output
+// over limit is streamed instead, with the exit status sent as a trailer.
+type bufferedWriter struct {
+	sync.Mutex
+	w         http.ResponseWriter
+	buf       bytes.Buffer
+	mapStatus bool
+	limit     int // defaults to maxBufferedOutput
+	streaming bool
+}
+
+func (bw *bufferedWriter) Write(p []byte) (int, error) {
+	bw.Lock()
+	defer bw.Unlock()
+	limit := bw.limit
+	if limit == 0 {
+		limit = maxBufferedOutput
+	}
+	if !bw.streaming && bw.buf.Len()+len(p) <= limit {
+		return bw.buf.Write(p)
+	}
+	if !bw.streaming {
+		bw.streaming = true
+		bw.w.Header().Set("Trailer", ExitStatusHeader)
+		if _, err := bw.buf.WriteTo(bw.w); err != nil {
+			return 0, err
+		}
+	}
+	n, err := bw.w.Write(p)
+	if f, ok := bw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
+
+func (bw *bufferedWriter) WriteExit(status int) error {
+	bw.Lock()
+	defer bw.Unlock()
+	bw.w.Header().Set(ExitStatusHeader, strconv.Itoa(status))
+	if bw.streaming {
+		// headers were sent, so the status is sent as a trailer
+		return nil
+	}
+	if bw.mapStatus {
+		bw.w.WriteHeader(httpStatus(status))
+	}
+	_, err := bw.buf.WriteTo(bw.w)
+	return err
+}
+
+func (bw *bufferedWriter) Close() error {
+	return nil
+}
+
 type gorillaWSAdapter struct {
 	sync.Mutex
 	*websocket.Conn
+	closed bool
 }
 
 func (ws *gorillaWSAdapter) Write(p []byte) (int, error) {
@@ -138,10 +390,34 @@ func (ws *gorillaWSAdapter) Write(p []byte) (int, error) {
 	return len(p), ws.WriteMessage(websocket.TextMessage, p)
 }
 
+// WriteFrame sends a frame of the framed protocol as a JSON text message.
+func (ws *gorillaWSAdapter) WriteFrame(f Frame) error {
+	ws.Lock()
+	defer ws.Unlock()
+	return ws.WriteJSON(f)
+}
+
+// WriteExit sends a close frame with the exit status. Successful runs close
+// normally while failed runs close with code 4000 plus the exit status. The
+// reason is a JSON object with the exit status.
+func (ws *gorillaWSAdapter) WriteExit(status int) error {
+	ws.Lock()
+	defer ws.Unlock()
+	code := websocket.CloseNormalClosure
+	if status != 0 {
+		code = 4000 + status
+	}
+	ws.closed = true
+	return ws.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, fmt.Sprintf(`{"exit_status":%d}`, status)))
+}
+
 func (ws *gorillaWSAdapter) Close() error {
 	ws.Lock()
 	defer ws.Unlock()
-	ws.WriteMessage(websocket.CloseMessage,
-		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	if !ws.closed {
+		ws.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}
 	return ws.Conn.Close()
 }
@@ -0,0 +1,211 @@
+package runapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gliderlabs/cmd/app/billing"
+	"github.com/gliderlabs/cmd/app/core"
+)
+
+func TestBufferedWriter(t *testing.T) {
+	t.Run("Header", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		bw := &bufferedWriter{w: rec}
+		io.WriteString(bw, "output")
+		assert.NoError(t, bw.WriteExit(3))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "3", rec.Header().Get(ExitStatusHeader))
+		assert.Equal(t, "output", rec.Body.String(),
+			"Exit status should not be written to the body")
+	})
+
+	t.Run("MapStatus", func(t *testing.T) {
+		for exit, code := range map[int]int{
+			0:   http.StatusOK,
+			1:   http.StatusInternalServerError,
+			64:  http.StatusBadRequest,
+			77:  http.StatusForbidden,
+			255: http.StatusBadGateway,
+		} {
+			rec := httptest.NewRecorder()
+			bw := &bufferedWriter{w: rec, mapStatus: true}
+			assert.NoError(t, bw.WriteExit(exit))
+			assert.Equal(t, code, rec.Code, "exit status %d", exit)
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bw := &bufferedWriter{w: w, mapStatus: true, limit: 8}
+			io.WriteString(bw, "output")
+			io.WriteString(bw, " over limit")
+			io.WriteString(bw, " streamed")
+			bw.WriteExit(2)
+		}))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, "output over limit streamed", string(body))
+		assert.Equal(t, http.StatusOK, resp.StatusCode,
+			"Status should not be mapped once output is streamed")
+		assert.Empty(t, resp.Header.Get(ExitStatusHeader))
+		assert.Equal(t, "2", resp.Trailer.Get(ExitStatusHeader))
+	})
+}
+
+func TestTrailerWriter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", ExitStatusHeader)
+		tw := &trailerWriter{&flushWriter{w.(http.Flusher), w}, w.Header()}
+		io.WriteString(tw, "output")
+		tw.WriteExit(2)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "output", string(body))
+	assert.Equal(t, "2", resp.Trailer.Get(ExitStatusHeader))
+}
+
+func TestWebSocketExit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		ws := &gorillaWSAdapter{Conn: conn}
+		io.WriteString(ws, "output")
+		ws.WriteExit(3)
+		ws.Close()
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	_, msg, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, "output", string(msg))
+	_, _, err = conn.ReadMessage()
+	if closeErr, ok := err.(*websocket.CloseError); assert.True(t, ok, "expected close error: %v", err) {
+		assert.Equal(t, 4003, closeErr.Code)
+		assert.Equal(t, `{"exit_status":3}`, closeErr.Text)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", ExitStatusHeader)
+		nw := &ndjsonWriter{flushWriter: &flushWriter{w.(http.Flusher), w}, header: w.Header()}
+		io.WriteString(nw, "output")
+		io.WriteString(nw.Stderr(), "error")
+		nw.WriteExit(1)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	var frames []Frame
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var f Frame
+		if !assert.NoError(t, dec.Decode(&f)) {
+			return
+		}
+		frames = append(frames, f)
+	}
+	if assert.Len(t, frames, 3) {
+		assert.Equal(t, Frame{Channel: ChannelStdout, Data: []byte("output")}, frames[0])
+		assert.Equal(t, Frame{Channel: ChannelStderr, Data: []byte("error")}, frames[1])
+		assert.Equal(t, ChannelExit, frames[2].Channel)
+		if assert.NotNil(t, frames[2].Status) {
+			assert.Equal(t, 1, *frames[2].Status)
+		}
+	}
+	assert.Equal(t, "1", resp.Trailer.Get(ExitStatusHeader))
+}
+
+func TestFrameBinary(t *testing.T) {
+	var buf bytes.Buffer
+	nw := &ndjsonWriter{flushWriter: &flushWriter{nil, &buf}, header: http.Header{}}
+	output := []byte{'o', 0xff, 0xfe, '\n'}
+	nw.Write(output)
+	assert.Equal(t, `{"channel":"stdout","data":"b//+Cg=="}`+"\n", buf.String(),
+		"Data should be base64 encoded")
+	var f Frame
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &f)) {
+		assert.Equal(t, output, f.Data, "Invalid UTF-8 should be sent unchanged")
+	}
+}
+
+func TestWebSocketFramed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if conn.Subprotocol() != FramedProtocol {
+			conn.Close()
+			return
+		}
+		fw := &wsFramedWriter{&gorillaWSAdapter{Conn: conn}}
+		io.WriteString(fw, "output")
+		io.WriteString(fw.Stderr(), "error")
+		fw.WriteExit(0)
+		fw.Close()
+	}))
+	defer srv.Close()
+
+	dialer := &websocket.Dialer{Subprotocols: []string{FramedProtocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	var f Frame
+	assert.NoError(t, conn.ReadJSON(&f))
+	assert.Equal(t, Frame{Channel: ChannelStdout, Data: []byte("output")}, f)
+	f = Frame{}
+	assert.NoError(t, conn.ReadJSON(&f))
+	assert.Equal(t, Frame{Channel: ChannelStderr, Data: []byte("error")}, f)
+	f = Frame{}
+	assert.NoError(t, conn.ReadJSON(&f))
+	assert.Equal(t, ChannelExit, f.Channel)
+	if assert.NotNil(t, f.Status) {
+		assert.Equal(t, 0, *f.Status)
+	}
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "expected normal close: %v", err)
+}
+
+func TestAsyncStatus(t *testing.T) {
+	assert.Equal(t, http.StatusTooManyRequests, asyncStatus(billing.ErrMaxJobsExceded))
+	assert.Equal(t, http.StatusBadRequest, asyncStatus(&core.BuildError{Message: "failed"}))
+	assert.Equal(t, http.StatusServiceUnavailable, asyncStatus(errors.New("no runtime")))
+}
@@ -0,0 +1,166 @@
+package runapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gliderlabs/comlab/pkg/log"
+
+	"github.com/gliderlabs/cmd/app/console"
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/lib/crypto"
+	"github.com/gliderlabs/cmd/lib/github"
+	"github.com/gliderlabs/cmd/lib/stripe"
+)
+
+const hookPrefix = "/hook/"
+
+// Headers of deliveries to generic webhooks. Deliveries are verified with
+// either a hex HMAC-SHA256 of the body prefixed with "sha256=", or the
+// secret itself. Event and delivery headers are optional.
+const (
+	WebhookSignatureHeader = "X-Cmd-Webhook-Signature"
+	WebhookSecretHeader    = "X-Cmd-Webhook-Secret"
+	WebhookEventHeader     = "X-Cmd-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Cmd-Webhook-Delivery"
+)
+
+// ErrWebhookSignature is returned for generic webhook deliveries without a
+// valid signature or secret.
+var ErrWebhookSignature = errors.New("invalid webhook signature")
+
+// webhookEvent describes a verified delivery to the command as environment
+// variables.
+type webhookEvent struct {
+	Provider string
+	Event    string
+	Delivery string
+}
+
+func (e *webhookEvent) Environ() []string {
+	return []string{
+		"WEBHOOK_PROVIDER=" + e.Provider,
+		"WEBHOOK_EVENT=" + e.Event,
+		"WEBHOOK_DELIVERY=" + e.Delivery,
+	}
+}
+
+// serveWebhook runs a command with a verified webhook delivery at
+// /hook/<owner>/<cmd> as stdin. The command runs as its owner.
+func (c *Component) serveWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, hookPrefix), "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	cmd := store.Selected().Get(parts[0], parts[1])
+	if cmd == nil || cmd.Webhook == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	body, err := readBody(r, MaxSignedBody)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	event, err := verifyWebhook(cmd.Webhook, r, body)
+	if err != nil {
+		log.Info(r, cmd, err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := context.Background()
+	if u, err := console.LookupNickname(cmd.User); err == nil {
+		ctx = context.WithValue(ctx, "plan", u.Account.Plan)
+	}
+	wc := &bufferedWriter{w: w, mapStatus: true}
+	session := &httpSession{
+		req:   r,
+		wc:    wc,
+		token: cmd.User,
+		ctx:   ctx,
+		cmd:   []string{cmd.Name},
+		env:   event.Environ(),
+	}
+	defer session.Close()
+
+	rec := core.NewRunRecorder(cmd, session, core.RunViaWebhook, nil)
+	status := cmd.Run(rec, nil)
+	if err := store.Selected().PutRun(rec.Finish(status)); err != nil {
+		log.Info(r, cmd, err)
+	}
+	if err := wc.WriteExit(status); err != nil {
+		log.Info(r, cmd, err)
+	}
+}
+
+// verifyWebhook verifies a delivery with body to webhook using the method
+// of its provider, returning the event delivered.
+func verifyWebhook(webhook *core.Webhook, r *http.Request, body []byte) (*webhookEvent, error) {
+	secret := crypto.Decrypt(webhook.Secret)
+	event := &webhookEvent{Provider: webhook.Provider}
+	switch webhook.Provider {
+	case core.WebhookGitHub:
+		if err := github.VerifySignature(r.Header, body, secret); err != nil {
+			return nil, err
+		}
+		event.Event = r.Header.Get("X-GitHub-Event")
+		event.Delivery = r.Header.Get("X-GitHub-Delivery")
+	case core.WebhookStripe:
+		err := stripe.VerifySignature(r.Header.Get(stripe.SignatureHeader), body, secret, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		var payload struct {
+			ID   string `json:"id"`
+			Type string `json:"type"`
+		}
+		json.Unmarshal(body, &payload)
+		event.Event = payload.Type
+		event.Delivery = payload.ID
+	case core.WebhookGeneric:
+		if err := verifyGeneric(r.Header, body, secret); err != nil {
+			return nil, err
+		}
+		event.Event = r.Header.Get(WebhookEventHeader)
+		event.Delivery = r.Header.Get(WebhookDeliveryHeader)
+	default:
+		return nil, webhook.Validate()
+	}
+	return event, nil
+}
+
+func verifyGeneric(header http.Header, body []byte, secret string) error {
+	if secret == "" {
+		return ErrWebhookSignature
+	}
+	if sig := header.Get(WebhookSignatureHeader); sig != "" {
+		got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+		if err != nil {
+			return ErrWebhookSignature
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		if !hmac.Equal(got, mac.Sum(nil)) {
+			return ErrWebhookSignature
+		}
+		return nil
+	}
+	if !hmac.Equal([]byte(header.Get(WebhookSecretHeader)), []byte(secret)) {
+		return ErrWebhookSignature
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package runapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/lib/crypto"
+)
+
+func hmacHex(secret, msg string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(msg))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	secret := "s3cret"
+	box, err := crypto.Encrypt(secret)
+	if !assert.NoError(t, err) {
+		return
+	}
+	body := `{"id":"evt_1","type":"charge.succeeded"}`
+
+	t.Run("GitHub", func(t *testing.T) {
+		webhook := &core.Webhook{Provider: core.WebhookGitHub, Secret: box}
+		r := httptest.NewRequest("POST", "/hook/user/cmd", strings.NewReader(body))
+		r.Header.Set("X-GitHub-Event", "push")
+		r.Header.Set("X-GitHub-Delivery", "delivery-1")
+		r.Header.Set("X-Hub-Signature-256", "sha256="+hmacHex(secret, body))
+		event, err := verifyWebhook(webhook, r, []byte(body))
+		assert.NoError(t, err)
+		if assert.NotNil(t, event) {
+			assert.Equal(t, []string{
+				"WEBHOOK_PROVIDER=github",
+				"WEBHOOK_EVENT=push",
+				"WEBHOOK_DELIVERY=delivery-1",
+			}, event.Environ())
+		}
+
+		r.Header.Set("X-Hub-Signature-256", "sha256="+hmacHex("wrong", body))
+		_, err = verifyWebhook(webhook, r, []byte(body))
+		assert.Error(t, err)
+	})
+
+	t.Run("Stripe", func(t *testing.T) {
+		webhook := &core.Webhook{Provider: core.WebhookStripe, Secret: box}
+		ts := fmt.Sprint(time.Now().Unix())
+		r := httptest.NewRequest("POST", "/hook/user/cmd", strings.NewReader(body))
+		r.Header.Set("Stripe-Signature", fmt.Sprintf("t=%s,v1=%s", ts, hmacHex(secret, ts+"."+body)))
+		event, err := verifyWebhook(webhook, r, []byte(body))
+		assert.NoError(t, err)
+		if assert.NotNil(t, event) {
+			assert.Equal(t, "charge.succeeded", event.Event)
+			assert.Equal(t, "evt_1", event.Delivery)
+		}
+
+		old := fmt.Sprint(time.Now().Add(-time.Hour).Unix())
+		r.Header.Set("Stripe-Signature", fmt.Sprintf("t=%s,v1=%s", old, hmacHex(secret, old+"."+body)))
+		_, err = verifyWebhook(webhook, r, []byte(body))
+		assert.Error(t, err, "Old signatures should be rejected")
+	})
+
+	t.Run("Generic", func(t *testing.T) {
+		webhook := &core.Webhook{Provider: core.WebhookGeneric, Secret: box}
+		r := httptest.NewRequest("POST", "/hook/user/cmd", strings.NewReader(body))
+		r.Header.Set(WebhookSignatureHeader, "sha256="+hmacHex(secret, body))
+		r.Header.Set(WebhookEventHeader, "deploy")
+		event, err := verifyWebhook(webhook, r, []byte(body))
+		assert.NoError(t, err)
+		if assert.NotNil(t, event) {
+			assert.Equal(t, "deploy", event.Event)
+		}
+
+		r = httptest.NewRequest("POST", "/hook/user/cmd", strings.NewReader(body))
+		r.Header.Set(WebhookSecretHeader, secret)
+		_, err = verifyWebhook(webhook, r, []byte(body))
+		assert.NoError(t, err)
+
+		r.Header.Set(WebhookSecretHeader, "wrong")
+		_, err = verifyWebhook(webhook, r, []byte(body))
+		assert.Equal(t, ErrWebhookSignature, err)
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		webhook := &core.Webhook{Provider: "other", Secret: box}
+		r := httptest.NewRequest("POST", "/hook/user/cmd", strings.NewReader(body))
+		_, err := verifyWebhook(webhook, r, []byte(body))
+		assert.Error(t, err)
+	})
+}
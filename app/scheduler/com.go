@@ -0,0 +1,30 @@
+// Package scheduler runs commands on the cron schedules created with the
+// :schedule builtin.
+package scheduler
+
+import (
+	"sync"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+)
+
+func init() {
+	com.Register("scheduler", &Component{},
+		com.Option("interval", "15s", "interval between checking for due schedules"))
+}
+
+// Component implements a supervised service running due schedules
+type Component struct {
+	mu      sync.Mutex
+	running map[string]bool
+	wg      sync.WaitGroup
+	once    sync.Once
+	stop    chan struct{}
+
+	// store and run are overridden by tests
+	store store.ScheduleBackend
+	run   func(schedule *core.Schedule) (status int, output string)
+}
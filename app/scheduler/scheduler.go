@@ -0,0 +1,184 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/log"
+
+	"github.com/gliderlabs/cmd/app/console"
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/lib/cli"
+)
+
+// Serve runs due schedules until stopped.
+func (c *Component) Serve() {
+	interval, err := time.ParseDuration(com.GetString("interval"))
+	if err != nil {
+		log.Info(err)
+		interval = 15 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopped():
+			c.wg.Wait()
+			return
+		case now := <-ticker.C:
+			if err := c.tick(now); err != nil {
+				log.Info(err)
+			}
+		}
+	}
+}
+
+// Stop checking schedules, waiting for running commands to finish.
+func (c *Component) Stop() {
+	close(c.stopped())
+}
+
+// stopped returns the channel closed by Stop, made on first use since Stop
+// may be called before Serve.
+func (c *Component) stopped() chan struct{} {
+	c.once.Do(func() {
+		c.stop = make(chan struct{})
+	})
+	return c.stop
+}
+
+func (c *Component) backend() store.ScheduleBackend {
+	if c.store == nil {
+		return store.Selected()
+	}
+	return c.store
+}
+
+// tick starts each schedule due at now that is not already running. The next
+// run is claimed in the store before starting, so a run is only started once
+// by any scheduler and a slow run is not started twice.
+func (c *Component) tick(now time.Time) error {
+	schedules, err := c.backend().ListSchedules("")
+	if err != nil {
+		return err
+	}
+	for _, schedule := range schedules {
+		if !schedule.Due(now) || !c.start(schedule.ID) {
+			continue
+		}
+		prev := schedule.NextRun
+		if err := schedule.Next(now); err != nil {
+			log.Info(schedule.ID, err)
+			c.done(schedule.ID)
+			continue
+		}
+		claimed, err := c.backend().ClaimSchedule(schedule, prev)
+		if err != nil {
+			log.Info(schedule.ID, err)
+		}
+		if !claimed {
+			c.done(schedule.ID)
+			continue
+		}
+		c.wg.Add(1)
+		go func(schedule *core.Schedule) {
+			defer c.wg.Done()
+			defer c.done(schedule.ID)
+			c.runSchedule(schedule, now)
+		}(schedule)
+	}
+	return nil
+}
+
+// start marks schedule id as running, returning false if it already was.
+func (c *Component) start(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running == nil {
+		c.running = make(map[string]bool)
+	}
+	if c.running[id] {
+		return false
+	}
+	c.running[id] = true
+	return true
+}
+
+func (c *Component) done(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.running, id)
+}
+
+// runSchedule runs the command for schedule and records the result.
+func (c *Component) runSchedule(schedule *core.Schedule, now time.Time) {
+	run := c.run
+	if run == nil {
+		run = runCmd
+	}
+	status, output := run(schedule)
+	log.Info("schedule", schedule.ID, schedule.Owner+"/"+schedule.Cmd, status)
+
+	// reload as the schedule may have been changed or removed while running
+	current, err := c.backend().GetSchedule(schedule.ID)
+	if err != nil {
+		return
+	}
+	current.LastRun = now.UTC()
+	current.LastStatus = status
+	current.LastOutput = output
+	if err := c.backend().PutSchedule(current); err != nil {
+		log.Info(schedule.ID, err)
+	}
+}
+
+// runCmd runs the command for schedule as the user who created it, limited
+// by the plan of the command owner.
+func runCmd(schedule *core.Schedule) (int, string) {
+	cmd := store.Selected().Get(schedule.Owner, schedule.Cmd)
+	if cmd == nil {
+		return cli.StatusUnknownCommand, fmt.Sprintf("Command not found: %s/%s",
+			schedule.Owner, schedule.Cmd)
+	}
+	if !cmd.HasAccess(schedule.User) {
+		return cli.StatusNoPerm, "Not allowed"
+	}
+	ctx := context.Background()
+	token, err := scheduleToken(store.Selected(), schedule)
+	if err != nil {
+		return cli.StatusNoPerm, err.Error()
+	}
+	if token != nil {
+		ctx = context.WithValue(ctx, "token", token)
+	}
+	if u, err := console.LookupNickname(schedule.Owner); err == nil {
+		ctx = context.WithValue(ctx, "plan", u.Account.Plan)
+	}
+	sess := newSession(ctx, schedule)
+	status := cmd.Run(sess, schedule.Args)
+	return status, sess.output.String()
+}
+
+// scheduleToken returns the token schedule was created with, if any, or an
+// error if the token may no longer run the command. Tokens are checked on
+// every run since they can expire or be removed after scheduling.
+func scheduleToken(backend store.TokenBackend, schedule *core.Schedule) (*core.Token, error) {
+	if !core.IsTokenKey(schedule.User) {
+		return nil, nil
+	}
+	token, err := backend.GetToken(schedule.User)
+	if err != nil || token == nil || token.Key != schedule.User {
+		return nil, errors.New("Not allowed: token removed")
+	}
+	if token.Expired() {
+		return nil, core.ErrTokenExpired
+	}
+	if !token.AllowsCmd(schedule.Owner, schedule.Cmd) {
+		return nil, core.ErrTokenCmdDenied
+	}
+	return token, nil
+}
@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store/memory"
+)
+
+func TestTick(t *testing.T) {
+	var (
+		backend = &memory.Component{}
+		now     = time.Date(2017, 1, 31, 18, 0, 0, 0, time.UTC)
+		mu      sync.Mutex
+		ran     []string
+	)
+	c := &Component{
+		store: backend,
+		run: func(schedule *core.Schedule) (int, string) {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, schedule.ID)
+			return 3, "output"
+		},
+	}
+	assert.NoError(t, backend.PutSchedule(&core.Schedule{
+		ID: "due", User: "user", Owner: "owner", Cmd: "cmd",
+		Spec: "@hourly", NextRun: now,
+	}))
+	assert.NoError(t, backend.PutSchedule(&core.Schedule{
+		ID: "later", User: "user", Owner: "owner", Cmd: "cmd",
+		Spec: "@hourly", NextRun: now.Add(time.Hour),
+	}))
+
+	assert.NoError(t, c.tick(now))
+	c.wg.Wait()
+	assert.Equal(t, []string{"due"}, ran)
+
+	schedule, err := backend.GetSchedule("due")
+	if assert.NoError(t, err) {
+		assert.Equal(t, now.Add(time.Hour), schedule.NextRun)
+		assert.Equal(t, now, schedule.LastRun)
+		assert.Equal(t, 3, schedule.LastStatus)
+		assert.Equal(t, "output", schedule.LastOutput)
+	}
+
+	assert.NoError(t, c.tick(now.Add(time.Minute)))
+	c.wg.Wait()
+	assert.Equal(t, []string{"due"}, ran, "Schedule should not run before NextRun")
+}
+
+func TestTickRunning(t *testing.T) {
+	var (
+		backend = &memory.Component{}
+		now     = time.Date(2017, 1, 31, 18, 0, 0, 0, time.UTC)
+		release = make(chan struct{})
+		runs    int
+	)
+	c := &Component{
+		store: backend,
+		run: func(schedule *core.Schedule) (int, string) {
+			runs++
+			<-release
+			return 0, ""
+		},
+	}
+	assert.NoError(t, backend.PutSchedule(&core.Schedule{
+		ID: "slow", User: "user", Owner: "owner", Cmd: "cmd",
+		Spec: "* * * * *", NextRun: now,
+	}))
+
+	assert.NoError(t, c.tick(now))
+	assert.NoError(t, c.tick(now.Add(time.Minute)))
+	close(release)
+	c.wg.Wait()
+	assert.Equal(t, 1, runs, "Schedule should not run while still running")
+}
+
+func TestTickClaimed(t *testing.T) {
+	var (
+		backend = &memory.Component{}
+		now     = time.Date(2017, 1, 31, 18, 0, 0, 0, time.UTC)
+		mu      sync.Mutex
+		runs    int
+	)
+	run := func(schedule *core.Schedule) (int, string) {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		return 0, ""
+	}
+	a := &Component{store: backend, run: run}
+	b := &Component{store: backend, run: run}
+	assert.NoError(t, backend.PutSchedule(&core.Schedule{
+		ID: "due", User: "user", Owner: "owner", Cmd: "cmd",
+		Spec: "@hourly", NextRun: now,
+	}))
+
+	// b lists the schedule before a claims the run
+	schedules, err := backend.ListSchedules("")
+	assert.NoError(t, err)
+	assert.NoError(t, a.tick(now))
+	a.wg.Wait()
+	b.store = &staleList{backend, schedules}
+	assert.NoError(t, b.tick(now))
+	b.wg.Wait()
+	assert.Equal(t, 1, runs, "Run should only be started by one scheduler")
+}
+
+// staleList returns schedules listed before another scheduler claimed them.
+type staleList struct {
+	*memory.Component
+	schedules []*core.Schedule
+}
+
+func (s *staleList) ListSchedules(user string) ([]*core.Schedule, error) {
+	return s.schedules, nil
+}
+
+func TestScheduleToken(t *testing.T) {
+	backend := &memory.Component{}
+	key := "c2a9b1c6-7a4f-4f1e-9d1b-3f1b1f3e5a01"
+	schedule := &core.Schedule{User: key, Owner: "user", Cmd: "cmd"}
+
+	_, err := scheduleToken(backend, schedule)
+	assert.Error(t, err, "Removed tokens should not run schedules")
+
+	token := &core.Token{Key: key, User: "user"}
+	assert.NoError(t, backend.PutToken(token))
+	got, err := scheduleToken(backend, schedule)
+	assert.NoError(t, err)
+	assert.Equal(t, key, got.Key)
+
+	token.Cmds = []string{"other"}
+	assert.NoError(t, backend.PutToken(token))
+	_, err = scheduleToken(backend, schedule)
+	assert.Equal(t, core.ErrTokenCmdDenied, err)
+
+	token.Cmds = nil
+	token.ExpiresOn = time.Now().Add(-time.Hour)
+	assert.NoError(t, backend.PutToken(token))
+	_, err = scheduleToken(backend, schedule)
+	assert.Equal(t, core.ErrTokenExpired, err)
+
+	got, err = scheduleToken(backend, &core.Schedule{User: "user"})
+	assert.NoError(t, err)
+	assert.Nil(t, got, "Schedules of users have no token")
+}
+
+func TestStopBeforeServe(t *testing.T) {
+	c := &Component{store: &memory.Component{}}
+	c.Stop()
+	done := make(chan struct{})
+	go func() {
+		c.Serve()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Serve should return when stopped before it started")
+	}
+}
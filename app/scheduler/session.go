@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/gliderlabs/cmd/app/core"
+)
+
+// maxOutput is the number of bytes of output kept from the end of a run.
+const maxOutput = 4096
+
+// session runs a scheduled command without standard input, keeping the tail
+// of its combined output.
+type session struct {
+	ctx      context.Context
+	schedule *core.Schedule
+	output   *core.TailBuffer
+}
+
+func newSession(ctx context.Context, schedule *core.Schedule) *session {
+	return &session{
+		ctx:      ctx,
+		schedule: schedule,
+		output:   &core.TailBuffer{Max: maxOutput},
+	}
+}
+
+func (sess *session) Write(p []byte) (n int, err error) {
+	return sess.output.Write(p)
+}
+func (sess *session) Read(data []byte) (int, error) {
+	return 0, io.EOF
+}
+func (sess *session) PublicKey() ssh.PublicKey {
+	return nil
+}
+func (sess *session) Exit(code int) error {
+	return nil
+}
+func (sess *session) Permissions() ssh.Permissions {
+	return ssh.Permissions{}
+}
+func (sess *session) Context() context.Context {
+	return sess.ctx
+}
+func (sess *session) User() string {
+	return sess.schedule.User
+}
+func (sess *session) RemoteAddr() net.Addr {
+	return &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+func (sess *session) Environ() []string {
+	return []string{
+		"CMD_SCHEDULE=" + sess.schedule.ID,
+		"CMD_SCHEDULE_SPEC=" + sess.schedule.Spec,
+	}
+}
+func (sess *session) Command() []string {
+	return append([]string{sess.schedule.Cmd}, sess.schedule.Args...)
+}
+func (sess *session) Pty() (ssh.Pty, <-chan ssh.Window, bool) {
+	return ssh.Pty{}, nil, false
+}
+func (sess *session) Close() error {
+	return nil
+}
+func (sess *session) CloseWrite() error {
+	return nil
+}
+func (sess *session) SendRequest(name string, wantReply bool, payload []byte) (bool, error) {
+	return false, nil
+}
+func (sess *session) Stderr() io.ReadWriter {
+	return &struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(""), sess.output}
+}
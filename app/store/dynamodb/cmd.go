@@ -49,7 +49,7 @@ func (c *Component) Get(user, name string) *core.Command {
 	migrated, err := migrations.Apply(latestVesion, false, item)
 	if err != nil {
 		log.Info(errors.Wrapf(err,
-			"failed migrating cmd: %s from user: %s to version: %s",
+			"failed migrating cmd: %s from user: %s to version: %d",
 			name, user, latestVesion),
 		)
 		return nil
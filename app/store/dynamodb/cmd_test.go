@@ -10,8 +10,8 @@ import (
 	"github.com/gliderlabs/comlab/pkg/com/viper"
 	"github.com/stretchr/testify/assert"
 
-	"github.com/gliderlabs/cmd/app/core"
 	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/app/store/storetest"
 )
 
 func TestCmdBackend(t *testing.T) {
@@ -36,85 +36,5 @@ func TestCmdBackend(t *testing.T) {
 		}
 	}
 
-	t.Run("Put", func(t *testing.T) {
-		assert.NoError(t, c.Put("user", "cmd", &core.Command{
-			User: "user",
-			Name: "cmd",
-		}))
-	})
-
-	t.Run("PutNil", func(t *testing.T) {
-		assert.Error(t, c.Put("user", "cmd", nil))
-	})
-
-	t.Run("Get", func(t *testing.T) {
-		assert.NotNil(t, c.Get("user", "cmd"))
-	})
-
-	t.Run("List", func(t *testing.T) {
-		assert.NoError(t, c.Put("user2", "cmd", &core.Command{
-			User: "user2",
-			Name: "cmd",
-		}))
-
-		cmds := c.List("user")
-		assert.NotNil(t, cmds)
-		for _, cmd := range cmds {
-			assert.Equal(t, "user", cmd.User,
-				"Result should only contain commands owned by 'user'")
-		}
-	})
-
-	t.Run("GrantAccess", func(t *testing.T) {
-		err := c.GrantAccess("user", "cmd", "foo")
-		assert.NoError(t, err)
-		cmd := c.Get("user", "cmd")
-		if assert.NotNil(t, cmd) {
-			assert.EqualValues(t, []string{"foo"}, cmd.ACL)
-		}
-		err = c.GrantAccess("user", "cmd", "bar")
-		assert.NoError(t, err)
-		cmd = c.Get("user", "cmd")
-		if assert.NotNil(t, cmd) {
-			assert.EqualValues(t, []string{"bar", "foo"}, cmd.ACL)
-		}
-	})
-
-	t.Run("RevokeAccess", func(t *testing.T) {
-		err := c.RevokeAccess("user", "cmd", "foo")
-		assert.NoError(t, err)
-		cmd := c.Get("user", "cmd")
-		if assert.NotNil(t, cmd) {
-			assert.EqualValues(t, []string{"bar"}, cmd.ACL)
-		}
-	})
-
-	t.Run("GrantAdmin", func(t *testing.T) {
-		err := c.GrantAdmin("user", "cmd", "foo")
-		assert.NoError(t, err)
-		cmd := c.Get("user", "cmd")
-		if assert.NotNil(t, cmd) {
-			assert.EqualValues(t, []string{"foo"}, cmd.Admins)
-		}
-		err = c.GrantAdmin("user", "cmd", "bar")
-		assert.NoError(t, err)
-		cmd = c.Get("user", "cmd")
-		if assert.NotNil(t, cmd) {
-			assert.EqualValues(t, []string{"bar", "foo"}, cmd.Admins)
-		}
-	})
-
-	t.Run("RevokeAdmin", func(t *testing.T) {
-		err := c.RevokeAdmin("user", "cmd", "foo")
-		assert.NoError(t, err)
-		cmd := c.Get("user", "cmd")
-		if assert.NotNil(t, cmd) {
-			assert.EqualValues(t, []string{"bar"}, cmd.Admins)
-		}
-	})
-
-	t.Run("Delete", func(t *testing.T) {
-		assert.NoError(t, c.Delete("user", "cmd"))
-		assert.Nil(t, c.Get("user", "cmd"))
-	})
+	storetest.TestCmdBackend(t, c)
 }
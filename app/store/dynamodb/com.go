@@ -1,6 +1,8 @@
 package dynamodb
 
 import (
+	"strings"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -13,7 +15,12 @@ import (
 func init() {
 	com.Register("store.dynamodb", &Component{},
 		com.Option("table", "", "dynamodb table name for command storage"),
-		com.Option("token_table", "", "dynamodb table name for token storage"),
+		com.Option("token_table", "", "dynamodb table name for token storage, default <table>_tokens"),
+		com.Option("revision_table", "", "dynamodb table name for command revision storage, default <table>_revisions"),
+		com.Option("schedule_table", "", "dynamodb table name for schedule storage, default <table>_schedules"),
+		com.Option("run_table", "", "dynamodb table name for run history storage, default <table>_runs"),
+		com.Option("key_table", "", "dynamodb table name for ssh key storage, default <table>_keys"),
+		com.Option("signature_table", "", "dynamodb table name for used request signatures, default <table>_signatures"),
 		com.Option("access_key", "", "aws access key for dynamodb store"),
 		com.Option("secret_key", "", "aws secret key for dynamodb store"),
 		com.Option("endpoint", "", "alternate dynamodb endpoint. eg: http://localhost:8000"),
@@ -31,30 +38,83 @@ type Component struct{}
 // Note that an error will be returned if a hard migration is required and
 // maintenance is NOT active.
 func (c *Component) AppPreStart() error {
-	var (
-		cmdTable   = com.GetString("table")
-		tokenTable = com.GetString("token_table")
-	)
-
+	cmdTable := tableName("table")
+	if cmdTable == "" {
+		return errors.New("dynamodb option \"table\" must be set")
+	}
 	if err := ensureTableExists(c.client(), cmdTable, 5, 5); err != nil {
 		return errors.Wrapf(err, "dynamodb table %q setup failed", cmdTable)
 	}
 
-	if err := ensureTokenTableExists(c.client(), tokenTable, 5, 5); err != nil {
-		return errors.Wrapf(err, "dynamodb table %q setup failed", tokenTable)
+	for _, t := range []struct {
+		option string
+		schema tableSchema
+	}{
+		{"token_table", tokenSchema},
+		{"revision_table", revisionSchema},
+		{"schedule_table", scheduleSchema},
+		{"run_table", runSchema},
+		{"key_table", keySchema},
+		{"signature_table", signatureSchema},
+	} {
+		name := tableName(t.option)
+		if name == "" {
+			return errors.Errorf("dynamodb option %q must be set", t.option)
+		}
+		if _, err := ensureTable(c.client(), name, t.schema, 5, 5); err != nil {
+			return errors.Wrapf(err, "dynamodb table %q setup failed", name)
+		}
 	}
 
 	return ensureTableSchema(c.client(), cmdTable)
 }
 
-func (c *Component) cmdTable() dynamo.Table {
+// tableName returns the table set by option. Tables other than the command
+// table default to the command table name with a suffix, such as
+// <table>_tokens for token_table.
+func tableName(option string) string {
+	name := com.GetString(option)
+	if name != "" || option == "table" {
+		return name
+	}
+	table := com.GetString("table")
+	if table == "" {
+		return ""
+	}
+	return table + "_" + strings.TrimSuffix(option, "_table") + "s"
+}
+
+func (c *Component) table(option string) dynamo.Table {
 	db := dynamo.New(session.New(), &c.client().Config)
-	return db.Table(com.GetString("table"))
+	return db.Table(tableName(option))
+}
+
+func (c *Component) cmdTable() dynamo.Table {
+	return c.table("table")
 }
 
 func (c *Component) tokenTable() dynamo.Table {
-	db := dynamo.New(session.New(), &c.client().Config)
-	return db.Table(com.GetString("token_table"))
+	return c.table("token_table")
+}
+
+func (c *Component) revisionTable() dynamo.Table {
+	return c.table("revision_table")
+}
+
+func (c *Component) scheduleTable() dynamo.Table {
+	return c.table("schedule_table")
+}
+
+func (c *Component) runTable() dynamo.Table {
+	return c.table("run_table")
+}
+
+func (c *Component) keyTable() dynamo.Table {
+	return c.table("key_table")
+}
+
+func (c *Component) signatureTable() dynamo.Table {
+	return c.table("signature_table")
 }
 
 func (c *Component) client() *dynamodb.DynamoDB {
@@ -0,0 +1,26 @@
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/com/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableName(t *testing.T) {
+	cfg := viper.NewConfig()
+	cfg.Set("dynamodb.table", "alpha.cmd.io_config")
+	cfg.Set("dynamodb.token_table", "alpha.cmd.io_tokens")
+	com.SetConfig(cfg)
+	assert.Equal(t, "alpha.cmd.io_config", tableName("table"))
+	assert.Equal(t, "alpha.cmd.io_tokens", tableName("token_table"))
+	assert.Equal(t, "alpha.cmd.io_config_revisions", tableName("revision_table"),
+		"Unset tables should default to the command table name with a suffix")
+	assert.Equal(t, "alpha.cmd.io_config_signatures", tableName("signature_table"))
+
+	com.SetConfig(viper.NewConfig())
+	assert.Equal(t, "", tableName("table"))
+	assert.Equal(t, "", tableName("key_table"), "Tables should not default without a command table")
+	assert.Error(t, (&Component{}).AppPreStart())
+}
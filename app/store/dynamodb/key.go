@@ -0,0 +1,30 @@
+package dynamodb
+
+import (
+	"github.com/guregu/dynamo"
+
+	"github.com/gliderlabs/cmd/app/core"
+)
+
+// ListKeys of user ordered by fingerprint.
+func (c *Component) ListKeys(user string) ([]*core.Key, error) {
+	var keys []*core.Key
+	err := c.keyTable().Get("User", user).All(&keys)
+	if err == dynamo.ErrNotFound {
+		return nil, nil
+	}
+	return keys, err
+}
+
+// PutKey ...
+func (c *Component) PutKey(key *core.Key) error {
+	if err := key.Validate(); err != nil {
+		return err
+	}
+	return c.keyTable().Put(key).Run()
+}
+
+// DeleteKey of user by fingerprint.
+func (c *Component) DeleteKey(user, fingerprint string) error {
+	return c.keyTable().Delete("User", user).Range("Fingerprint", fingerprint).Run()
+}
@@ -0,0 +1,76 @@
+package dynamodb
+
+import (
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/guregu/dynamo"
+	"github.com/pkg/errors"
+
+	"github.com/gliderlabs/cmd/app/core"
+)
+
+// maxRevisionRetries when racing another writer for a revision number
+const maxRevisionRetries = 20
+
+// ListRevisions of a command, oldest first.
+func (c *Component) ListRevisions(user, name string) ([]*core.Revision, error) {
+	var revs []*core.Revision
+	err := c.revisionTable().Get("Cmd", core.RevisionKey(user, name)).
+		Order(dynamo.Ascending).All(&revs)
+	if err == dynamo.ErrNotFound {
+		return nil, nil
+	}
+	return revs, err
+}
+
+// GetRevision of a command by number.
+func (c *Component) GetRevision(user, name string, revision int) (*core.Revision, error) {
+	var rev *core.Revision
+	err := c.revisionTable().Get("Cmd", core.RevisionKey(user, name)).
+		Range("Revision", dynamo.Equal, revision).One(&rev)
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// PutRevision assigning the next revision number. A conditional put is used
+// so concurrent writers never overwrite a revision.
+func (c *Component) PutRevision(rev *core.Revision) error {
+	if rev == nil || rev.Cmd == "" {
+		return errors.New("revision Cmd required")
+	}
+	for i := 0; i < maxRevisionRetries; i++ {
+		var latest core.Revision
+		err := c.revisionTable().Get("Cmd", rev.Cmd).
+			Order(dynamo.Descending).Limit(1).Consistent(true).One(&latest)
+		if err != nil && err != dynamo.ErrNotFound {
+			return err
+		}
+		rev.Revision = latest.Revision + 1
+		err = c.revisionTable().Put(rev).If("attribute_not_exists('Revision')").Run()
+		if awserr, ok := err.(awserr.Error); ok && awserr.Code() == "ConditionalCheckFailedException" {
+			continue
+		}
+		return err
+	}
+	return errors.Errorf("unable to assign revision for cmd: %s", rev.Cmd)
+}
+
+// DeleteRevisions of a command.
+func (c *Component) DeleteRevisions(user, name string) error {
+	key := core.RevisionKey(user, name)
+	var keys []struct {
+		Cmd      string
+		Revision int
+	}
+	err := c.revisionTable().Get("Cmd", key).Project("Cmd", "Revision").All(&keys)
+	if err != nil && err != dynamo.ErrNotFound {
+		return err
+	}
+	for _, k := range keys {
+		if err := c.revisionTable().Delete("Cmd", key).Range("Revision", k.Revision).Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
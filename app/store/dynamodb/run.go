@@ -0,0 +1,59 @@
+package dynamodb
+
+import (
+	"github.com/guregu/dynamo"
+	"github.com/pkg/errors"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+)
+
+// ListRuns of a command newest first.
+func (c *Component) ListRuns(user, name string, limit int) ([]*core.RunRecord, error) {
+	var runs []*core.RunRecord
+	query := c.runTable().Get("Cmd", core.RevisionKey(user, name)).Order(dynamo.Descending)
+	if limit > 0 {
+		query = query.Limit(int64(limit))
+	}
+	err := query.All(&runs)
+	if err == dynamo.ErrNotFound {
+		return nil, nil
+	}
+	return runs, err
+}
+
+// GetRun of a command by id.
+func (c *Component) GetRun(user, name, id string) (*core.RunRecord, error) {
+	var run *core.RunRecord
+	err := c.runTable().Get("Cmd", core.RevisionKey(user, name)).
+		Range("ID", dynamo.Equal, id).One(&run)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// PutRun discarding the oldest runs beyond store.MaxRuns.
+func (c *Component) PutRun(run *core.RunRecord) error {
+	if run == nil || run.Cmd == "" || run.ID == "" {
+		return errors.New("run Cmd and ID required")
+	}
+	if err := c.runTable().Put(run).Run(); err != nil {
+		return err
+	}
+	var keys []struct {
+		Cmd string
+		ID  string
+	}
+	err := c.runTable().Get("Cmd", run.Cmd).
+		Order(dynamo.Descending).Project("Cmd", "ID").All(&keys)
+	if err != nil {
+		return err
+	}
+	for i := store.MaxRuns; i < len(keys); i++ {
+		if err := c.runTable().Delete("Cmd", run.Cmd).Range("ID", keys[i].ID).Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
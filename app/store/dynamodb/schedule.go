@@ -0,0 +1,55 @@
+package dynamodb
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+
+	"github.com/gliderlabs/cmd/app/core"
+)
+
+// ListSchedules created by user, or all schedules if user is empty.
+func (c *Component) ListSchedules(user string) ([]*core.Schedule, error) {
+	var schedules []*core.Schedule
+	scan := c.scheduleTable().Scan()
+	if user != "" {
+		scan = scan.Filter("'User' = ?", user)
+	}
+	err := scan.All(&schedules)
+	return schedules, err
+}
+
+// GetSchedule by id.
+func (c *Component) GetSchedule(id string) (*core.Schedule, error) {
+	var schedule *core.Schedule
+	err := c.scheduleTable().Get("ID", id).One(&schedule)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// PutSchedule ...
+func (c *Component) PutSchedule(schedule *core.Schedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	return c.scheduleTable().Put(schedule).Run()
+}
+
+// ClaimSchedule with a conditional put on the stored NextRun.
+func (c *Component) ClaimSchedule(schedule *core.Schedule, prev time.Time) (bool, error) {
+	if err := schedule.Validate(); err != nil {
+		return false, err
+	}
+	err := c.scheduleTable().Put(schedule).If("'NextRun' = ?", prev).Run()
+	if awserr, ok := err.(awserr.Error); ok && awserr.Code() == "ConditionalCheckFailedException" {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// DeleteSchedule by id
+func (c *Component) DeleteSchedule(id string) error {
+	return c.scheduleTable().Delete("ID", id).Run()
+}
@@ -0,0 +1,38 @@
+package dynamodb
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/com/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/app/store/storetest"
+)
+
+func TestScheduleBackend(t *testing.T) {
+	assert.Implements(t, new(store.ScheduleBackend), new(Component))
+
+	os.Setenv("DYNAMODB_SCHEDULE_TABLE", "cmd-test-schedules-table")
+	os.Setenv("DYNAMODB_REGION", "local")
+	os.Setenv("DYNAMODB_ACCESS_KEY", "test")
+	os.Setenv("DYNAMODB_SECRET_KEY", "test")
+	os.Setenv("DYNAMODB_MAX_RETRIES", "1")
+	cfg := viper.NewConfig()
+	cfg.AutomaticEnv()
+	cfg.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	com.SetConfig(cfg)
+	c := &Component{}
+	_, err := ensureTable(c.client(), "cmd-test-schedules-table", scheduleSchema, 5, 5)
+	if awserr, ok := err.(awserr.Error); ok {
+		if awserr.Code() == "RequestError" && awserr.Message() == "send request failed" {
+			t.Skip("unable to connect to local instance of dynamodb", awserr)
+		}
+	}
+
+	storetest.TestScheduleBackend(t, c)
+}
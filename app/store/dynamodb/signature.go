@@ -0,0 +1,26 @@
+package dynamodb
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// signature is a used request signature. Expires is in unix seconds so
+// DynamoDB removes it once expired.
+type signature struct {
+	Signature string
+	Expires   int64
+}
+
+// UseSignature records signature until expires with a conditional put, as
+// expired items may not have been removed yet.
+func (c *Component) UseSignature(sig string, expires time.Time) (bool, error) {
+	err := c.signatureTable().Put(signature{sig, expires.Unix()}).
+		If("attribute_not_exists('Signature') OR 'Expires' <= ?", time.Now().Unix()).
+		Run()
+	if awserr, ok := err.(awserr.Error); ok && awserr.Code() == "ConditionalCheckFailedException" {
+		return false, nil
+	}
+	return err == nil, err
+}
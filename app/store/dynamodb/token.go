@@ -1,6 +1,8 @@
 package dynamodb
 
 import (
+	"time"
+
 	"github.com/gliderlabs/cmd/app/core"
 )
 
@@ -27,3 +29,15 @@ func (c *Component) PutToken(token *core.Token) error {
 func (c *Component) DeleteToken(id string) error {
 	return c.tokenTable().Delete("Key", id).Run()
 }
+
+// RecordTokenUse adds uses and sets last use of a token.
+func (c *Component) RecordTokenUse(key, ip string, at time.Time, uses int64) error {
+	update := c.tokenTable().Update("Key", key).
+		Add("Uses", uses).
+		Set("LastUsedOn", at)
+	if ip != "" {
+		// empty strings can't be stored
+		update.Set("LastUsedIP", ip)
+	}
+	return update.If("attribute_exists('Key')").Run()
+}
@@ -13,93 +13,100 @@ import (
 	maintenance "github.com/gliderlabs/cmd/lib/maint"
 )
 
-// ensureTableExists creates a DynamoDB table with a given
-// DynamoDB client. If the table already exists, it is not
-// being reconfigured.
-func ensureTableExists(client *dynamodb.DynamoDB, table string, readCapacity, writeCapacity int) error {
+// tableSchema is the primary key of a table, with an optional range key,
+// and the attribute its items expire at if ttl is set.
+type tableSchema struct {
+	hash, hashType string
+	rng, rangeType string
+	ttl            string
+}
+
+// Schemas of the store tables
+var (
+	cmdSchema       = tableSchema{hash: "User", hashType: "S", rng: "Name", rangeType: "S"}
+	tokenSchema     = tableSchema{hash: "Key", hashType: "S"}
+	revisionSchema  = tableSchema{hash: "Cmd", hashType: "S", rng: "Revision", rangeType: "N"}
+	scheduleSchema  = tableSchema{hash: "ID", hashType: "S"}
+	runSchema       = tableSchema{hash: "Cmd", hashType: "S", rng: "ID", rangeType: "S"}
+	keySchema       = tableSchema{hash: "User", hashType: "S", rng: "Fingerprint", rangeType: "S"}
+	signatureSchema = tableSchema{hash: "Signature", hashType: "S", ttl: "Expires"}
+)
+
+// ensureTable creates a DynamoDB table with schema using a given DynamoDB
+// client, returning true if it was created. If the table already exists, it
+// is not being reconfigured.
+func ensureTable(client *dynamodb.DynamoDB, table string, schema tableSchema, readCapacity, writeCapacity int) (bool, error) {
 	_, err := client.DescribeTable(&dynamodb.DescribeTableInput{
 		TableName: aws.String(table),
 	})
-	if awserr, ok := err.(awserr.Error); ok {
-		if awserr.Code() == "ResourceNotFoundException" {
-			_, err = client.CreateTable(&dynamodb.CreateTableInput{
-				TableName: aws.String(table),
-				ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-					ReadCapacityUnits:  aws.Int64(int64(readCapacity)),
-					WriteCapacityUnits: aws.Int64(int64(writeCapacity)),
-				},
-				KeySchema: []*dynamodb.KeySchemaElement{{
-					AttributeName: aws.String("User"),
-					KeyType:       aws.String("HASH"),
-				}, {
-					AttributeName: aws.String("Name"),
-					KeyType:       aws.String("RANGE"),
-				}},
-				AttributeDefinitions: []*dynamodb.AttributeDefinition{{
-					AttributeName: aws.String("User"),
-					AttributeType: aws.String("S"),
-				}, {
-					AttributeName: aws.String("Name"),
-					AttributeType: aws.String("S"),
-				}},
-			})
-			if err != nil {
-				return err
-			}
-			err = client.WaitUntilTableExists(&dynamodb.DescribeTableInput{
-				TableName: aws.String(table),
-			})
-			if err != nil {
-				return err
-			}
-			if aws.StringValue(client.Config.Region) == "local" {
-				log.Info("skipping unsupported dynamodb-local operation", log.Fields{"operation": "setTableVersion"})
-				return nil
-			}
-			return setTableVersion(client, table, latestVesion)
+	awserr, ok := err.(awserr.Error)
+	if !ok || awserr.Code() != "ResourceNotFoundException" {
+		return false, err
+	}
+	keys := []*dynamodb.KeySchemaElement{{
+		AttributeName: aws.String(schema.hash),
+		KeyType:       aws.String("HASH"),
+	}}
+	attrs := []*dynamodb.AttributeDefinition{{
+		AttributeName: aws.String(schema.hash),
+		AttributeType: aws.String(schema.hashType),
+	}}
+	if schema.rng != "" {
+		keys = append(keys, &dynamodb.KeySchemaElement{
+			AttributeName: aws.String(schema.rng),
+			KeyType:       aws.String("RANGE"),
+		})
+		attrs = append(attrs, &dynamodb.AttributeDefinition{
+			AttributeName: aws.String(schema.rng),
+			AttributeType: aws.String(schema.rangeType),
+		})
+	}
+	_, err = client.CreateTable(&dynamodb.CreateTableInput{
+		TableName: aws.String(table),
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(int64(readCapacity)),
+			WriteCapacityUnits: aws.Int64(int64(writeCapacity)),
+		},
+		KeySchema:            keys,
+		AttributeDefinitions: attrs,
+	})
+	if err != nil {
+		return false, err
+	}
+	err = client.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+		TableName: aws.String(table),
+	})
+	if err != nil {
+		return false, err
+	}
+	if schema.ttl != "" {
+		_, err = client.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String(table),
+			TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+				AttributeName: aws.String(schema.ttl),
+				Enabled:       aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return false, err
 		}
 	}
-
-	return err
+	return true, nil
 }
 
-// ensureTokenTableExists creates a DynamoDB table with a given
+// ensureTableExists creates the command table with a given
 // DynamoDB client. If the table already exists, it is not
 // being reconfigured.
-func ensureTokenTableExists(client *dynamodb.DynamoDB, table string, readCapacity, writeCapacity int) error {
-	_, err := client.DescribeTable(&dynamodb.DescribeTableInput{
-		TableName: aws.String(table),
-	})
-	if awserr, ok := err.(awserr.Error); ok {
-		if awserr.Code() == "ResourceNotFoundException" {
-			_, err = client.CreateTable(&dynamodb.CreateTableInput{
-				TableName: aws.String(table),
-				ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-					ReadCapacityUnits:  aws.Int64(int64(readCapacity)),
-					WriteCapacityUnits: aws.Int64(int64(writeCapacity)),
-				},
-				KeySchema: []*dynamodb.KeySchemaElement{{
-					AttributeName: aws.String("Key"),
-					KeyType:       aws.String("HASH"),
-				}},
-				AttributeDefinitions: []*dynamodb.AttributeDefinition{{
-					AttributeName: aws.String("Key"),
-					AttributeType: aws.String("S"),
-				}},
-			})
-			if err != nil {
-				return err
-			}
-			err = client.WaitUntilTableExists(&dynamodb.DescribeTableInput{
-				TableName: aws.String(table),
-			})
-			if err != nil {
-				return err
-			}
-		}
+func ensureTableExists(client *dynamodb.DynamoDB, table string, readCapacity, writeCapacity int) error {
+	created, err := ensureTable(client, table, cmdSchema, readCapacity, writeCapacity)
+	if err != nil || !created {
+		return err
 	}
-
-	return err
+	if aws.StringValue(client.Config.Region) == "local" {
+		log.Info("skipping unsupported dynamodb-local operation", log.Fields{"operation": "setTableVersion"})
+		return nil
+	}
+	return setTableVersion(client, table, latestVesion)
 }
 
 func setTableVersion(client *dynamodb.DynamoDB, name string, version int) error {
@@ -0,0 +1,143 @@
+package filesystem
+
+import (
+	"encoding/json"
+
+	"github.com/boltdb/bolt"
+	"github.com/gliderlabs/comlab/pkg/log"
+	"github.com/pkg/errors"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+)
+
+// List all commands for a given user.
+func (c *Component) List(user string) []*core.Command {
+	var cmds []*core.Command
+	err := c.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(cmdBucket).Bucket([]byte(user))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var cmd core.Command
+			if err := json.Unmarshal(v, &cmd); err != nil {
+				log.Debug(err)
+				return nil
+			}
+			cmds = append(cmds, &cmd)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Info(errors.Wrapf(err, "unable to list commands for user: %s", user))
+		return nil
+	}
+	return cmds
+}
+
+// Get command by name for given user
+func (c *Component) Get(user, name string) *core.Command {
+	var cmd *core.Command
+	err := c.view(func(tx *bolt.Tx) (err error) {
+		cmd, err = getCmd(tx, user, name)
+		return
+	})
+	if err != nil {
+		log.Debug(err)
+		return nil
+	}
+	return cmd
+}
+
+// Put command with name for given user.
+func (c *Component) Put(user, name string, cmd *core.Command) error {
+	if cmd == nil {
+		return errors.New("unable to put nil command")
+	}
+	return c.update(func(tx *bolt.Tx) error {
+		return putCmd(tx, user, name, cmd)
+	})
+}
+
+// Delete command by name for given user
+func (c *Component) Delete(user, name string) error {
+	return c.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(cmdBucket).Bucket([]byte(user))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(name))
+	})
+}
+
+// updateCmd applies fn to the stored command within a single transaction.
+func (c *Component) updateCmd(owner, name string, fn func(*core.Command)) error {
+	return c.update(func(tx *bolt.Tx) error {
+		cmd, err := getCmd(tx, owner, name)
+		if err != nil {
+			return err
+		}
+		if cmd == nil {
+			return errors.Errorf("command not found: %s/%s", owner, name)
+		}
+		fn(cmd)
+		return putCmd(tx, owner, name, cmd)
+	})
+}
+
+// GrantAccess to a command, for each subject
+func (c *Component) GrantAccess(owner, name string, subject ...string) error {
+	return c.updateCmd(owner, name, func(cmd *core.Command) {
+		cmd.ACL = store.SetAdd(cmd.ACL, subject...)
+	})
+}
+
+// RevokeAccess to a command, for each subject
+func (c *Component) RevokeAccess(owner, name string, subject ...string) error {
+	return c.updateCmd(owner, name, func(cmd *core.Command) {
+		cmd.ACL = store.SetRemove(cmd.ACL, subject...)
+	})
+}
+
+// GrantAdmin to a command, for each subject
+func (c *Component) GrantAdmin(owner, name string, subject ...string) error {
+	return c.updateCmd(owner, name, func(cmd *core.Command) {
+		cmd.Admins = store.SetAdd(cmd.Admins, subject...)
+	})
+}
+
+// RevokeAdmin to a command, for each subject
+func (c *Component) RevokeAdmin(owner, name string, subject ...string) error {
+	return c.updateCmd(owner, name, func(cmd *core.Command) {
+		cmd.Admins = store.SetRemove(cmd.Admins, subject...)
+	})
+}
+
+func getCmd(tx *bolt.Tx, user, name string) (*core.Command, error) {
+	b := tx.Bucket(cmdBucket).Bucket([]byte(user))
+	if b == nil {
+		return nil, nil
+	}
+	v := b.Get([]byte(name))
+	if v == nil {
+		return nil, nil
+	}
+	var cmd core.Command
+	if err := json.Unmarshal(v, &cmd); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode cmd: %s from user: %s", name, user)
+	}
+	return &cmd, nil
+}
+
+func putCmd(tx *bolt.Tx, user, name string, cmd *core.Command) error {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	b, err := tx.Bucket(cmdBucket).CreateBucketIfNotExists([]byte(user))
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(name), data)
+}
@@ -0,0 +1,92 @@
+package filesystem
+
+import (
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	com.Register("store.filesystem", &Component{},
+		com.Option("path", "cmd.db", "path to database file for filesystem store"),
+		com.Option("lock_timeout", 5, "seconds to wait for lock on database file"),
+	)
+}
+
+var (
+	cmdBucket       = []byte("cmds")
+	tokenBucket     = []byte("tokens")
+	revisionBucket  = []byte("revisions")
+	scheduleBucket  = []byte("schedules")
+	runBucket       = []byte("runs")
+	keyBucket       = []byte("keys")
+	signatureBucket = []byte("signatures")
+)
+
+// Component implements a store backend
+type Component struct {
+	mu sync.Mutex
+	db *bolt.DB
+}
+
+// open returns the database, opening the configured file and creating any
+// missing top level buckets on first use.
+func (c *Component) open() (*bolt.DB, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.db != nil {
+		return c.db, nil
+	}
+	path := com.GetString("path")
+	db, err := bolt.Open(path, 0600, &bolt.Options{
+		Timeout: time.Duration(com.GetInt("lock_timeout")) * time.Second,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open database %q", path)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{cmdBucket, tokenBucket, revisionBucket, scheduleBucket, runBucket, keyBucket, signatureBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "unable to setup database %q", path)
+	}
+	c.db = db
+	return c.db, nil
+}
+
+// Close the database file if open.
+func (c *Component) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.db == nil {
+		return nil
+	}
+	err := c.db.Close()
+	c.db = nil
+	return err
+}
+
+func (c *Component) view(fn func(*bolt.Tx) error) error {
+	db, err := c.open()
+	if err != nil {
+		return err
+	}
+	return db.View(fn)
+}
+
+func (c *Component) update(fn func(*bolt.Tx) error) error {
+	db, err := c.open()
+	if err != nil {
+		return err
+	}
+	return db.Update(fn)
+}
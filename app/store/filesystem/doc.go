@@ -0,0 +1,17 @@
+// Package filesystem implements an embedded store backend persisting commands
+// and tokens to a single BoltDB file, requiring no external services.
+//
+// Commands are stored as JSON under a bucket per owner nested within the
+// "cmds" bucket, keyed by command name. Tokens are stored as JSON within the
+// "tokens" bucket keyed by token key. Revisions are stored as JSON under a
+// bucket per command nested within the "revisions" bucket, keyed by revision
+// number. Schedules are stored as JSON within the "schedules" bucket keyed by
+// schedule ID. Runs are stored as JSON under a bucket per command nested
+// within the "runs" bucket, keyed by run ID. SSH keys are stored as JSON
+// under a bucket per user nested within the "keys" bucket, keyed by
+// fingerprint. Used request signatures are stored within the "signatures"
+// bucket keyed by signature, with the time they expire as the value.
+//
+// The database file location is set with the "path" option of the
+// filesystem section.
+package filesystem
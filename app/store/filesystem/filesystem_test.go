@@ -0,0 +1,68 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/com/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/app/store/storetest"
+)
+
+func setupComponent(t *testing.T) (*Component, func()) {
+	dir, err := ioutil.TempDir("", "cmd-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("FILESYSTEM_PATH", filepath.Join(dir, "cmd.db"))
+	cfg := viper.NewConfig()
+	cfg.AutomaticEnv()
+	cfg.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	com.SetConfig(cfg)
+	c := &Component{}
+	return c, func() {
+		c.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBackend(t *testing.T) {
+	assert.Implements(t, new(store.Backend), new(Component))
+
+	c, cleanup := setupComponent(t)
+	defer cleanup()
+	storetest.TestBackend(t, c)
+}
+
+func TestReopen(t *testing.T) {
+	c, cleanup := setupComponent(t)
+	defer cleanup()
+
+	assert.NoError(t, c.Put("user", "cmd", &core.Command{User: "user", Name: "cmd"}))
+	assert.NoError(t, c.GrantAdmin("user", "cmd", "foo"))
+	assert.NoError(t, c.PutToken(&core.Token{Key: "key", User: "user"}))
+	assert.NoError(t, c.Close())
+
+	cmd := c.Get("user", "cmd")
+	if assert.NotNil(t, cmd) {
+		assert.EqualValues(t, []string{"foo"}, cmd.Admins)
+	}
+	token, err := c.GetToken("key")
+	assert.NoError(t, err)
+	assert.NotNil(t, token)
+}
+
+func TestGrantMissing(t *testing.T) {
+	c, cleanup := setupComponent(t)
+	defer cleanup()
+
+	assert.Error(t, c.GrantAccess("user", "missing", "foo"))
+	assert.Error(t, c.GrantAdmin("user", "missing", "foo"))
+}
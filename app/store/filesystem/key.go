@@ -0,0 +1,59 @@
+package filesystem
+
+import (
+	"encoding/json"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/gliderlabs/cmd/app/core"
+)
+
+// ListKeys of user ordered by fingerprint.
+func (c *Component) ListKeys(user string) ([]*core.Key, error) {
+	var keys []*core.Key
+	err := c.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(keyBucket).Bucket([]byte(user))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var key core.Key
+			if err := json.Unmarshal(v, &key); err != nil {
+				return errors.Wrapf(err, "unable to decode key: %s", k)
+			}
+			keys = append(keys, &key)
+			return nil
+		})
+	})
+	return keys, err
+}
+
+// PutKey ...
+func (c *Component) PutKey(key *core.Key) error {
+	if err := key.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return c.update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(keyBucket).CreateBucketIfNotExists([]byte(key.User))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key.Fingerprint), data)
+	})
+}
+
+// DeleteKey of user by fingerprint.
+func (c *Component) DeleteKey(user, fingerprint string) error {
+	return c.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(keyBucket).Bucket([]byte(user))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(fingerprint))
+	})
+}
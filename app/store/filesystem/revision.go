@@ -0,0 +1,92 @@
+package filesystem
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/gliderlabs/cmd/app/core"
+)
+
+// ListRevisions of a command, oldest first.
+func (c *Component) ListRevisions(user, name string) ([]*core.Revision, error) {
+	var revs []*core.Revision
+	err := c.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(revisionBucket).Bucket([]byte(core.RevisionKey(user, name)))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var rev core.Revision
+			if err := json.Unmarshal(v, &rev); err != nil {
+				return errors.Wrapf(err, "unable to decode revision: %d", binary.BigEndian.Uint64(k))
+			}
+			revs = append(revs, &rev)
+			return nil
+		})
+	})
+	return revs, err
+}
+
+// GetRevision of a command by number.
+func (c *Component) GetRevision(user, name string, revision int) (*core.Revision, error) {
+	var rev *core.Revision
+	err := c.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(revisionBucket).Bucket([]byte(core.RevisionKey(user, name)))
+		if b == nil || revision < 1 {
+			return ErrNotFound
+		}
+		v := b.Get(revisionID(uint64(revision)))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &rev)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// PutRevision assigning the next revision number.
+func (c *Component) PutRevision(rev *core.Revision) error {
+	if rev == nil || rev.Cmd == "" {
+		return errors.New("revision Cmd required")
+	}
+	return c.update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(revisionBucket).CreateBucketIfNotExists([]byte(rev.Cmd))
+		if err != nil {
+			return err
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		rev.Revision = int(seq)
+		data, err := json.Marshal(rev)
+		if err != nil {
+			return err
+		}
+		return b.Put(revisionID(seq), data)
+	})
+}
+
+// DeleteRevisions of a command.
+func (c *Component) DeleteRevisions(user, name string) error {
+	return c.update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(revisionBucket).DeleteBucket([]byte(core.RevisionKey(user, name)))
+		if err == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// revisionID returns a key for revision which sorts numerically.
+func revisionID(revision uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, revision)
+	return b
+}
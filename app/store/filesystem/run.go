@@ -0,0 +1,88 @@
+package filesystem
+
+import (
+	"encoding/json"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+)
+
+// ListRuns of a command newest first.
+func (c *Component) ListRuns(user, name string, limit int) ([]*core.RunRecord, error) {
+	var runs []*core.RunRecord
+	err := c.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(runBucket).Bucket([]byte(core.RevisionKey(user, name)))
+		if b == nil {
+			return nil
+		}
+		cur := b.Cursor()
+		for k, v := cur.Last(); k != nil; k, v = cur.Prev() {
+			if limit > 0 && len(runs) == limit {
+				break
+			}
+			var run core.RunRecord
+			if err := json.Unmarshal(v, &run); err != nil {
+				return errors.Wrapf(err, "unable to decode run: %s", k)
+			}
+			runs = append(runs, &run)
+		}
+		return nil
+	})
+	return runs, err
+}
+
+// GetRun of a command by id.
+func (c *Component) GetRun(user, name, id string) (*core.RunRecord, error) {
+	var run *core.RunRecord
+	err := c.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(runBucket).Bucket([]byte(core.RevisionKey(user, name)))
+		if b == nil {
+			return ErrNotFound
+		}
+		v := b.Get([]byte(id))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &run)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// PutRun discarding the oldest runs beyond store.MaxRuns.
+func (c *Component) PutRun(run *core.RunRecord) error {
+	if run == nil || run.Cmd == "" || run.ID == "" {
+		return errors.New("run Cmd and ID required")
+	}
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	return c.update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(runBucket).CreateBucketIfNotExists([]byte(run.Cmd))
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(run.ID), data); err != nil {
+			return err
+		}
+		// keys sort oldest first, so collect all but the newest MaxRuns
+		var keys [][]byte
+		cur := b.Cursor()
+		for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for len(keys) > store.MaxRuns {
+			if err := b.Delete(keys[0]); err != nil {
+				return err
+			}
+			keys = keys[1:]
+		}
+		return nil
+	})
+}
@@ -0,0 +1,96 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/gliderlabs/cmd/app/core"
+)
+
+// ListSchedules created by user, or all schedules if user is empty.
+func (c *Component) ListSchedules(user string) ([]*core.Schedule, error) {
+	var schedules []*core.Schedule
+	err := c.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(scheduleBucket).ForEach(func(k, v []byte) error {
+			var schedule core.Schedule
+			if err := json.Unmarshal(v, &schedule); err != nil {
+				return errors.Wrapf(err, "unable to decode schedule: %s", k)
+			}
+			if user == "" || schedule.User == user {
+				schedules = append(schedules, &schedule)
+			}
+			return nil
+		})
+	})
+	return schedules, err
+}
+
+// GetSchedule by id.
+func (c *Component) GetSchedule(id string) (*core.Schedule, error) {
+	var schedule *core.Schedule
+	err := c.view(func(tx *bolt.Tx) error {
+		v := tx.Bucket(scheduleBucket).Get([]byte(id))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &schedule)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// PutSchedule ...
+func (c *Component) PutSchedule(schedule *core.Schedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+	return c.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(scheduleBucket).Put([]byte(schedule.ID), data)
+	})
+}
+
+// ClaimSchedule storing it if NextRun is still prev. The check and put are
+// made in one transaction.
+func (c *Component) ClaimSchedule(schedule *core.Schedule, prev time.Time) (bool, error) {
+	if err := schedule.Validate(); err != nil {
+		return false, err
+	}
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return false, err
+	}
+	var claimed bool
+	err = c.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(scheduleBucket)
+		v := b.Get([]byte(schedule.ID))
+		if v == nil {
+			return nil
+		}
+		var current core.Schedule
+		if err := json.Unmarshal(v, &current); err != nil {
+			return errors.Wrapf(err, "unable to decode schedule: %s", schedule.ID)
+		}
+		if !current.NextRun.Equal(prev) {
+			return nil
+		}
+		claimed = true
+		return b.Put([]byte(schedule.ID), data)
+	})
+	return claimed, err
+}
+
+// DeleteSchedule by id
+func (c *Component) DeleteSchedule(id string) error {
+	return c.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(scheduleBucket).Delete([]byte(id))
+	})
+}
@@ -0,0 +1,43 @@
+package filesystem
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// UseSignature records signature until expires, removing expired
+// signatures in the same transaction.
+func (c *Component) UseSignature(signature string, expires time.Time) (bool, error) {
+	var used bool
+	err := c.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(signatureBucket)
+		now := time.Now()
+		var expired [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var exp time.Time
+			if err := exp.UnmarshalText(v); err != nil || !exp.After(now) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		if b.Get([]byte(signature)) != nil {
+			used = true
+			return nil
+		}
+		data, err := expires.MarshalText()
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(signature), data)
+	})
+	return !used && err == nil, err
+}
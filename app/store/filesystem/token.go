@@ -0,0 +1,92 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/gliderlabs/cmd/app/core"
+)
+
+// ErrNotFound returned when a requested item does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ListTokens for a user.
+func (c *Component) ListTokens(user string) ([]*core.Token, error) {
+	var tokens []*core.Token
+	err := c.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokenBucket).ForEach(func(k, v []byte) error {
+			var token core.Token
+			if err := json.Unmarshal(v, &token); err != nil {
+				return errors.Wrapf(err, "unable to decode token: %s", k)
+			}
+			if token.User == user {
+				tokens = append(tokens, &token)
+			}
+			return nil
+		})
+	})
+	return tokens, err
+}
+
+// GetToken by id.
+func (c *Component) GetToken(id string) (*core.Token, error) {
+	var token *core.Token
+	err := c.view(func(tx *bolt.Tx) error {
+		v := tx.Bucket(tokenBucket).Get([]byte(id))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// PutToken ...
+func (c *Component) PutToken(token *core.Token) error {
+	if err := token.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return c.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokenBucket).Put([]byte(token.Key), data)
+	})
+}
+
+// DeleteToken by id
+func (c *Component) DeleteToken(id string) error {
+	return c.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokenBucket).Delete([]byte(id))
+	})
+}
+
+// RecordTokenUse adds uses and sets last use of a token.
+func (c *Component) RecordTokenUse(key, ip string, at time.Time, uses int64) error {
+	return c.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tokenBucket)
+		v := b.Get([]byte(key))
+		if v == nil {
+			return ErrNotFound
+		}
+		var token core.Token
+		if err := json.Unmarshal(v, &token); err != nil {
+			return errors.Wrapf(err, "unable to decode token: %s", key)
+		}
+		token.Uses += uses
+		token.LastUsedIP = ip
+		token.LastUsedOn = at
+		data, err := json.Marshal(token)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), data)
+	})
+}
@@ -0,0 +1,447 @@
+// Package memory implements an in-memory store backend, useful for tests and
+// trying out cmd locally. Nothing is persisted across restarts.
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/pkg/errors"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+)
+
+func init() {
+	com.Register("store.memory", &Component{})
+}
+
+// ErrNotFound returned when a requested item does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Component implements a store backend
+type Component struct {
+	mu         sync.RWMutex
+	cmds       map[string]map[string]*core.Command
+	tokens     map[string]*core.Token
+	revisions  map[string][]*core.Revision
+	schedules  map[string]*core.Schedule
+	runs       map[string][]*core.RunRecord
+	keys       map[string]map[string]*core.Key
+	signatures map[string]time.Time
+}
+
+// List all commands for a given user.
+func (c *Component) List(user string) []*core.Command {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var cmds []*core.Command
+	for _, cmd := range c.cmds[user] {
+		cmds = append(cmds, copyCmd(cmd))
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+	return cmds
+}
+
+// Get command by name for given user
+func (c *Component) Get(user, name string) *core.Command {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cmd, ok := c.cmds[user][name]
+	if !ok {
+		return nil
+	}
+	return copyCmd(cmd)
+}
+
+// Put command with name for given user.
+func (c *Component) Put(user, name string, cmd *core.Command) error {
+	if cmd == nil {
+		return errors.New("unable to put nil command")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cmds == nil {
+		c.cmds = make(map[string]map[string]*core.Command)
+	}
+	if c.cmds[user] == nil {
+		c.cmds[user] = make(map[string]*core.Command)
+	}
+	c.cmds[user][name] = copyCmd(cmd)
+	return nil
+}
+
+// Delete command by name for given user
+func (c *Component) Delete(user, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cmds[user], name)
+	return nil
+}
+
+func (c *Component) updateCmd(owner, name string, fn func(*core.Command)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cmd, ok := c.cmds[owner][name]
+	if !ok {
+		return errors.Errorf("command not found: %s/%s", owner, name)
+	}
+	fn(cmd)
+	return nil
+}
+
+// GrantAccess to a command, for each subject
+func (c *Component) GrantAccess(owner, name string, subject ...string) error {
+	return c.updateCmd(owner, name, func(cmd *core.Command) {
+		cmd.ACL = store.SetAdd(cmd.ACL, subject...)
+	})
+}
+
+// RevokeAccess to a command, for each subject
+func (c *Component) RevokeAccess(owner, name string, subject ...string) error {
+	return c.updateCmd(owner, name, func(cmd *core.Command) {
+		cmd.ACL = store.SetRemove(cmd.ACL, subject...)
+	})
+}
+
+// GrantAdmin to a command, for each subject
+func (c *Component) GrantAdmin(owner, name string, subject ...string) error {
+	return c.updateCmd(owner, name, func(cmd *core.Command) {
+		cmd.Admins = store.SetAdd(cmd.Admins, subject...)
+	})
+}
+
+// RevokeAdmin to a command, for each subject
+func (c *Component) RevokeAdmin(owner, name string, subject ...string) error {
+	return c.updateCmd(owner, name, func(cmd *core.Command) {
+		cmd.Admins = store.SetRemove(cmd.Admins, subject...)
+	})
+}
+
+// ListTokens for a user.
+func (c *Component) ListTokens(user string) ([]*core.Token, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var tokens []*core.Token
+	for _, token := range c.tokens {
+		if token.User == user {
+			t := *token
+			tokens = append(tokens, &t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Key < tokens[j].Key
+	})
+	return tokens, nil
+}
+
+// GetToken by id.
+func (c *Component) GetToken(id string) (*core.Token, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	token, ok := c.tokens[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	t := *token
+	return &t, nil
+}
+
+// PutToken ...
+func (c *Component) PutToken(token *core.Token) error {
+	if err := token.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens == nil {
+		c.tokens = make(map[string]*core.Token)
+	}
+	t := *token
+	c.tokens[token.Key] = &t
+	return nil
+}
+
+// DeleteToken by id
+func (c *Component) DeleteToken(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tokens, id)
+	return nil
+}
+
+// RecordTokenUse adds uses and sets last use of a token.
+func (c *Component) RecordTokenUse(key, ip string, at time.Time, uses int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	token, ok := c.tokens[key]
+	if !ok {
+		return ErrNotFound
+	}
+	token.Uses += uses
+	token.LastUsedIP = ip
+	token.LastUsedOn = at
+	return nil
+}
+
+// ListRevisions of a command, oldest first.
+func (c *Component) ListRevisions(user, name string) ([]*core.Revision, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var revs []*core.Revision
+	for _, rev := range c.revisions[core.RevisionKey(user, name)] {
+		revs = append(revs, copyRevision(rev))
+	}
+	return revs, nil
+}
+
+// GetRevision of a command by number.
+func (c *Component) GetRevision(user, name string, revision int) (*core.Revision, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	revs := c.revisions[core.RevisionKey(user, name)]
+	if revision < 1 || revision > len(revs) {
+		return nil, ErrNotFound
+	}
+	return copyRevision(revs[revision-1]), nil
+}
+
+// PutRevision assigning the next revision number.
+func (c *Component) PutRevision(rev *core.Revision) error {
+	if rev == nil || rev.Cmd == "" {
+		return errors.New("revision Cmd required")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.revisions == nil {
+		c.revisions = make(map[string][]*core.Revision)
+	}
+	rev.Revision = len(c.revisions[rev.Cmd]) + 1
+	c.revisions[rev.Cmd] = append(c.revisions[rev.Cmd], copyRevision(rev))
+	return nil
+}
+
+// DeleteRevisions of a command.
+func (c *Component) DeleteRevisions(user, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.revisions, core.RevisionKey(user, name))
+	return nil
+}
+
+// ListSchedules created by user, or all schedules if user is empty.
+func (c *Component) ListSchedules(user string) ([]*core.Schedule, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var schedules []*core.Schedule
+	for _, schedule := range c.schedules {
+		if user == "" || schedule.User == user {
+			schedules = append(schedules, copySchedule(schedule))
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ID < schedules[j].ID
+	})
+	return schedules, nil
+}
+
+// GetSchedule by id.
+func (c *Component) GetSchedule(id string) (*core.Schedule, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	schedule, ok := c.schedules[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copySchedule(schedule), nil
+}
+
+// PutSchedule ...
+func (c *Component) PutSchedule(schedule *core.Schedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.schedules == nil {
+		c.schedules = make(map[string]*core.Schedule)
+	}
+	c.schedules[schedule.ID] = copySchedule(schedule)
+	return nil
+}
+
+// ClaimSchedule storing it if NextRun is still prev.
+func (c *Component) ClaimSchedule(schedule *core.Schedule, prev time.Time) (bool, error) {
+	if err := schedule.Validate(); err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current, ok := c.schedules[schedule.ID]
+	if !ok || !current.NextRun.Equal(prev) {
+		return false, nil
+	}
+	c.schedules[schedule.ID] = copySchedule(schedule)
+	return true, nil
+}
+
+// DeleteSchedule by id
+func (c *Component) DeleteSchedule(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.schedules, id)
+	return nil
+}
+
+// ListRuns of a command newest first.
+func (c *Component) ListRuns(user, name string, limit int) ([]*core.RunRecord, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var runs []*core.RunRecord
+	stored := c.runs[core.RevisionKey(user, name)]
+	for i := len(stored) - 1; i >= 0; i-- {
+		if limit > 0 && len(runs) == limit {
+			break
+		}
+		runs = append(runs, copyRun(stored[i]))
+	}
+	return runs, nil
+}
+
+// GetRun of a command by id.
+func (c *Component) GetRun(user, name, id string) (*core.RunRecord, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, run := range c.runs[core.RevisionKey(user, name)] {
+		if run.ID == id {
+			return copyRun(run), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// PutRun discarding the oldest runs beyond store.MaxRuns.
+func (c *Component) PutRun(run *core.RunRecord) error {
+	if run == nil || run.Cmd == "" || run.ID == "" {
+		return errors.New("run Cmd and ID required")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.runs == nil {
+		c.runs = make(map[string][]*core.RunRecord)
+	}
+	runs := append(c.runs[run.Cmd], copyRun(run))
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].ID < runs[j].ID
+	})
+	if len(runs) > store.MaxRuns {
+		runs = runs[len(runs)-store.MaxRuns:]
+	}
+	c.runs[run.Cmd] = runs
+	return nil
+}
+
+// ListKeys of user ordered by fingerprint.
+func (c *Component) ListKeys(user string) ([]*core.Key, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var keys []*core.Key
+	for _, key := range c.keys[user] {
+		k := *key
+		keys = append(keys, &k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Fingerprint < keys[j].Fingerprint
+	})
+	return keys, nil
+}
+
+// PutKey ...
+func (c *Component) PutKey(key *core.Key) error {
+	if err := key.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		c.keys = make(map[string]map[string]*core.Key)
+	}
+	if c.keys[key.User] == nil {
+		c.keys[key.User] = make(map[string]*core.Key)
+	}
+	k := *key
+	c.keys[key.User][key.Fingerprint] = &k
+	return nil
+}
+
+// DeleteKey of user by fingerprint.
+func (c *Component) DeleteKey(user, fingerprint string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.keys[user], fingerprint)
+	return nil
+}
+
+// UseSignature records signature until expires, removing expired
+// signatures.
+func (c *Component) UseSignature(signature string, expires time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.signatures == nil {
+		c.signatures = make(map[string]time.Time)
+	}
+	for s, exp := range c.signatures {
+		if !exp.After(now) {
+			delete(c.signatures, s)
+		}
+	}
+	if _, used := c.signatures[signature]; used {
+		return false, nil
+	}
+	c.signatures[signature] = expires
+	return true, nil
+}
+
+// copyRun returns a copy of run not sharing any slices.
+func copyRun(run *core.RunRecord) *core.RunRecord {
+	cp := *run
+	cp.Args = append([]string(nil), run.Args...)
+	return &cp
+}
+
+// copySchedule returns a copy of schedule not sharing any slices.
+func copySchedule(schedule *core.Schedule) *core.Schedule {
+	cp := *schedule
+	cp.Args = append([]string(nil), schedule.Args...)
+	return &cp
+}
+
+// copyRevision returns a copy of rev not sharing any slices.
+func copyRevision(rev *core.Revision) *core.Revision {
+	cp := *rev
+	cp.EnvKeys = append([]string(nil), rev.EnvKeys...)
+	return &cp
+}
+
+// copyCmd returns a copy of cmd not sharing any maps or slices.
+func copyCmd(cmd *core.Command) *core.Command {
+	cp := *cmd
+	if cmd.Environment != nil {
+		cp.Environment = make(map[string]string, len(cmd.Environment))
+		for k, v := range cmd.Environment {
+			cp.Environment[k] = v
+		}
+	}
+	cp.ACL = append([]string(nil), cmd.ACL...)
+	cp.Admins = append([]string(nil), cmd.Admins...)
+	if cmd.Webhook != nil {
+		webhook := *cmd.Webhook
+		cp.Webhook = &webhook
+	}
+	return &cp
+}
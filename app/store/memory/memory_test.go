@@ -0,0 +1,15 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gliderlabs/cmd/app/store"
+	"github.com/gliderlabs/cmd/app/store/storetest"
+)
+
+func TestBackend(t *testing.T) {
+	assert.Implements(t, new(store.Backend), new(Component))
+	storetest.TestBackend(t, &Component{})
+}
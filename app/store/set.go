@@ -0,0 +1,36 @@
+package store
+
+import "sort"
+
+// SetAdd returns a sorted set of the union of set and items. Backends without
+// native set types use it for GrantAccess and GrantAdmin.
+func SetAdd(set []string, items ...string) []string {
+	seen := make(map[string]bool, len(set)+len(items))
+	var out []string
+	for _, item := range append(append([]string{}, set...), items...) {
+		if seen[item] {
+			continue
+		}
+		seen[item] = true
+		out = append(out, item)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SetRemove returns a sorted set of set without items. Backends without
+// native set types use it for RevokeAccess and RevokeAdmin.
+func SetRemove(set []string, items ...string) []string {
+	remove := make(map[string]bool, len(items))
+	for _, item := range items {
+		remove[item] = true
+	}
+	var out []string
+	for _, item := range set {
+		if !remove[item] {
+			out = append(out, item)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
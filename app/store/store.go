@@ -1,6 +1,8 @@
 package store
 
 import (
+	"time"
+
 	"github.com/gliderlabs/cmd/app/core"
 
 	"github.com/gliderlabs/comlab/pkg/com"
@@ -11,6 +13,9 @@ func init() {
 		com.Option("backend", "store.filesystem", "Store backend"))
 }
 
+// MaxRuns is the number of most recent runs kept for each command.
+var MaxRuns = 100
+
 func Selected() Backend {
 	backend := com.Select(com.GetString("backend"), new(Backend))
 	if backend == nil {
@@ -22,6 +27,11 @@ func Selected() Backend {
 type Backend interface {
 	CmdBackend
 	TokenBackend
+	RevisionBackend
+	ScheduleBackend
+	RunBackend
+	KeyBackend
+	SignatureBackend
 }
 
 type CmdBackend interface {
@@ -40,4 +50,58 @@ type TokenBackend interface {
 	GetToken(key string) (*core.Token, error)
 	PutToken(token *core.Token) error
 	DeleteToken(key string) error
+	// RecordTokenUse adds uses to the use count of a token and sets when and
+	// where it was last used.
+	RecordTokenUse(key, ip string, at time.Time, uses int64) error
+}
+
+// RevisionBackend records the revision history of commands. Revisions are
+// immutable and numbered from 1 in the order they are recorded.
+type RevisionBackend interface {
+	ListRevisions(user, name string) ([]*core.Revision, error)
+	GetRevision(user, name string, revision int) (*core.Revision, error)
+	// PutRevision assigns the next revision number for the command to rev
+	// before storing it.
+	PutRevision(rev *core.Revision) error
+	// DeleteRevisions of a command so a command later created with the same
+	// name starts a new history.
+	DeleteRevisions(user, name string) error
+}
+
+// ScheduleBackend stores schedules for running commands.
+type ScheduleBackend interface {
+	// ListSchedules created by user, or all schedules if user is empty.
+	ListSchedules(user string) ([]*core.Schedule, error)
+	GetSchedule(id string) (*core.Schedule, error)
+	PutSchedule(schedule *core.Schedule) error
+	// ClaimSchedule stores schedule only if its stored NextRun is still prev,
+	// returning false if it was claimed by another scheduler or deleted. This
+	// ensures only one scheduler starts each run.
+	ClaimSchedule(schedule *core.Schedule, prev time.Time) (bool, error)
+	DeleteSchedule(id string) error
+}
+
+// RunBackend records the run history of commands, keeping the most recent
+// MaxRuns runs of each command.
+type RunBackend interface {
+	// ListRuns of a command newest first, returning at most limit runs unless
+	// limit is zero.
+	ListRuns(user, name string, limit int) ([]*core.RunRecord, error)
+	GetRun(user, name, id string) (*core.RunRecord, error)
+	PutRun(run *core.RunRecord) error
+}
+
+// SignatureBackend records the signatures of signed requests, so each is
+// only accepted once by any instance.
+type SignatureBackend interface {
+	// UseSignature records signature until expires, returning false if it
+	// was already recorded and has not expired.
+	UseSignature(signature string, expires time.Time) (bool, error)
+}
+
+// KeyBackend stores SSH public keys users may authenticate with.
+type KeyBackend interface {
+	ListKeys(user string) ([]*core.Key, error)
+	PutKey(key *core.Key) error
+	DeleteKey(user, fingerprint string) error
 }
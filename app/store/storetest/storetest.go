@@ -0,0 +1,836 @@
+// Package storetest implements a conformance suite for store backends.
+//
+// Backends run the suite from their own tests against an empty instance:
+//
+//	func TestBackend(t *testing.T) {
+//		storetest.TestBackend(t, &Component{})
+//	}
+package storetest
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gliderlabs/cmd/app/core"
+	"github.com/gliderlabs/cmd/app/store"
+)
+
+// Concurrency is the number of goroutines used by concurrency tests.
+var Concurrency = 20
+
+// TestBackend runs the command and token suites against backend.
+func TestBackend(t *testing.T, backend store.Backend) {
+	t.Run("Cmd", func(t *testing.T) {
+		TestCmdBackend(t, backend)
+	})
+	t.Run("Token", func(t *testing.T) {
+		TestTokenBackend(t, backend)
+	})
+	t.Run("Revision", func(t *testing.T) {
+		TestRevisionBackend(t, backend)
+	})
+	t.Run("Schedule", func(t *testing.T) {
+		TestScheduleBackend(t, backend)
+	})
+	t.Run("Run", func(t *testing.T) {
+		TestRunBackend(t, backend)
+	})
+	t.Run("Key", func(t *testing.T) {
+		TestKeyBackend(t, backend)
+	})
+	t.Run("Signature", func(t *testing.T) {
+		TestSignatureBackend(t, backend)
+	})
+}
+
+// TestCmdBackend runs the command suite against backend.
+func TestCmdBackend(t *testing.T, backend store.CmdBackend) {
+	var (
+		user  = "storetest-user"
+		user2 = "storetest-user2"
+		name  = "cmd"
+	)
+
+	t.Run("Put", func(t *testing.T) {
+		assert.NoError(t, backend.Put(user, name, &core.Command{
+			User:        user,
+			Name:        name,
+			Source:      "#!cmd alpine\n#!/bin/sh\necho hello",
+			Description: "description",
+			Environment: map[string]string{"FOO": "bar"},
+		}))
+	})
+
+	t.Run("PutNil", func(t *testing.T) {
+		assert.Error(t, backend.Put(user, name, nil))
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		cmd := backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			assert.Equal(t, user, cmd.User)
+			assert.Equal(t, name, cmd.Name)
+			assert.Equal(t, "#!cmd alpine\n#!/bin/sh\necho hello", cmd.Source)
+			assert.Equal(t, "description", cmd.Description)
+			assert.Equal(t, map[string]string{"FOO": "bar"}, cmd.Environment)
+		}
+	})
+
+	t.Run("GetMissing", func(t *testing.T) {
+		assert.Nil(t, backend.Get(user, "missing"))
+		assert.Nil(t, backend.Get("storetest-missing", name))
+	})
+
+	t.Run("GetCopy", func(t *testing.T) {
+		cmd := backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			cmd.Source = "modified"
+			cmd.Environment["FOO"] = "modified"
+		}
+		cmd = backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			assert.NotEqual(t, "modified", cmd.Source,
+				"Changes must not be stored without Put")
+			assert.Equal(t, "bar", cmd.Environment["FOO"],
+				"Changes must not be stored without Put")
+		}
+	})
+
+	t.Run("PutReplace", func(t *testing.T) {
+		cmd := backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			cmd.Description = "replaced"
+			assert.NoError(t, backend.Put(user, name, cmd))
+		}
+		cmd = backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			assert.Equal(t, "replaced", cmd.Description)
+		}
+	})
+
+	t.Run("PutWebhook", func(t *testing.T) {
+		cmd := backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			cmd.Webhook = &core.Webhook{Provider: core.WebhookGitHub, Secret: "secret"}
+			assert.NoError(t, backend.Put(user, name, cmd))
+		}
+		cmd = backend.Get(user, name)
+		if assert.NotNil(t, cmd) && assert.NotNil(t, cmd.Webhook) {
+			assert.Equal(t, core.WebhookGitHub, cmd.Webhook.Provider)
+			assert.Equal(t, "secret", cmd.Webhook.Secret)
+			cmd.Webhook = nil
+			assert.NoError(t, backend.Put(user, name, cmd))
+		}
+		cmd = backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			assert.Nil(t, cmd.Webhook)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		assert.NoError(t, backend.Put(user2, name, &core.Command{
+			User: user2,
+			Name: name,
+		}))
+		assert.NoError(t, backend.Put(user, "cmd2", &core.Command{
+			User: user,
+			Name: "cmd2",
+		}))
+
+		cmds := backend.List(user)
+		var names []string
+		for _, cmd := range cmds {
+			assert.Equal(t, user, cmd.User,
+				"Result should only contain commands owned by user")
+			names = append(names, cmd.Name)
+		}
+		assertSet(t, []string{name, "cmd2"}, names)
+		assert.Empty(t, backend.List("storetest-missing"))
+	})
+
+	t.Run("GrantAccess", func(t *testing.T) {
+		assert.NoError(t, backend.GrantAccess(user, name, "foo"))
+		cmd := backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			assertSet(t, []string{"foo"}, cmd.ACL)
+		}
+		assert.NoError(t, backend.GrantAccess(user, name, "bar", "foo"))
+		cmd = backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			assertSet(t, []string{"bar", "foo"}, cmd.ACL)
+			assert.True(t, cmd.HasAccess("bar"))
+			assert.False(t, cmd.IsAdmin("bar"))
+		}
+	})
+
+	t.Run("RevokeAccess", func(t *testing.T) {
+		assert.NoError(t, backend.RevokeAccess(user, name, "foo"))
+		cmd := backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			assertSet(t, []string{"bar"}, cmd.ACL)
+			assert.False(t, cmd.HasAccess("foo"))
+		}
+	})
+
+	t.Run("GrantAdmin", func(t *testing.T) {
+		assert.NoError(t, backend.GrantAdmin(user, name, "foo"))
+		cmd := backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			assertSet(t, []string{"foo"}, cmd.Admins)
+		}
+		assert.NoError(t, backend.GrantAdmin(user, name, "bar"))
+		cmd = backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			assertSet(t, []string{"bar", "foo"}, cmd.Admins)
+			assertSet(t, []string{"bar"}, cmd.ACL,
+				"Granting admin should not modify ACL")
+			assert.True(t, cmd.IsAdmin("foo"))
+		}
+	})
+
+	t.Run("RevokeAdmin", func(t *testing.T) {
+		assert.NoError(t, backend.RevokeAdmin(user, name, "foo"))
+		cmd := backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			assertSet(t, []string{"bar"}, cmd.Admins)
+			assert.False(t, cmd.IsAdmin("foo"))
+		}
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		var (
+			wg       sync.WaitGroup
+			subjects []string
+		)
+		for i := 0; i < Concurrency; i++ {
+			subject := fmt.Sprintf("subject%d", i)
+			subjects = append(subjects, subject)
+			wg.Add(1)
+			go func(i int, subject string) {
+				defer wg.Done()
+				assert.NoError(t, backend.GrantAccess(user, name, subject))
+				concurrent := fmt.Sprintf("concurrent%d", i)
+				assert.NoError(t, backend.Put(user2, concurrent, &core.Command{
+					User: user2,
+					Name: concurrent,
+				}))
+				backend.Get(user, name)
+				backend.List(user2)
+			}(i, subject)
+		}
+		wg.Wait()
+
+		cmd := backend.Get(user, name)
+		if assert.NotNil(t, cmd) {
+			assertSet(t, append(subjects, "bar"), cmd.ACL,
+				"Concurrent grants must not be lost")
+		}
+		assert.Len(t, backend.List(user2), Concurrency+1)
+		for i := 0; i < Concurrency; i++ {
+			assert.NoError(t, backend.Delete(user2, fmt.Sprintf("concurrent%d", i)))
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, backend.Delete(user, name))
+		assert.NoError(t, backend.Delete(user, "cmd2"))
+		assert.NoError(t, backend.Delete(user2, name))
+		assert.Nil(t, backend.Get(user, name))
+		assert.Empty(t, backend.List(user))
+		assert.NoError(t, backend.Delete(user, name),
+			"Deleting a missing command should not fail")
+	})
+}
+
+// TestTokenBackend runs the token suite against backend.
+func TestTokenBackend(t *testing.T, backend store.TokenBackend) {
+	var (
+		user  = "storetest-user"
+		user2 = "storetest-user2"
+	)
+
+	t.Run("PutToken", func(t *testing.T) {
+		assert.NoError(t, backend.PutToken(&core.Token{
+			Key:         "storetest-key",
+			User:        user,
+			Description: "description",
+		}))
+		assert.NoError(t, backend.PutToken(&core.Token{
+			Key:  "storetest-key2",
+			User: user2,
+		}))
+	})
+
+	t.Run("GetToken", func(t *testing.T) {
+		token, err := backend.GetToken("storetest-key")
+		assert.NoError(t, err)
+		if assert.NotNil(t, token) {
+			assert.Equal(t, "storetest-key", token.Key)
+			assert.Equal(t, user, token.User)
+			assert.Equal(t, "description", token.Description)
+		}
+	})
+
+	t.Run("GetTokenMissing", func(t *testing.T) {
+		token, err := backend.GetToken("storetest-missing")
+		assert.Error(t, err)
+		assert.Nil(t, token)
+	})
+
+	t.Run("PutTokenReplace", func(t *testing.T) {
+		assert.NoError(t, backend.PutToken(&core.Token{
+			Key:         "storetest-key",
+			User:        user,
+			Description: "replaced",
+		}))
+		token, err := backend.GetToken("storetest-key")
+		assert.NoError(t, err)
+		if assert.NotNil(t, token) {
+			assert.Equal(t, "replaced", token.Description)
+		}
+	})
+
+	t.Run("PutTokenScope", func(t *testing.T) {
+		expires := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+		assert.NoError(t, backend.PutToken(&core.Token{
+			Key:       "storetest-key",
+			User:      user,
+			ExpiresOn: expires,
+			Cmds:      []string{"storetest-cmd"},
+			CIDRs:     []string{"10.0.0.0/8"},
+			NoStdin:   true,
+		}))
+		token, err := backend.GetToken("storetest-key")
+		assert.NoError(t, err)
+		if assert.NotNil(t, token) {
+			assert.True(t, expires.Equal(token.ExpiresOn), "expected %s got %s", expires, token.ExpiresOn)
+			assert.Equal(t, []string{"storetest-cmd"}, token.Cmds)
+			assert.Equal(t, []string{"10.0.0.0/8"}, token.CIDRs)
+			assert.True(t, token.NoStdin)
+		}
+	})
+
+	t.Run("RecordTokenUse", func(t *testing.T) {
+		at := time.Now().UTC().Truncate(time.Second)
+		assert.NoError(t, backend.RecordTokenUse("storetest-key", "192.0.2.1", at, 2))
+		assert.NoError(t, backend.RecordTokenUse("storetest-key", "192.0.2.2", at, 3))
+		token, err := backend.GetToken("storetest-key")
+		assert.NoError(t, err)
+		if assert.NotNil(t, token) {
+			assert.Equal(t, int64(5), token.Uses)
+			assert.Equal(t, "192.0.2.2", token.LastUsedIP)
+			assert.True(t, at.Equal(token.LastUsedOn), "expected %s got %s", at, token.LastUsedOn)
+			assert.True(t, token.NoStdin, "Recording use should keep other fields")
+		}
+		assert.Error(t, backend.RecordTokenUse("storetest-missing", "", at, 1))
+	})
+
+	t.Run("ListTokens", func(t *testing.T) {
+		tokens, err := backend.ListTokens(user)
+		assert.NoError(t, err)
+		if assert.Len(t, tokens, 1) {
+			assert.Equal(t, "storetest-key", tokens[0].Key)
+		}
+		tokens, err = backend.ListTokens("storetest-missing")
+		assert.NoError(t, err)
+		assert.Empty(t, tokens)
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < Concurrency; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				key := fmt.Sprintf("storetest-concurrent%d", i)
+				assert.NoError(t, backend.PutToken(&core.Token{Key: key, User: user2}))
+				_, err := backend.GetToken(key)
+				assert.NoError(t, err)
+				_, err = backend.ListTokens(user2)
+				assert.NoError(t, err)
+			}(i)
+		}
+		wg.Wait()
+
+		tokens, err := backend.ListTokens(user2)
+		assert.NoError(t, err)
+		assert.Len(t, tokens, Concurrency+1)
+		for i := 0; i < Concurrency; i++ {
+			assert.NoError(t, backend.DeleteToken(fmt.Sprintf("storetest-concurrent%d", i)))
+		}
+	})
+
+	t.Run("DeleteToken", func(t *testing.T) {
+		assert.NoError(t, backend.DeleteToken("storetest-key"))
+		assert.NoError(t, backend.DeleteToken("storetest-key2"))
+		token, err := backend.GetToken("storetest-key")
+		assert.Error(t, err)
+		assert.Nil(t, token)
+		tokens, err := backend.ListTokens(user)
+		assert.NoError(t, err)
+		assert.Empty(t, tokens)
+	})
+}
+
+// TestRevisionBackend runs the revision suite against backend.
+func TestRevisionBackend(t *testing.T, backend store.RevisionBackend) {
+	var (
+		user = "storetest-user"
+		name = "storetest-revisions"
+		cmd  = &core.Command{
+			User:        user,
+			Name:        name,
+			Source:      "#!cmd alpine\n#!/bin/sh\necho hello",
+			Environment: map[string]string{"FOO": "bar", "BAR": "baz"},
+		}
+	)
+
+	t.Run("ListRevisionsEmpty", func(t *testing.T) {
+		revs, err := backend.ListRevisions(user, name)
+		assert.NoError(t, err)
+		assert.Empty(t, revs)
+	})
+
+	t.Run("PutRevision", func(t *testing.T) {
+		rev := cmd.NewRevision(core.RevisionCreate, user)
+		assert.NoError(t, backend.PutRevision(rev))
+		assert.Equal(t, 1, rev.Revision)
+
+		cmd.Source = "#!cmd alpine\n#!/bin/sh\necho goodbye"
+		rev = cmd.NewRevision(core.RevisionEdit, "storetest-author")
+		assert.NoError(t, backend.PutRevision(rev))
+		assert.Equal(t, 2, rev.Revision)
+	})
+
+	t.Run("PutRevisionNil", func(t *testing.T) {
+		assert.Error(t, backend.PutRevision(nil))
+		assert.Error(t, backend.PutRevision(&core.Revision{}))
+	})
+
+	t.Run("GetRevision", func(t *testing.T) {
+		rev, err := backend.GetRevision(user, name, 1)
+		assert.NoError(t, err)
+		if assert.NotNil(t, rev) {
+			assert.Equal(t, 1, rev.Revision)
+			assert.Equal(t, core.RevisionCreate, rev.Action)
+			assert.Equal(t, "#!cmd alpine\n#!/bin/sh\necho hello", rev.Source)
+			assert.Equal(t, []string{"BAR", "FOO"}, rev.EnvKeys)
+			assert.Equal(t, user, rev.Author)
+			assert.False(t, rev.Created.IsZero())
+		}
+		rev, err = backend.GetRevision(user, name, 2)
+		assert.NoError(t, err)
+		if assert.NotNil(t, rev) {
+			assert.Equal(t, core.RevisionEdit, rev.Action)
+			assert.Equal(t, "storetest-author", rev.Author)
+		}
+	})
+
+	t.Run("GetRevisionMissing", func(t *testing.T) {
+		rev, err := backend.GetRevision(user, name, 3)
+		assert.Error(t, err)
+		assert.Nil(t, rev)
+		rev, err = backend.GetRevision(user, "missing", 1)
+		assert.Error(t, err)
+		assert.Nil(t, rev)
+	})
+
+	t.Run("ListRevisions", func(t *testing.T) {
+		revs, err := backend.ListRevisions(user, name)
+		assert.NoError(t, err)
+		if assert.Len(t, revs, 2) {
+			assert.Equal(t, 1, revs[0].Revision)
+			assert.Equal(t, 2, revs[1].Revision)
+		}
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < Concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, backend.PutRevision(
+					cmd.NewRevision(core.RevisionEnv, user)))
+			}()
+		}
+		wg.Wait()
+
+		revs, err := backend.ListRevisions(user, name)
+		assert.NoError(t, err)
+		if assert.Len(t, revs, Concurrency+2) {
+			for i, rev := range revs {
+				assert.Equal(t, i+1, rev.Revision,
+					"Concurrent revisions must be numbered without gaps")
+			}
+		}
+	})
+
+	t.Run("DeleteRevisions", func(t *testing.T) {
+		assert.NoError(t, backend.DeleteRevisions(user, name))
+		revs, err := backend.ListRevisions(user, name)
+		assert.NoError(t, err)
+		assert.Empty(t, revs)
+		assert.NoError(t, backend.DeleteRevisions(user, name),
+			"Deleting missing revisions should not fail")
+
+		rev := cmd.NewRevision(core.RevisionCreate, user)
+		assert.NoError(t, backend.PutRevision(rev))
+		assert.Equal(t, 1, rev.Revision, "A recreated command should start a new history")
+		assert.NoError(t, backend.DeleteRevisions(user, name))
+	})
+}
+
+// TestScheduleBackend runs the schedule suite against backend.
+func TestScheduleBackend(t *testing.T, backend store.ScheduleBackend) {
+	var (
+		user    = "storetest-user"
+		user2   = "storetest-user2"
+		created = time.Date(2017, 1, 31, 18, 0, 0, 0, time.UTC)
+	)
+
+	t.Run("PutSchedule", func(t *testing.T) {
+		assert.NoError(t, backend.PutSchedule(&core.Schedule{
+			ID:      "storetest-schedule",
+			User:    user,
+			Owner:   user2,
+			Cmd:     "cmd",
+			Spec:    "@hourly",
+			Args:    []string{"foo", "bar"},
+			Created: created,
+			NextRun: created.Add(time.Hour),
+		}))
+		assert.NoError(t, backend.PutSchedule(&core.Schedule{
+			ID:    "storetest-schedule2",
+			User:  user2,
+			Owner: user2,
+			Cmd:   "cmd",
+			Spec:  "0 0 * * *",
+		}))
+	})
+
+	t.Run("PutScheduleInvalid", func(t *testing.T) {
+		assert.Error(t, backend.PutSchedule(&core.Schedule{
+			ID:    "storetest-invalid",
+			User:  user,
+			Owner: user,
+			Cmd:   "cmd",
+			Spec:  "not a spec",
+		}))
+		_, err := backend.GetSchedule("storetest-invalid")
+		assert.Error(t, err)
+	})
+
+	t.Run("GetSchedule", func(t *testing.T) {
+		schedule, err := backend.GetSchedule("storetest-schedule")
+		assert.NoError(t, err)
+		if assert.NotNil(t, schedule) {
+			assert.Equal(t, user, schedule.User)
+			assert.Equal(t, user2, schedule.Owner)
+			assert.Equal(t, "cmd", schedule.Cmd)
+			assert.Equal(t, "@hourly", schedule.Spec)
+			assert.Equal(t, []string{"foo", "bar"}, schedule.Args)
+			assert.True(t, created.Equal(schedule.Created))
+			assert.True(t, created.Add(time.Hour).Equal(schedule.NextRun))
+		}
+	})
+
+	t.Run("GetScheduleMissing", func(t *testing.T) {
+		schedule, err := backend.GetSchedule("storetest-missing")
+		assert.Error(t, err)
+		assert.Nil(t, schedule)
+	})
+
+	t.Run("PutScheduleReplace", func(t *testing.T) {
+		schedule, err := backend.GetSchedule("storetest-schedule")
+		if assert.NoError(t, err) {
+			schedule.LastRun = created.Add(time.Hour)
+			schedule.LastStatus = 3
+			schedule.LastOutput = "output"
+			assert.NoError(t, backend.PutSchedule(schedule))
+		}
+		schedule, err = backend.GetSchedule("storetest-schedule")
+		assert.NoError(t, err)
+		if assert.NotNil(t, schedule) {
+			assert.True(t, created.Add(time.Hour).Equal(schedule.LastRun))
+			assert.Equal(t, 3, schedule.LastStatus)
+			assert.Equal(t, "output", schedule.LastOutput)
+		}
+	})
+
+	t.Run("ClaimSchedule", func(t *testing.T) {
+		schedule, err := backend.GetSchedule("storetest-schedule")
+		if !assert.NoError(t, err) {
+			return
+		}
+		prev := schedule.NextRun
+		schedule.NextRun = prev.Add(time.Hour)
+		claimed, err := backend.ClaimSchedule(schedule, prev)
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+		claimed, err = backend.ClaimSchedule(schedule, prev)
+		assert.NoError(t, err)
+		assert.False(t, claimed, "A run should only be claimed once")
+		stored, err := backend.GetSchedule("storetest-schedule")
+		if assert.NoError(t, err) {
+			assert.True(t, prev.Add(time.Hour).Equal(stored.NextRun))
+		}
+
+		missing := *schedule
+		missing.ID = "storetest-missing"
+		claimed, err = backend.ClaimSchedule(&missing, prev)
+		assert.NoError(t, err)
+		assert.False(t, claimed, "Deleted schedules should not be claimed")
+		_, err = backend.GetSchedule("storetest-missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("ListSchedules", func(t *testing.T) {
+		schedules, err := backend.ListSchedules(user)
+		assert.NoError(t, err)
+		if assert.Len(t, schedules, 1) {
+			assert.Equal(t, "storetest-schedule", schedules[0].ID)
+		}
+		schedules, err = backend.ListSchedules("")
+		assert.NoError(t, err)
+		var ids []string
+		for _, schedule := range schedules {
+			ids = append(ids, schedule.ID)
+		}
+		assertSet(t, []string{"storetest-schedule", "storetest-schedule2"}, ids)
+		schedules, err = backend.ListSchedules("storetest-missing")
+		assert.NoError(t, err)
+		assert.Empty(t, schedules)
+	})
+
+	t.Run("DeleteSchedule", func(t *testing.T) {
+		assert.NoError(t, backend.DeleteSchedule("storetest-schedule"))
+		assert.NoError(t, backend.DeleteSchedule("storetest-schedule2"))
+		_, err := backend.GetSchedule("storetest-schedule")
+		assert.Error(t, err)
+		schedules, err := backend.ListSchedules("")
+		assert.NoError(t, err)
+		assert.Empty(t, schedules)
+	})
+}
+
+// TestKeyBackend runs the SSH key suite against backend.
+func TestKeyBackend(t *testing.T, backend store.KeyBackend) {
+	var (
+		user  = "storetest-user"
+		user2 = "storetest-user2"
+	)
+	newKey := func(user, fingerprint string) *core.Key {
+		return &core.Key{
+			User:        user,
+			Fingerprint: fingerprint,
+			Key:         "ssh-ed25519 " + fingerprint,
+			Comment:     "comment",
+			Created:     time.Now().UTC().Truncate(time.Second),
+		}
+	}
+
+	t.Run("PutKey", func(t *testing.T) {
+		assert.NoError(t, backend.PutKey(newKey(user, "SHA256:b")))
+		assert.NoError(t, backend.PutKey(newKey(user, "SHA256:a")))
+		assert.NoError(t, backend.PutKey(newKey(user2, "SHA256:c")))
+		assert.Error(t, backend.PutKey(&core.Key{User: user}))
+	})
+
+	t.Run("ListKeys", func(t *testing.T) {
+		keys, err := backend.ListKeys(user)
+		assert.NoError(t, err)
+		if assert.Len(t, keys, 2) {
+			assert.Equal(t, "SHA256:a", keys[0].Fingerprint)
+			assert.Equal(t, "SHA256:b", keys[1].Fingerprint)
+			assert.Equal(t, "ssh-ed25519 SHA256:a", keys[0].Key)
+			assert.Equal(t, "comment", keys[0].Comment)
+		}
+		keys, err = backend.ListKeys("storetest-missing")
+		assert.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("PutKeyReplace", func(t *testing.T) {
+		key := newKey(user, "SHA256:a")
+		key.Comment = "replaced"
+		assert.NoError(t, backend.PutKey(key))
+		keys, err := backend.ListKeys(user)
+		assert.NoError(t, err)
+		if assert.Len(t, keys, 2) {
+			assert.Equal(t, "replaced", keys[0].Comment)
+		}
+	})
+
+	t.Run("DeleteKey", func(t *testing.T) {
+		assert.NoError(t, backend.DeleteKey(user, "SHA256:a"))
+		assert.NoError(t, backend.DeleteKey(user, "SHA256:missing"))
+		assert.NoError(t, backend.DeleteKey(user2, "SHA256:b"),
+			"Deleting a key of another user should do nothing")
+		keys, err := backend.ListKeys(user)
+		assert.NoError(t, err)
+		if assert.Len(t, keys, 1) {
+			assert.Equal(t, "SHA256:b", keys[0].Fingerprint)
+		}
+		keys, err = backend.ListKeys(user2)
+		assert.NoError(t, err)
+		assert.Len(t, keys, 1)
+	})
+}
+
+// TestRunBackend runs the run history suite against backend.
+func TestRunBackend(t *testing.T, backend store.RunBackend) {
+	var (
+		user    = "storetest-user"
+		name    = "storetest-runs"
+		started = time.Date(2017, 1, 31, 18, 0, 0, 0, time.UTC)
+	)
+	run := func(i int) *core.RunRecord {
+		return &core.RunRecord{
+			Cmd:      core.RevisionKey(user, name),
+			ID:       fmt.Sprintf("run%04d", i),
+			Caller:   "storetest-caller",
+			Via:      core.RunViaSSH,
+			Args:     []string{"foo"},
+			Started:  started.Add(time.Duration(i) * time.Minute),
+			Ended:    started.Add(time.Duration(i)*time.Minute + time.Second),
+			Status:   i,
+			BytesIn:  1,
+			BytesOut: 2,
+			Stdout:   "stdout",
+			Stderr:   "stderr",
+		}
+	}
+
+	t.Run("ListRunsEmpty", func(t *testing.T) {
+		runs, err := backend.ListRuns(user, name, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, runs)
+	})
+
+	t.Run("PutRun", func(t *testing.T) {
+		assert.NoError(t, backend.PutRun(run(1)))
+		assert.NoError(t, backend.PutRun(run(2)))
+		assert.Error(t, backend.PutRun(nil))
+		assert.Error(t, backend.PutRun(&core.RunRecord{}))
+	})
+
+	t.Run("GetRun", func(t *testing.T) {
+		got, err := backend.GetRun(user, name, "run0001")
+		assert.NoError(t, err)
+		if assert.NotNil(t, got) {
+			expected := run(1)
+			assert.Equal(t, expected.Caller, got.Caller)
+			assert.Equal(t, expected.Via, got.Via)
+			assert.Equal(t, expected.Args, got.Args)
+			assert.True(t, expected.Started.Equal(got.Started))
+			assert.Equal(t, time.Second, got.Duration())
+			assert.Equal(t, 1, got.Status)
+			assert.Equal(t, int64(1), got.BytesIn)
+			assert.Equal(t, int64(2), got.BytesOut)
+			assert.Equal(t, "stdout", got.Stdout)
+			assert.Equal(t, "stderr", got.Stderr)
+		}
+	})
+
+	t.Run("GetRunMissing", func(t *testing.T) {
+		got, err := backend.GetRun(user, name, "missing")
+		assert.Error(t, err)
+		assert.Nil(t, got)
+		got, err = backend.GetRun(user, "missing", "run0001")
+		assert.Error(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("ListRuns", func(t *testing.T) {
+		runs, err := backend.ListRuns(user, name, 0)
+		assert.NoError(t, err)
+		if assert.Len(t, runs, 2) {
+			assert.Equal(t, "run0002", runs[0].ID, "Runs should be listed newest first")
+			assert.Equal(t, "run0001", runs[1].ID)
+		}
+		runs, err = backend.ListRuns(user, name, 1)
+		assert.NoError(t, err)
+		if assert.Len(t, runs, 1) {
+			assert.Equal(t, "run0002", runs[0].ID)
+		}
+	})
+
+	t.Run("MaxRuns", func(t *testing.T) {
+		for i := 3; i <= store.MaxRuns+2; i++ {
+			assert.NoError(t, backend.PutRun(run(i)))
+		}
+		runs, err := backend.ListRuns(user, name, 0)
+		assert.NoError(t, err)
+		if assert.Len(t, runs, store.MaxRuns) {
+			assert.Equal(t, fmt.Sprintf("run%04d", store.MaxRuns+2), runs[0].ID)
+			assert.Equal(t, "run0003", runs[store.MaxRuns-1].ID,
+				"Oldest runs should be discarded")
+		}
+	})
+}
+
+// assertSet asserts expected and actual contain the same elements in any
+// order, as not all backends preserve ordering of sets.
+func assertSet(t *testing.T, expected, actual []string, msgAndArgs ...interface{}) bool {
+	sortedCopy := func(in []string) []string {
+		out := append([]string{}, in...)
+		sort.Strings(out)
+		return out
+	}
+	return assert.Equal(t, sortedCopy(expected), sortedCopy(actual), msgAndArgs...)
+}
+
+// TestSignatureBackend runs the request signature suite against backend.
+func TestSignatureBackend(t *testing.T, backend store.SignatureBackend) {
+	expires := time.Now().Add(time.Minute)
+
+	t.Run("UseSignature", func(t *testing.T) {
+		used, err := backend.UseSignature("storetest-signature", expires)
+		assert.NoError(t, err)
+		assert.True(t, used)
+		used, err = backend.UseSignature("storetest-signature", expires)
+		assert.NoError(t, err)
+		assert.False(t, used, "A signature should only be used once")
+		used, err = backend.UseSignature("storetest-signature2", expires)
+		assert.NoError(t, err)
+		assert.True(t, used)
+	})
+
+	t.Run("UseExpired", func(t *testing.T) {
+		used, err := backend.UseSignature("storetest-expired", time.Now().Add(-time.Minute))
+		assert.NoError(t, err)
+		assert.True(t, used)
+		used, err = backend.UseSignature("storetest-expired", expires)
+		assert.NoError(t, err)
+		assert.True(t, used, "Expired signatures should be forgotten")
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		var (
+			wg   sync.WaitGroup
+			mu   sync.Mutex
+			uses int
+		)
+		for i := 0; i < Concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				used, err := backend.UseSignature("storetest-concurrent", expires)
+				assert.NoError(t, err)
+				if used {
+					mu.Lock()
+					uses++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, uses, "A signature should only be used once")
+	})
+}
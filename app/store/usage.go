@@ -0,0 +1,90 @@
+package store
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gliderlabs/comlab/pkg/log"
+)
+
+// TokenUseInterval is the minimum time between recording uses of a token.
+// Uses in between are counted and recorded together.
+var TokenUseInterval = time.Minute
+
+var tokenUses = &tokenUseRecorder{
+	uses: make(map[string]*tokenUse),
+	record: func(key, ip string, at time.Time, uses int64) error {
+		return Selected().RecordTokenUse(key, ip, at, uses)
+	},
+}
+
+// RecordTokenUse records a use of the token with key from ip. Uses are
+// written to the selected backend at most once per TokenUseInterval.
+func RecordTokenUse(key string, ip net.IP) {
+	tokenUses.use(key, ip, time.Now().UTC())
+}
+
+type tokenUse struct {
+	uses    int64
+	ip      string
+	at      time.Time
+	written time.Time
+	pending bool
+}
+
+type tokenUseRecorder struct {
+	sync.Mutex
+	uses   map[string]*tokenUse
+	record func(key, ip string, at time.Time, uses int64) error
+}
+
+func (r *tokenUseRecorder) use(key string, ip net.IP, at time.Time) {
+	r.Lock()
+	u, ok := r.uses[key]
+	if !ok {
+		u = &tokenUse{}
+		r.uses[key] = u
+	}
+	u.uses++
+	u.at = at
+	if ip != nil {
+		u.ip = ip.String()
+	}
+	wait := TokenUseInterval - at.Sub(u.written)
+	if u.pending {
+		r.Unlock()
+		return
+	}
+	u.pending = true
+	r.Unlock()
+	if wait <= 0 {
+		r.flush(key)
+		return
+	}
+	time.AfterFunc(wait, func() { r.flush(key) })
+}
+
+func (r *tokenUseRecorder) flush(key string) {
+	r.Lock()
+	u := r.uses[key]
+	uses, ip, at := u.uses, u.ip, u.at
+	u.uses = 0
+	u.written = time.Now()
+	u.pending = false
+	r.Unlock()
+	if err := r.record(key, ip, at, uses); err != nil {
+		log.Info(key, err)
+	}
+	time.AfterFunc(TokenUseInterval, func() { r.expire(key) })
+}
+
+// expire forgets the token with key if it has not been used since it was
+// last written, so tokens no longer in use are not kept.
+func (r *tokenUseRecorder) expire(key string) {
+	r.Lock()
+	defer r.Unlock()
+	if u, ok := r.uses[key]; ok && !u.pending && u.uses == 0 {
+		delete(r.uses, key)
+	}
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenUseRecorder(t *testing.T) {
+	defer func(d time.Duration) { TokenUseInterval = d }(TokenUseInterval)
+	TokenUseInterval = 50 * time.Millisecond
+
+	var mu sync.Mutex
+	var recorded []int64
+	var lastIP string
+	r := &tokenUseRecorder{
+		uses: make(map[string]*tokenUse),
+		record: func(key, ip string, at time.Time, uses int64) error {
+			mu.Lock()
+			defer mu.Unlock()
+			recorded = append(recorded, uses)
+			lastIP = ip
+			return nil
+		},
+	}
+
+	r.use("key", net.ParseIP("192.0.2.1"), time.Now())
+	r.use("key", net.ParseIP("192.0.2.2"), time.Now())
+	r.use("key", net.ParseIP("192.0.2.3"), time.Now())
+	mu.Lock()
+	assert.Equal(t, []int64{1}, recorded, "First use should be recorded immediately")
+	mu.Unlock()
+
+	time.Sleep(2 * TokenUseInterval)
+	mu.Lock()
+	assert.Equal(t, []int64{1, 2}, recorded, "Later uses should be recorded together")
+	assert.Equal(t, "192.0.2.3", lastIP)
+	mu.Unlock()
+
+	time.Sleep(2 * TokenUseInterval)
+	r.Lock()
+	defer r.Unlock()
+	assert.Empty(t, r.uses, "Unused tokens should be forgotten once written")
+}
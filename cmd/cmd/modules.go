@@ -4,15 +4,22 @@ import (
 	_ "github.com/gliderlabs/cmd/app/builtin"
 	_ "github.com/gliderlabs/cmd/app/cmd"
 	_ "github.com/gliderlabs/cmd/app/console"
+	_ "github.com/gliderlabs/cmd/app/keys"
 	_ "github.com/gliderlabs/cmd/app/runapi"
+	_ "github.com/gliderlabs/cmd/app/scheduler"
 	_ "github.com/gliderlabs/cmd/app/store"
 	_ "github.com/gliderlabs/cmd/app/store/dynamodb"
+	_ "github.com/gliderlabs/cmd/app/store/filesystem"
+	_ "github.com/gliderlabs/cmd/app/store/memory"
 	_ "github.com/gliderlabs/cmd/lib/access"
 	_ "github.com/gliderlabs/cmd/lib/crypto"
 	_ "github.com/gliderlabs/cmd/lib/dockerbox"
 	_ "github.com/gliderlabs/cmd/lib/github"
 	_ "github.com/gliderlabs/cmd/lib/google/analytics"
 	_ "github.com/gliderlabs/cmd/lib/maint"
+	_ "github.com/gliderlabs/cmd/lib/runtime"
+	_ "github.com/gliderlabs/cmd/lib/runtime/docker"
+	_ "github.com/gliderlabs/cmd/lib/runtime/kubernetes"
 	_ "github.com/gliderlabs/cmd/lib/slack"
 	_ "github.com/gliderlabs/cmd/lib/ssh"
 	_ "github.com/gliderlabs/cmd/lib/stripe"
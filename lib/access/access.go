@@ -1,26 +1,97 @@
+// Package access decides which users may use cmd. Each policy is a component
+// that can be disabled, and a user is allowed if any enabled policy allows
+// them and none deny them.
 package access
 
 import (
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gliderlabs/comlab/pkg/com"
-	"github.com/google/go-github/github"
-	"github.com/gregjones/httpcache"
-	"golang.org/x/oauth2"
+	"github.com/gliderlabs/comlab/pkg/log"
+	"github.com/patrickmn/go-cache"
 )
 
+// Decision of a policy on the access of a user.
+type Decision int
+
+const (
+	// Abstain when a policy has nothing to say about a user.
+	Abstain Decision = iota
+	Allow
+	Deny
+)
+
+// Policy decides the access of users, giving the reason for decisions other
+// than Abstain.
+type Policy interface {
+	Decide(name string) (decision Decision, reason string, err error)
+}
+
+func Policies() []Policy {
+	var policies []Policy
+	for _, com := range com.Enabled(new(Policy), nil) {
+		policies = append(policies, com.(Policy))
+	}
+	return policies
+}
+
+var decisions = cache.New(cache.NoExpiration, 10*time.Minute)
+
+// Check returns whether name is allowed access by the enabled policies.
+// Decisions are cached for cache_ttl unless a policy failed.
 func Check(name string) bool {
-	auth := &oauth2.Transport{Source: oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: com.GetString("gh_token")},
-	)}
-	cache := httpcache.NewMemoryCacheTransport()
-	cache.Transport = auth
-	gh := github.NewClient(cache.Client())
-	gh.UserAgent = "cmd.io"
-	id, _ := strconv.Atoi(com.GetString("gh_team_id"))
-	checker := &Component{
-		teamID: id,
-		client: gh,
+	if allowed, ok := decisions.Get(name); ok {
+		return allowed.(bool)
+	}
+	allowed, reason, err := Decide(Policies(), name)
+	log.Info("access", name, allowed, reason)
+	if err != nil {
+		log.Info("access", name, err)
+		return allowed
+	}
+	ttl, err := time.ParseDuration(com.GetString("cache_ttl"))
+	if err != nil {
+		log.Info(err)
+		return allowed
+	}
+	decisions.Set(name, allowed, ttl)
+	return allowed
+}
+
+// Decide returns whether policies allow access to name and why. Any Deny
+// takes precedence over Allow, and without either access is denied. The
+// error is of the first policy to fail, which is treated as abstaining.
+func Decide(policies []Policy, name string) (allowed bool, reason string, err error) {
+	var allows []string
+	for _, policy := range policies {
+		decision, why, perr := policy.Decide(name)
+		if perr != nil {
+			if err == nil {
+				err = perr
+			}
+			continue
+		}
+		switch decision {
+		case Deny:
+			return false, why, err
+		case Allow:
+			allows = append(allows, why)
+		}
+	}
+	if len(allows) == 0 {
+		return false, "not allowed by any policy", err
+	}
+	return true, strings.Join(allows, ", "), err
+}
+
+// splitList splits a comma separated option, dropping empty values.
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
 	}
-	return checker.Check(name)
+	return values
 }
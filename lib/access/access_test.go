@@ -0,0 +1,155 @@
+package access
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/com/viper"
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePolicy struct {
+	decision Decision
+	reason   string
+	err      error
+}
+
+func (p fakePolicy) Decide(name string) (Decision, string, error) {
+	return p.decision, p.reason, p.err
+}
+
+func testConfig(t *testing.T, values map[string]interface{}) {
+	cfg := viper.NewConfig()
+	for k, v := range values {
+		cfg.Set(k, v)
+	}
+	com.SetConfig(cfg)
+}
+
+func TestDecide(t *testing.T) {
+	allow := fakePolicy{decision: Allow, reason: "allowed"}
+	deny := fakePolicy{decision: Deny, reason: "denied"}
+	abstain := fakePolicy{}
+	broken := fakePolicy{err: errors.New("broken")}
+
+	allowed, reason, err := Decide(nil, "user")
+	assert.False(t, allowed, "Users should be denied without policies")
+	assert.NotEmpty(t, reason)
+	assert.NoError(t, err)
+
+	allowed, reason, _ = Decide([]Policy{abstain, allow}, "user")
+	assert.True(t, allowed)
+	assert.Equal(t, "allowed", reason)
+
+	allowed, reason, _ = Decide([]Policy{allow, deny}, "user")
+	assert.False(t, allowed, "Deny should take precedence")
+	assert.Equal(t, "denied", reason)
+
+	allowed, _, err = Decide([]Policy{broken, allow}, "user")
+	assert.True(t, allowed, "Failed policies should abstain")
+	assert.Error(t, err)
+}
+
+func TestList(t *testing.T) {
+	f, err := ioutil.TempFile("", "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("# comment\nallow alice\n\nallow mallory\ndeny mallory\n")
+	f.Close()
+	testConfig(t, map[string]interface{}{"access.list_file": f.Name()})
+	defer testConfig(t, nil)
+
+	p := &List{}
+	decision, _, err := p.Decide("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, Allow, decision)
+	decision, _, _ = p.Decide("mallory")
+	assert.Equal(t, Deny, decision)
+	decision, _, _ = p.Decide("bob")
+	assert.Equal(t, Abstain, decision)
+
+	ioutil.WriteFile(f.Name(), []byte("permit alice\n"), 0644)
+	_, _, err = p.Decide("alice")
+	assert.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	decision, _, _ := (&Open{}).Decide("user")
+	assert.Equal(t, Abstain, decision, "Registration should be closed by default")
+
+	testConfig(t, map[string]interface{}{"access.open": true})
+	defer testConfig(t, nil)
+	decision, _, _ = (&Open{}).Decide("user")
+	assert.Equal(t, Allow, decision)
+}
+
+func TestGitHub(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/teams/2/members/alice", "/orgs/gliderlabs/members/bob":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	p := &GitHub{client: client}
+
+	testConfig(t, map[string]interface{}{
+		"access.gh_team_id": "1, 2",
+		"access.gh_orgs":    "gliderlabs",
+	})
+	defer testConfig(t, nil)
+
+	decision, reason, err := p.Decide("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, Allow, decision)
+	assert.Equal(t, "member of GitHub team 2", reason)
+	decision, reason, _ = p.Decide("bob")
+	assert.Equal(t, Allow, decision)
+	assert.Equal(t, "member of GitHub organization gliderlabs", reason)
+	decision, _, err = p.Decide("mallory")
+	assert.NoError(t, err)
+	assert.Equal(t, Abstain, decision)
+}
+
+func TestGitHubGroups(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/orgs/gliderlabs/teams":
+			w.Write([]byte(`[{"id": 3, "slug": "ops"}]`))
+		case "/teams/3/members/alice", "/orgs/gliderlabs/members/bob":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	p := &GitHub{client: client}
+
+	member, err := p.InGroup("alice", "gh:gliderlabs/ops")
+	assert.NoError(t, err)
+	assert.True(t, member)
+	member, _ = p.InGroup("bob", "gh:gliderlabs/ops")
+	assert.False(t, member)
+	member, _ = p.InGroup("bob", "gh:gliderlabs")
+	assert.True(t, member)
+	member, err = p.InGroup("alice", "team:ops")
+	assert.NoError(t, err)
+	assert.False(t, member, "Other namespaces should not be resolved")
+	_, err = p.InGroup("alice", "gh:gliderlabs/missing")
+	assert.Error(t, err)
+}
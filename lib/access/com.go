@@ -1,38 +1,23 @@
 package access
 
 import (
-	"context"
-
 	"github.com/gliderlabs/comlab/pkg/com"
-	"github.com/gliderlabs/comlab/pkg/log"
-	"github.com/google/go-github/github"
-	"github.com/gregjones/httpcache"
 )
 
 func init() {
 	com.Register("access", &Component{},
-		com.Option("gh_team_id", "2144066", "GitHub team ID to allow access to"),
+		com.Option("gh_team_id", "2144066", "GitHub team IDs to allow access to, comma separated"),
+		com.Option("gh_orgs", "", "GitHub organizations whose members are allowed access, comma separated"),
 		com.Option("gh_token", "", "GitHub access token"),
+		com.Option("list_file", "", "path to file of users to allow or deny access to"),
+		com.Option("open", false, "allow access to all users not denied"),
+		com.Option("cache_ttl", "5m", "duration to cache access decisions"),
 		com.Option("deny_msg", "Access Denied", "User message on access denied"),
 	)
+	com.Register("access.github", &GitHub{})
+	com.Register("access.list", &List{})
+	com.Register("access.open", &Open{})
 }
 
-type Component struct {
-	teamID int
-	client *github.Client
-}
-
-// TODO: this component is unnecessary, but is around from first pass
-// at consolidating into single package from two
-func (c *Component) Check(name string) bool {
-	isMember, res, err := c.client.Organizations.IsTeamMember(context.Background(), c.teamID, name)
-	if err != nil {
-		log.Info(err)
-		return isMember
-	}
-	log.Info("github api rate:", res.Rate.String())
-	if res.Header.Get(httpcache.XFromCache) != "" {
-		log.Info("member: " + name + " checked from cache")
-	}
-	return isMember
-}
+// Component checks access of sessions with the enabled policies.
+type Component struct{}
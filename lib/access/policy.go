@@ -0,0 +1,195 @@
+package access
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/log"
+	"github.com/google/go-github/github"
+	"github.com/gregjones/httpcache"
+	"github.com/patrickmn/go-cache"
+	"golang.org/x/oauth2"
+)
+
+// GitHub allows members of the GitHub teams in gh_team_id and organizations
+// in gh_orgs.
+type GitHub struct {
+	once   sync.Once
+	client *github.Client
+}
+
+func (p *GitHub) githubClient() *github.Client {
+	p.once.Do(func() {
+		if p.client != nil {
+			return
+		}
+		auth := &oauth2.Transport{Source: oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: com.GetString("gh_token")},
+		)}
+		transport := httpcache.NewMemoryCacheTransport()
+		transport.Transport = auth
+		p.client = github.NewClient(transport.Client())
+		p.client.UserAgent = "cmd.io"
+	})
+	return p.client
+}
+
+func (p *GitHub) Decide(name string) (Decision, string, error) {
+	ctx := context.Background()
+	for _, team := range splitList(com.GetString("gh_team_id")) {
+		id, err := strconv.Atoi(team)
+		if err != nil {
+			return Abstain, "", fmt.Errorf("invalid GitHub team ID: %s", team)
+		}
+		isMember, res, err := p.githubClient().Organizations.IsTeamMember(ctx, id, name)
+		if err != nil {
+			return Abstain, "", err
+		}
+		logRate(res, name)
+		if isMember {
+			return Allow, "member of GitHub team " + team, nil
+		}
+	}
+	for _, org := range splitList(com.GetString("gh_orgs")) {
+		isMember, res, err := p.githubClient().Organizations.IsMember(ctx, org, name)
+		if err != nil {
+			return Abstain, "", err
+		}
+		logRate(res, name)
+		if isMember {
+			return Allow, "member of GitHub organization " + org, nil
+		}
+	}
+	return Abstain, "", nil
+}
+
+// GitHubNamespace is the namespace of groups of GitHub organization members,
+// as gh:org, and team members, as gh:org/team.
+const GitHubNamespace = "gh"
+
+var memberships = cache.New(cache.NoExpiration, 10*time.Minute)
+
+// InGroup resolves GitHub groups of user, caching memberships for cache_ttl.
+func (p *GitHub) InGroup(user, group string) (bool, error) {
+	if !strings.HasPrefix(group, GitHubNamespace+":") {
+		return false, nil
+	}
+	key := user + " " + group
+	if member, ok := memberships.Get(key); ok {
+		return member.(bool), nil
+	}
+	member, err := p.isMember(user, strings.TrimPrefix(group, GitHubNamespace+":"))
+	if err != nil {
+		return false, err
+	}
+	ttl, err := time.ParseDuration(com.GetString("cache_ttl"))
+	if err != nil {
+		log.Info(err)
+		return member, nil
+	}
+	memberships.Set(key, member, ttl)
+	return member, nil
+}
+
+// isMember returns whether user is a member of a GitHub org or org/team.
+func (p *GitHub) isMember(user, group string) (bool, error) {
+	ctx := context.Background()
+	parts := strings.SplitN(group, "/", 2)
+	if len(parts) == 1 {
+		member, _, err := p.githubClient().Organizations.IsMember(ctx, parts[0], user)
+		return member, err
+	}
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		teams, res, err := p.githubClient().Organizations.ListTeams(ctx, parts[0], opt)
+		if err != nil {
+			return false, err
+		}
+		for _, team := range teams {
+			if team.GetSlug() == parts[1] {
+				member, _, err := p.githubClient().Organizations.IsTeamMember(ctx, team.GetID(), user)
+				return member, err
+			}
+		}
+		if res.NextPage == 0 {
+			return false, fmt.Errorf("GitHub team not found: %s", group)
+		}
+		opt.Page = res.NextPage
+	}
+}
+
+func logRate(res *github.Response, name string) {
+	if res.Header.Get(httpcache.XFromCache) != "" {
+		log.Info("member: " + name + " checked from cache")
+		return
+	}
+	log.Info("github api rate:", res.Rate.String())
+}
+
+// List allows or denies the users in list_file. Each line is "allow" or
+// "deny" followed by a user, and blank lines and lines starting with # are
+// ignored.
+type List struct{}
+
+func (p *List) Decide(name string) (Decision, string, error) {
+	path := com.GetString("list_file")
+	if path == "" {
+		return Abstain, "", nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return Abstain, "", err
+	}
+	defer f.Close()
+	decision := Abstain
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return Abstain, "", fmt.Errorf("%s:%d: expected allow or deny and a user", path, n)
+		}
+		switch fields[0] {
+		case "allow":
+			if fields[1] == name && decision == Abstain {
+				decision = Allow
+			}
+		case "deny":
+			if fields[1] == name {
+				decision = Deny
+			}
+		default:
+			return Abstain, "", fmt.Errorf("%s:%d: unknown action %q", path, n, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Abstain, "", err
+	}
+	switch decision {
+	case Allow:
+		return Allow, "allowed by " + path, nil
+	case Deny:
+		return Deny, "denied by " + path, nil
+	}
+	return Abstain, "", nil
+}
+
+// Open allows all users when open registration is enabled with the open
+// option.
+type Open struct{}
+
+func (p *Open) Decide(name string) (Decision, string, error) {
+	if !com.GetBool("open") {
+		return Abstain, "", nil
+	}
+	return Allow, "open registration", nil
+}
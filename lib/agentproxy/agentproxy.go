@@ -4,19 +4,16 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path"
 	"sync"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/gliderlabs/comlab/pkg/com"
 	"github.com/gliderlabs/ssh"
 	"github.com/inconshreveable/muxado"
 	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/gliderlabs/cmd/lib/runtime"
 )
 
 func init() {
@@ -30,32 +27,14 @@ type AgentProxy struct {
 	SocketPath  string
 	ContainerID string
 
-	docker client.APIClient
-	sess   ssh.Session
-	stream *types.HijackedResponse
-}
-
-// ImageExists and InspectImage taken from libcompose
-// Exists return whether or not the service image already exists
-func Exists(ctx context.Context, clt client.ImageAPIClient, image string) (bool, error) {
-	_, err := InspectImage(ctx, clt, image)
-	if err != nil {
-		if client.IsErrImageNotFound(err) {
-			return false, nil
-		}
-		return false, err
-	}
-	return true, nil
-}
-
-// InspectImage inspect the specified image (can be a name, an id or a digest)
-// with the specified client.
-func InspectImage(ctx context.Context, client client.ImageAPIClient, image string) (types.ImageInspect, error) {
-	imageInspect, _, err := client.ImageInspectWithRaw(ctx, image)
-	return imageInspect, err
+	runtime runtime.Runtime
+	sess    ssh.Session
+	stream  runtime.Stream
 }
 
-func NewAgentProxy(docker client.APIClient, sess ssh.Session) (*AgentProxy, error) {
+// NewAgentProxy creates the proxy container for sess with rt, which should be
+// the runtime running the session command so the proxy shares its host.
+func NewAgentProxy(rt runtime.Runtime, sess ssh.Session) (*AgentProxy, error) {
 	var (
 		TmpDir = com.GetString("tmpdir")
 		Image  = com.GetString("image")
@@ -64,35 +43,28 @@ func NewAgentProxy(docker client.APIClient, sess ssh.Session) (*AgentProxy, erro
 	socketPath := path.Join(TmpDir, fmt.Sprintf("auth-agent.%s", sessID), "listener.sock")
 	ctx := context.Background()
 
-	exists, err := Exists(ctx, docker, Image)
+	exists, err := rt.ImageExists(ctx, Image)
 	if err != nil {
 		return nil, err
 	}
 	if !exists {
-		res, err := docker.ImagePull(ctx, Image, types.ImagePullOptions{})
-		if err != nil {
+		if err := rt.PullImage(ctx, Image); err != nil {
 			return nil, err
 		}
-		io.Copy(ioutil.Discard, res)
-		res.Close()
 	}
 
-	res, err := docker.ContainerCreate(ctx, &container.Config{
-		Image:        Image,
-		Cmd:          []string{socketPath},
-		OpenStdin:    true,
-		AttachStderr: true,
-		AttachStdin:  true,
-		AttachStdout: true,
-		StdinOnce:    true,
-	}, nil, nil, "")
+	id, err := rt.Create(ctx, &runtime.Container{
+		Image: Image,
+		Cmd:   []string{socketPath},
+		Stdin: true,
+	})
 	if err != nil {
 		return nil, err
 	}
 	return &AgentProxy{
 		SocketPath:  socketPath,
-		ContainerID: res.ID,
-		docker:      docker,
+		ContainerID: id,
+		runtime:     rt,
 		sess:        sess,
 	}, nil
 }
@@ -100,40 +72,32 @@ func NewAgentProxy(docker client.APIClient, sess ssh.Session) (*AgentProxy, erro
 func (ap *AgentProxy) Shutdown() error {
 	if ap.ContainerID != "" {
 		ctx := context.Background()
-		err := ap.docker.ContainerRemove(ctx, ap.ContainerID, types.ContainerRemoveOptions{
-			Force: true,
-		})
-		if err != nil {
+		if err := ap.runtime.Remove(ctx, ap.ContainerID); err != nil {
 			return err
 		}
 	}
 	if ap.stream != nil {
-		ap.stream.Conn.Close()
+		ap.stream.Close()
 	}
 	return nil
 }
 
 func (ap *AgentProxy) Start() error {
 	ctx := context.Background()
-	stream, err := ap.docker.ContainerAttach(ctx, ap.ContainerID, types.ContainerAttachOptions{
-		Stdin:  true,
-		Stdout: true,
-		Stderr: true,
-		Stream: true,
-	})
+	stream, err := ap.runtime.Attach(ctx, ap.ContainerID, false)
 	if err != nil {
 		return err
 	}
-	ap.stream = &stream
+	ap.stream = stream
 	pr, pw := io.Pipe()
 	go func() {
-		stdcopy.StdCopy(pw, os.Stderr, stream.Reader)
+		stream.Output(pw, os.Stderr)
 		pw.Close()
 	}()
 	tunnel := muxado.Server(struct {
 		io.Reader
 		io.WriteCloser
-	}{pr, stream.Conn}, nil)
+	}{pr, stream}, nil)
 	go func() {
 		for {
 			stream, err := tunnel.AcceptStream()
@@ -143,7 +107,7 @@ func (ap *AgentProxy) Start() error {
 			go ap.proxyStream(stream)
 		}
 	}()
-	return ap.docker.ContainerStart(ctx, ap.ContainerID, types.ContainerStartOptions{})
+	return ap.runtime.Start(ctx, ap.ContainerID)
 }
 
 func (ap *AgentProxy) proxyStream(stream muxado.Stream) {
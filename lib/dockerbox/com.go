@@ -1,11 +1,13 @@
 package dockerbox
 
 import (
-	"fmt"
-	"net"
+	"context"
+	"sync"
+	"time"
 
 	"github.com/docker/docker/client"
 	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/log"
 )
 
 const (
@@ -15,25 +17,77 @@ const (
 func init() {
 	com.Register("dockerbox", &Component{},
 		com.Option("hostname", "", "hostname used to get backend A records"),
+		com.Option("port", "", "port of the Docker API on backends, default 2376 with TLS or 2375"),
+		com.Option("tls_ca", "", "path to CA bundle to verify backend certificates with"),
+		com.Option("tls_cert", "", "path to client certificate to authenticate with"),
+		com.Option("tls_key", "", "path to client certificate key"),
+		com.Option("tls_server_name", "", "name to verify backend certificates against, default hostname"),
+		com.Option("check_interval", "10s", "interval between backend health checks"),
 	)
 }
 
-type Component struct{}
+type Component struct {
+	once sync.Once
+	stop chan struct{}
+}
+
+// Serve health checks backends until stopped.
+func (c *Component) Serve() {
+	ticker := time.NewTicker(checkInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopped():
+			return
+		case <-ticker.C:
+			if err := defaultPool.refresh(context.Background()); err != nil {
+				log.Info(err)
+			}
+		}
+	}
+}
+
+// Stop checking backends.
+func (c *Component) Stop() {
+	close(c.stopped())
+}
+
+// stopped returns the channel closed by Stop, made on first use since Stop
+// may be called before Serve.
+func (c *Component) stopped() chan struct{} {
+	c.once.Do(func() {
+		c.stop = make(chan struct{})
+	})
+	return c.stop
+}
+
+func checkInterval() time.Duration {
+	interval, err := time.ParseDuration(com.GetString("check_interval"))
+	if err != nil {
+		log.Info(err)
+		return 10 * time.Second
+	}
+	return interval
+}
 
 type Client struct {
 	client.APIClient
 	Host string
 }
 
+// GetBackend returns the least loaded backend.
 func GetBackend() (*Client, error) {
-	if com.GetString("hostname") == "" {
-		c, err := client.NewEnvClient()
-		return &Client{c, "local"}, err
-	}
-	addrs, err := net.LookupHost(com.GetString("hostname"))
+	return SelectBackend(context.Background(), "")
+}
+
+// ImageExists returns true if image is present on the backend.
+func (c *Client) ImageExists(ctx context.Context, image string) (bool, error) {
+	_, _, err := c.ImageInspectWithRaw(ctx, image)
 	if err != nil {
-		return nil, err
+		if client.IsErrImageNotFound(err) {
+			return false, nil
+		}
+		return false, err
 	}
-	c, err := client.NewClient(fmt.Sprintf("tcp://%s:2375", addrs[0]), APIVersion, nil, nil)
-	return &Client{c, addrs[0]}, err
+	return true, nil
 }
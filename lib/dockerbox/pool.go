@@ -0,0 +1,256 @@
+package dockerbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/log"
+)
+
+// ErrNoBackends is returned when hostname resolves to no backends.
+var ErrNoBackends = errors.New("dockerbox: no backends")
+
+// localHost is the name of the backend configured by the environment when
+// no hostname is set.
+const localHost = "local"
+
+var defaultPool = &pool{
+	lookup:    lookupBackends,
+	newClient: newClient,
+}
+
+// Backends returns the clients of all configured backends, including
+// unhealthy ones, so containers already placed on a backend can still be
+// found once it is taken out of rotation.
+func Backends() ([]*Client, error) {
+	backends, err := defaultPool.all(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var clients []*Client
+	for _, b := range backends {
+		clients = append(clients, b.client)
+	}
+	return clients, nil
+}
+
+// SelectBackend returns the backend to run image on. Healthy backends which
+// already have image are preferred, then the least loaded. An empty image
+// selects the least loaded backend.
+func SelectBackend(ctx context.Context, image string) (*Client, error) {
+	return defaultPool.selectBackend(ctx, image)
+}
+
+func lookupBackends() ([]string, error) {
+	hostname := com.GetString("hostname")
+	if hostname == "" {
+		return []string{localHost}, nil
+	}
+	return net.LookupHost(hostname)
+}
+
+func newClient(host string) (*Client, error) {
+	if host == localHost {
+		c, err := client.NewEnvClient()
+		return &Client{c, host}, err
+	}
+	httpClient, err := tlsClient()
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.NewClient(
+		fmt.Sprintf("tcp://%s", net.JoinHostPort(host, port(httpClient != nil))),
+		APIVersion, httpClient, nil)
+	return &Client{c, host}, err
+}
+
+// backend is a Docker host in the pool and its last known state.
+type backend struct {
+	client   *Client
+	healthy  bool
+	err      error
+	running  int
+	memTotal int64
+}
+
+// load of a backend is its running containers per GiB of memory.
+func (b *backend) load() float64 {
+	gib := float64(b.memTotal) / (1 << 30)
+	if gib < 1 {
+		gib = 1
+	}
+	return float64(b.running) / gib
+}
+
+// check pings the backend and updates its load.
+func (b *backend) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := b.client.Ping(ctx); err != nil {
+		b.healthy, b.err = false, err
+		return
+	}
+	info, err := b.client.Info(ctx)
+	if err != nil {
+		b.healthy, b.err = false, err
+		return
+	}
+	b.healthy, b.err = true, nil
+	b.running = info.ContainersRunning
+	b.memTotal = info.MemTotal
+}
+
+// pool tracks the backends resolved from hostname.
+type pool struct {
+	lookup    func() ([]string, error)
+	newClient func(host string) (*Client, error)
+
+	mu       sync.Mutex
+	backends map[string]*backend
+	checked  time.Time
+}
+
+// refresh resolves the backends, adding new hosts and dropping removed ones,
+// and health checks each of them.
+func (p *pool) refresh(ctx context.Context) error {
+	hosts, err := p.lookup()
+	if err != nil {
+		return err
+	}
+	// check copies of the backends so selection isn't blocked meanwhile
+	p.mu.Lock()
+	backends := make(map[string]backend, len(hosts))
+	for _, host := range hosts {
+		if b, ok := p.backends[host]; ok {
+			backends[host] = *b
+			continue
+		}
+		c, err := p.newClient(host)
+		if err != nil {
+			log.Info(host, err)
+			continue
+		}
+		backends[host] = backend{client: c}
+	}
+	p.mu.Unlock()
+
+	checked := make(map[string]*backend, len(backends))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for host, b := range backends {
+		wg.Add(1)
+		go func(host string, b backend) {
+			defer wg.Done()
+			b.check(ctx)
+			if b.err != nil {
+				log.Info("backend unhealthy:", host, b.err)
+			}
+			mu.Lock()
+			checked[host] = &b
+			mu.Unlock()
+		}(host, b)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.backends = checked
+	p.checked = time.Now()
+	return nil
+}
+
+// all returns every backend sorted by host. Backends are refreshed first if
+// they haven't been checked recently.
+func (p *pool) all(ctx context.Context) ([]*backend, error) {
+	p.mu.Lock()
+	stale := time.Since(p.checked) > 3*checkInterval()
+	p.mu.Unlock()
+	if stale {
+		if err := p.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var all []*backend
+	for _, b := range p.backends {
+		all = append(all, b)
+	}
+	if len(all) == 0 {
+		return nil, ErrNoBackends
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].client.Host < all[j].client.Host
+	})
+	return all, nil
+}
+
+// candidates returns healthy backends, or all backends if none are healthy
+// so callers fail with the error of the backend.
+func (p *pool) candidates(ctx context.Context) ([]*backend, error) {
+	all, err := p.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var healthy []*backend
+	for _, b := range all {
+		if b.healthy {
+			healthy = append(healthy, b)
+		}
+	}
+	if len(healthy) > 0 {
+		return healthy, nil
+	}
+	return all, nil
+}
+
+func (p *pool) selectBackend(ctx context.Context, image string) (*Client, error) {
+	backends, err := p.candidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if image != "" && len(backends) > 1 {
+		var withImage []*backend
+		for _, b := range backends {
+			exists, err := b.client.ImageExists(ctx, image)
+			if err != nil {
+				p.markUnhealthy(b, err)
+				continue
+			}
+			if exists {
+				withImage = append(withImage, b)
+			}
+		}
+		if len(withImage) > 0 {
+			backends = withImage
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	best := backends[0]
+	for _, b := range backends[1:] {
+		if b.healthy && (!best.healthy || b.load() < best.load()) {
+			best = b
+		}
+	}
+	// count the placement until the next check so bursts are spread out
+	best.running++
+	return best.client, nil
+}
+
+// markUnhealthy takes b out of rotation until it is checked again.
+func (p *pool) markUnhealthy(b *backend, err error) {
+	log.Info("backend unhealthy:", b.client.Host, err)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b.healthy, b.err = false, err
+}
@@ -0,0 +1,124 @@
+package dockerbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	mock_client "github.com/gliderlabs/cmd/lib/mock/docker/docker/client"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeBackend struct {
+	running  int
+	memTotal int64
+	down     bool
+	image    bool
+}
+
+func newTestPool(ctrl *gomock.Controller, backends map[string]*fakeBackend) *pool {
+	return &pool{
+		lookup: func() ([]string, error) {
+			var hosts []string
+			for host := range backends {
+				hosts = append(hosts, host)
+			}
+			return hosts, nil
+		},
+		newClient: func(host string) (*Client, error) {
+			b := backends[host]
+			client := mock_client.NewMockAPIClient(ctrl)
+			if b.down {
+				client.EXPECT().Ping(gomock.Any()).
+					Return(types.Ping{}, errors.New("connection refused")).AnyTimes()
+			} else {
+				client.EXPECT().Ping(gomock.Any()).Return(types.Ping{}, nil).AnyTimes()
+			}
+			client.EXPECT().Info(gomock.Any()).Return(types.Info{
+				ContainersRunning: b.running,
+				MemTotal:          b.memTotal,
+			}, nil).AnyTimes()
+			inspect := client.EXPECT().ImageInspectWithRaw(gomock.Any(), "image").AnyTimes()
+			if b.image {
+				inspect.Return(types.ImageInspect{}, nil, nil)
+			} else {
+				inspect.Return(types.ImageInspect{}, nil, imageNotFound{})
+			}
+			return &Client{client, host}, nil
+		},
+	}
+}
+
+type imageNotFound struct{}
+
+func (imageNotFound) Error() string  { return "No such image" }
+func (imageNotFound) NotFound() bool { return true }
+
+const gib = 1 << 30
+
+func TestSelectBackend(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	p := newTestPool(ctrl, map[string]*fakeBackend{
+		"busy":  {running: 8, memTotal: 4 * gib, image: true},
+		"idle":  {running: 1, memTotal: 4 * gib},
+		"large": {running: 2, memTotal: 16 * gib},
+		"down":  {down: true, image: true},
+	})
+	assert.NoError(t, p.refresh(ctx))
+
+	c, err := p.selectBackend(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "large", c.Host, "Least loaded backend should be selected")
+
+	c, err = p.selectBackend(ctx, "image")
+	assert.NoError(t, err)
+	assert.Equal(t, "busy", c.Host, "Backends with the image should be preferred")
+
+	backends, err := p.candidates(ctx)
+	assert.NoError(t, err)
+	var hosts []string
+	for _, b := range backends {
+		hosts = append(hosts, b.client.Host)
+	}
+	assert.Equal(t, []string{"busy", "idle", "large"}, hosts, "Unhealthy backends should be out of rotation")
+
+	backends, err = p.all(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, backends, 4, "Unhealthy backends should still be listed for existing containers")
+}
+
+func TestSelectBackendSpread(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	p := newTestPool(ctrl, map[string]*fakeBackend{
+		"a": {memTotal: gib},
+		"b": {memTotal: gib},
+	})
+	assert.NoError(t, p.refresh(ctx))
+	first, _ := p.selectBackend(ctx, "")
+	second, _ := p.selectBackend(ctx, "")
+	assert.NotEqual(t, first.Host, second.Host, "Placements should count towards load")
+}
+
+func TestSelectBackendAllDown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	p := newTestPool(ctrl, map[string]*fakeBackend{"down": {down: true}})
+	assert.NoError(t, p.refresh(ctx))
+	c, err := p.selectBackend(ctx, "image")
+	assert.NoError(t, err, "Unhealthy backends should be used when none are healthy")
+	assert.Equal(t, "down", c.Host)
+
+	p = newTestPool(ctrl, nil)
+	_, err = p.selectBackend(ctx, "")
+	assert.Equal(t, ErrNoBackends, err)
+}
@@ -0,0 +1,51 @@
+package dockerbox
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/docker/go-connections/tlsconfig"
+	"github.com/gliderlabs/comlab/pkg/com"
+)
+
+// port returns the Docker API port of backends, which defaults to the
+// standard port for the protocol.
+func port(tls bool) string {
+	if p := com.GetString("port"); p != "" {
+		return p
+	}
+	if tls {
+		return "2376"
+	}
+	return "2375"
+}
+
+// tlsClient returns an HTTP client connecting to backends over TLS, or nil
+// if no TLS options are set. Backends are dialed by address, so their
+// certificates are verified against tls_server_name or hostname, and only
+// the tls_ca bundle is trusted when set.
+func tlsClient() (*http.Client, error) {
+	ca, cert, key := com.GetString("tls_ca"), com.GetString("tls_cert"), com.GetString("tls_key")
+	if ca == "" && cert == "" && key == "" {
+		return nil, nil
+	}
+	if (cert == "") != (key == "") {
+		return nil, errors.New("dockerbox: tls_cert and tls_key must be set together")
+	}
+	config, err := tlsconfig.Client(tlsconfig.Options{
+		CAFile:             ca,
+		CertFile:           cert,
+		KeyFile:            key,
+		ExclusiveRootPools: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	config.ServerName = com.GetString("tls_server_name")
+	if config.ServerName == "" {
+		config.ServerName = com.GetString("hostname")
+	}
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: config},
+	}, nil
+}
@@ -0,0 +1,155 @@
+package dockerbox
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/com/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert, key, der}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+"-key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestTLSClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockerbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	server := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "dockerbox.test"},
+		DNSNames:    []string{"dockerbox.test"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "cmd"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := client.write(t, dir, "cert")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{server.tlsCert()},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	ping := func(options map[string]interface{}) error {
+		cfg := viper.NewConfig()
+		cfg.Set("dockerbox.hostname", "dockerbox.test")
+		cfg.Set("dockerbox.port", port)
+		for k, v := range options {
+			cfg.Set(k, v)
+		}
+		com.SetConfig(cfg)
+		defer com.SetConfig(viper.NewConfig())
+		c, err := newClient(host)
+		if err != nil {
+			return err
+		}
+		_, err = c.Ping(context.Background())
+		return err
+	}
+
+	assert.NoError(t, ping(map[string]interface{}{
+		"dockerbox.tls_ca":   caFile,
+		"dockerbox.tls_cert": certFile,
+		"dockerbox.tls_key":  keyFile,
+	}))
+	assert.Error(t, ping(map[string]interface{}{
+		"dockerbox.tls_ca": caFile,
+	}), "Client certificate should be required")
+	assert.Error(t, ping(map[string]interface{}{
+		"dockerbox.tls_ca":          caFile,
+		"dockerbox.tls_cert":        certFile,
+		"dockerbox.tls_key":         keyFile,
+		"dockerbox.tls_server_name": "other.test",
+	}), "Server certificate should be verified against the server name")
+	assert.Error(t, ping(map[string]interface{}{
+		"dockerbox.tls_cert": certFile,
+		"dockerbox.tls_key":  keyFile,
+	}), "Backend certificates should be verified")
+	assert.Error(t, ping(map[string]interface{}{
+		"dockerbox.tls_ca":   caFile,
+		"dockerbox.tls_cert": certFile,
+	}), "Certificate and key should be set together")
+}
+
+func TestPort(t *testing.T) {
+	assert.Equal(t, "2375", port(false))
+	assert.Equal(t, "2376", port(true))
+}
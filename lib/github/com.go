@@ -5,6 +5,7 @@ import "github.com/gliderlabs/comlab/pkg/com"
 func init() {
 	com.Register("github", &Component{},
 		com.Option("endpoint", "/_github", "webhook endpoint"),
+		com.Option("secret", "", "webhook secret deliveries are signed with"),
 	)
 }
 
@@ -0,0 +1,49 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// Headers GitHub signs deliveries with, newest first
+const (
+	SignatureHeader     = "X-Hub-Signature-256"
+	SignatureHeaderSHA1 = "X-Hub-Signature"
+)
+
+// ErrInvalidSignature is returned for deliveries without a valid signature.
+var ErrInvalidSignature = errors.New("github: invalid signature")
+
+// VerifySignature checks the signature headers of a delivery with body
+// against the webhook secret, preferring SHA-256 over SHA-1 signatures.
+func VerifySignature(header http.Header, body []byte, secret string) error {
+	if sig := header.Get(SignatureHeader); sig != "" {
+		return verifyHMAC(sha256.New, "sha256=", sig, body, secret)
+	}
+	if sig := header.Get(SignatureHeaderSHA1); sig != "" {
+		return verifyHMAC(sha1.New, "sha1=", sig, body, secret)
+	}
+	return ErrInvalidSignature
+}
+
+func verifyHMAC(h func() hash.Hash, prefix, sig string, body []byte, secret string) error {
+	if secret == "" || !strings.HasPrefix(sig, prefix) {
+		return ErrInvalidSignature
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, prefix))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
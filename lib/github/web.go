@@ -1,7 +1,9 @@
 package github
 
 import (
+	"bytes"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/gliderlabs/comlab/pkg/com"
@@ -14,9 +16,21 @@ func (c *Component) MatchHTTP(r *http.Request) bool {
 }
 
 func (c *Component) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	dec := json.NewDecoder(r.Body)
-	defer r.Body.Close()
-	var err error
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := VerifySignature(r.Header, body, com.GetString("secret")); err != nil {
+		log.Info(r, "github delivery rejected", err, log.Fields{
+			"delivery": r.Header.Get("X-GitHub-Delivery"),
+			"event":    r.Header.Get("X-GitHub-Event"),
+		})
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
 	switch r.Header.Get("x-github-event") {
 	case "issues":
 		var event IssuesEvent
@@ -0,0 +1,54 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/com/viper"
+	"github.com/gliderlabs/comlab/pkg/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestServeHTTP(t *testing.T) {
+	cfg := viper.NewConfig()
+	cfg.Set("github.secret", "secret")
+	com.SetConfig(cfg)
+	defer com.SetConfig(viper.NewConfig())
+	var received int
+	listener := &events.Listener{
+		EventName: EventPing,
+		Handler:   func(events.Event) { received++ },
+	}
+	events.Listen(listener)
+	defer events.Unlisten(listener)
+
+	deliver := func(signature string) int {
+		body := `{"zen": "Keep it logically awesome."}`
+		req := httptest.NewRequest("POST", "/_github", strings.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "ping")
+		if signature != "" {
+			req.Header.Set(SignatureHeader, signature)
+		}
+		w := httptest.NewRecorder()
+		(&Component{}).ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, deliver(""), "Unsigned deliveries should be rejected")
+	assert.Equal(t, http.StatusUnauthorized, deliver(sign("forged", "secret")))
+	assert.Equal(t, 0, received, "Rejected deliveries should not emit events")
+	assert.Equal(t, http.StatusOK, deliver(sign(`{"zen": "Keep it logically awesome."}`, "secret")))
+	assert.Equal(t, 1, received)
+}
@@ -0,0 +1,369 @@
+// Package docker runs commands with the Docker API of dockerbox backends.
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/log"
+
+	"github.com/gliderlabs/cmd/lib/dockerbox"
+	"github.com/gliderlabs/cmd/lib/runtime"
+)
+
+const dockerSocket = "/var/run/docker.sock"
+
+func init() {
+	com.Register("runtime.docker", &Component{})
+}
+
+// Component is a runtime backend placing containers on dockerbox backends.
+type Component struct{}
+
+// Runtime selects the backend to run image on. Without an image the
+// runtime manages containers on all backends.
+func (c *Component) Runtime(ctx context.Context, image string) (runtime.Runtime, error) {
+	if image == "" {
+		return &Runtime{backends: dockerbox.Backends}, nil
+	}
+	client, err := dockerbox.SelectBackend(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	return New(client), nil
+}
+
+// Runtime runs containers with a single Docker client, or manages existing
+// containers across backends when it has no client.
+type Runtime struct {
+	client   *dockerbox.Client
+	backends func() ([]*dockerbox.Client, error)
+}
+
+// New returns a runtime using client.
+func New(client *dockerbox.Client) *Runtime {
+	return &Runtime{client: client}
+}
+
+func (r *Runtime) Host() string {
+	if r.client == nil {
+		return ""
+	}
+	return r.client.Host
+}
+
+// SharesVolumes returns true since containers on a host can mount each
+// others volumes.
+func (r *Runtime) SharesVolumes() bool {
+	return true
+}
+
+// clients returns the client of the runtime or of all backends.
+func (r *Runtime) clients() ([]*dockerbox.Client, error) {
+	if r.client != nil {
+		return []*dockerbox.Client{r.client}, nil
+	}
+	return r.backends()
+}
+
+// locate returns container id and the client of the backend it is on.
+func (r *Runtime) locate(ctx context.Context, id string) (*dockerbox.Client, types.ContainerJSON, error) {
+	clients, err := r.clients()
+	if err != nil {
+		return nil, types.ContainerJSON{}, err
+	}
+	for _, client := range clients {
+		c, err := client.ContainerInspect(ctx, id)
+		if err == nil {
+			return client, c, nil
+		}
+	}
+	return nil, types.ContainerJSON{}, runtime.ErrNotFound
+}
+
+func (r *Runtime) ImageExists(ctx context.Context, image string) (bool, error) {
+	return r.client.ImageExists(ctx, image)
+}
+
+func (r *Runtime) ImageSize(ctx context.Context, image string) (int64, error) {
+	img, _, err := r.client.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return 0, err
+	}
+	return img.Size, nil
+}
+
+func (r *Runtime) BuildImage(ctx context.Context, buildCtx io.Reader, tag string, out io.Writer) error {
+	resp, err := r.client.ImageBuild(ctx, buildCtx, types.ImageBuildOptions{
+		Dockerfile: "Dockerfile",
+		Tags:       []string{tag},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// read all output to ensure we don't return before the image is built
+	// and tagged
+	return readBuildOutput(resp.Body, out)
+}
+
+func (r *Runtime) PullImage(ctx context.Context, image string) error {
+	res, err := r.client.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, res)
+	return res.Close()
+}
+
+func (r *Runtime) TagImage(ctx context.Context, image, tag string) error {
+	return r.client.ImageTag(ctx, image, tag)
+}
+
+func (r *Runtime) RemoveImage(ctx context.Context, image string) error {
+	_, err := r.client.ImageRemove(ctx, image, types.ImageRemoveOptions{})
+	return err
+}
+
+func (r *Runtime) Create(ctx context.Context, c *runtime.Container) (string, error) {
+	conf := &container.Config{
+		Image:        c.Image,
+		Env:          c.Env,
+		Cmd:          c.Cmd,
+		Labels:       c.Labels,
+		Tty:          c.Tty,
+		OpenStdin:    c.Stdin,
+		StdinOnce:    c.Stdin,
+		AttachStdin:  c.Stdin,
+		AttachStdout: c.Stdin,
+		AttachStderr: c.Stdin,
+		Volumes:      make(map[string]struct{}),
+	}
+	hostConf := &container.HostConfig{
+		AutoRemove:  c.AutoRemove,
+		VolumesFrom: c.VolumesFrom,
+		Resources: container.Resources{
+			CPUPeriod: c.CPUPeriod,
+			CPUQuota:  c.CPUQuota,
+			Memory:    c.Memory,
+		},
+	}
+	if c.DockerSocket {
+		// TODO: actual feature, maybe: https://github.com/gliderlabs/cmd/issues/40
+		conf.Volumes[dockerSocket] = struct{}{}
+		hostConf.Binds = []string{dockerSocket + ":" + dockerSocket}
+	}
+	res, err := r.client.ContainerCreate(ctx, conf, hostConf, nil, "")
+	if err != nil {
+		return "", err
+	}
+	return res.ID, nil
+}
+
+func (r *Runtime) Attach(ctx context.Context, id string, tty bool) (runtime.Stream, error) {
+	res, err := r.client.ContainerAttach(ctx, id, types.ContainerAttachOptions{
+		Stdin:  true,
+		Stdout: true,
+		Stderr: true,
+		Stream: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stream{res: res, tty: tty}, nil
+}
+
+func (r *Runtime) Start(ctx context.Context, id string) error {
+	return r.client.ContainerStart(ctx, id, types.ContainerStartOptions{})
+}
+
+func (r *Runtime) Wait(ctx context.Context, id string) (int, error) {
+	status, err := r.client.ContainerWait(ctx, id)
+	return int(status), err
+}
+
+func (r *Runtime) Resize(ctx context.Context, id string, width, height int) error {
+	return r.client.ContainerResize(ctx, id, types.ResizeOptions{
+		Height: uint(height),
+		Width:  uint(width),
+	})
+}
+
+func (r *Runtime) Remove(ctx context.Context, id string) error {
+	client := r.client
+	if client == nil {
+		var err error
+		if client, _, err = r.locate(ctx, id); err != nil {
+			return err
+		}
+	}
+	return client.ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true})
+}
+
+// List returns matching containers on all backends of the runtime. Backends
+// that can't be reached are skipped.
+func (r *Runtime) List(ctx context.Context, labels map[string]string) ([]*runtime.ContainerInfo, error) {
+	clients, err := r.clients()
+	if err != nil {
+		return nil, err
+	}
+	args := filters.NewArgs()
+	for k, v := range labels {
+		args.Add("label", k+"="+v)
+	}
+	var infos []*runtime.ContainerInfo
+	for _, client := range clients {
+		containers, err := client.ContainerList(ctx, types.ContainerListOptions{
+			All:     true,
+			Filters: args,
+		})
+		if err != nil {
+			if len(clients) == 1 {
+				return nil, err
+			}
+			log.Info(client.Host, err)
+			continue
+		}
+		for _, c := range containers {
+			infos = append(infos, &runtime.ContainerInfo{
+				ID:       c.ID,
+				Labels:   c.Labels,
+				State:    c.State,
+				ExitCode: exitCode(c.Status),
+				Created:  time.Unix(c.Created, 0).UTC(),
+			})
+		}
+	}
+	return infos, nil
+}
+
+func (r *Runtime) Inspect(ctx context.Context, id string) (*runtime.ContainerInfo, error) {
+	_, c, err := r.locate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	created, _ := time.Parse(time.RFC3339Nano, c.Created)
+	return &runtime.ContainerInfo{
+		ID:       c.ID,
+		Labels:   c.Config.Labels,
+		State:    c.State.Status,
+		ExitCode: c.State.ExitCode,
+		Created:  created.UTC(),
+	}, nil
+}
+
+func (r *Runtime) Logs(ctx context.Context, id string, follow bool, stdout, stderr io.Writer) error {
+	client, c, err := r.locate(ctx, id)
+	if err != nil {
+		return err
+	}
+	logs, err := client.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+	})
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	if c.Config.Tty {
+		_, err = io.Copy(stdout, logs)
+		return err
+	}
+	_, err = stdcopy.StdCopy(stdout, stderr, logs)
+	return err
+}
+
+func (r *Runtime) Kill(ctx context.Context, id string) error {
+	client, _, err := r.locate(ctx, id)
+	if err != nil {
+		return err
+	}
+	return client.ContainerKill(ctx, id, "KILL")
+}
+
+// exitCode parses the exit code from a container list status, such as
+// "Exited (1) 2 minutes ago".
+func exitCode(status string) int {
+	var code int
+	if strings.HasPrefix(status, "Exited") {
+		fmt.Sscanf(status, "Exited (%d)", &code)
+	}
+	return code
+}
+
+// stream is a hijacked attach connection. Without a tty stdout and stderr
+// are multiplexed on the connection.
+type stream struct {
+	res types.HijackedResponse
+	tty bool
+}
+
+func (s *stream) Write(p []byte) (int, error) {
+	return s.res.Conn.Write(p)
+}
+
+func (s *stream) CloseWrite() error {
+	return s.res.CloseWrite()
+}
+
+func (s *stream) Close() error {
+	return s.res.Conn.Close()
+}
+
+func (s *stream) Output(stdout, stderr io.Writer) error {
+	var err error
+	if s.tty {
+		_, err = io.Copy(stdout, s.res.Reader)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, s.res.Reader)
+	}
+	return err
+}
+
+// buildMessage is the subset of a Docker JSON message used to follow builds.
+type buildMessage struct {
+	Stream      string `json:"stream"`
+	Status      string `json:"status"`
+	ID          string `json:"id"`
+	Error       string `json:"error"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// readBuildOutput decodes the JSON message stream of an image build writing
+// build output to out and returning any error reported by the daemon.
+func readBuildOutput(r io.Reader, out io.Writer) error {
+	dec := json.NewDecoder(r)
+	for {
+		var msg buildMessage
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		switch {
+		case msg.ErrorDetail != nil:
+			return &runtime.BuildError{Message: strings.TrimSpace(msg.ErrorDetail.Message)}
+		case msg.Error != "":
+			return &runtime.BuildError{Message: strings.TrimSpace(msg.Error)}
+		case msg.Stream != "":
+			io.WriteString(out, msg.Stream)
+		case msg.Status != "" && msg.ID == "":
+			// skip per layer progress of base image pulls
+			fmt.Fprintln(out, msg.Status)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gliderlabs/cmd/lib/dockerbox"
+	mock_client "github.com/gliderlabs/cmd/lib/mock/docker/docker/client"
+	"github.com/gliderlabs/cmd/lib/runtime"
+)
+
+func TestList(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	now := time.Now().UTC().Truncate(time.Second)
+	up := mock_client.NewMockAPIClient(ctrl)
+	up.EXPECT().
+		ContainerList(gomock.Any(), gomock.Any()).
+		Return([]types.Container{{
+			ID:      "0123456789abcdef",
+			Labels:  map[string]string{"job": "true"},
+			State:   "exited",
+			Status:  "Exited (3) 2 minutes ago",
+			Created: now.Unix(),
+		}}, nil)
+	down := mock_client.NewMockAPIClient(ctrl)
+	down.EXPECT().
+		ContainerList(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("connection refused"))
+
+	r := &Runtime{backends: func() ([]*dockerbox.Client, error) {
+		return []*dockerbox.Client{{APIClient: down, Host: "down"}, {APIClient: up, Host: "up"}}, nil
+	}}
+	containers, err := r.List(context.Background(), map[string]string{"job": "true"})
+	assert.NoError(t, err, "Unreachable backends should be skipped")
+	if assert.Len(t, containers, 1) {
+		assert.Equal(t, &runtime.ContainerInfo{
+			ID:       "0123456789abcdef",
+			Labels:   map[string]string{"job": "true"},
+			State:    "exited",
+			ExitCode: 3,
+			Created:  now,
+		}, containers[0])
+	}
+}
+
+func TestLocate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	other := mock_client.NewMockAPIClient(ctrl)
+	other.EXPECT().
+		ContainerInspect(gomock.Any(), gomock.Any()).
+		Return(types.ContainerJSON{}, errors.New("No such container")).
+		AnyTimes()
+	host := mock_client.NewMockAPIClient(ctrl)
+	host.EXPECT().
+		ContainerInspect(gomock.Any(), "0123456789ab").
+		Return(types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:    "0123456789abcdef",
+				State: &types.ContainerState{Status: "running"},
+			},
+			Config: &container.Config{Labels: map[string]string{"job": "true"}},
+		}, nil).
+		Times(2)
+	host.EXPECT().
+		ContainerInspect(gomock.Any(), "missing").
+		Return(types.ContainerJSON{}, errors.New("No such container"))
+	host.EXPECT().ContainerKill(gomock.Any(), "0123456789ab", "KILL").Return(nil)
+
+	r := &Runtime{backends: func() ([]*dockerbox.Client, error) {
+		return []*dockerbox.Client{{APIClient: other, Host: "other"}, {APIClient: host, Host: "host"}}, nil
+	}}
+	info, err := r.Inspect(ctx, "0123456789ab")
+	assert.NoError(t, err)
+	if assert.NotNil(t, info) {
+		assert.Equal(t, "running", info.State)
+	}
+	assert.NoError(t, r.Kill(ctx, "0123456789ab"), "Containers should be killed on the backend they run on")
+
+	_, err = r.Inspect(ctx, "missing")
+	assert.Equal(t, runtime.ErrNotFound, err)
+}
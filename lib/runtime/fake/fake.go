@@ -0,0 +1,374 @@
+// Package fake provides an in-process runtime for tests. Containers run a Go
+// function instead of an image.
+package fake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gliderlabs/cmd/lib/runtime"
+)
+
+// Process is a running fake container.
+type Process struct {
+	*runtime.Container
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Killed is closed when the container is killed.
+	Killed <-chan struct{}
+}
+
+// Runtime runs containers in goroutines. The zero value is ready to use and
+// runs containers that exit immediately with status 0.
+type Runtime struct {
+	// Run is called for each started container and returns its exit status.
+	Run func(p *Process) int
+	// Registry holds the size of images that can be pulled.
+	Registry map[string]int64
+
+	mu         sync.Mutex
+	images     map[string]int64
+	containers map[string]*container
+	next       int
+}
+
+type container struct {
+	id      string
+	spec    *runtime.Container
+	stream  *runtime.Pipe
+	created time.Time
+	started bool
+	killed  chan struct{}
+	exited  chan struct{}
+	status  int
+	// logs keep output of containers started without a stream
+	logs bytes.Buffer
+}
+
+func (c *container) info() *runtime.ContainerInfo {
+	info := &runtime.ContainerInfo{
+		ID:      c.id,
+		Labels:  c.spec.Labels,
+		State:   "created",
+		Created: c.created,
+	}
+	select {
+	case <-c.exited:
+		info.State = "exited"
+		info.ExitCode = c.status
+	default:
+		if c.started {
+			info.State = "running"
+		}
+	}
+	return info
+}
+
+// Runtime returns r for any image so it can be used as a runtime backend.
+func (r *Runtime) Runtime(ctx context.Context, image string) (runtime.Runtime, error) {
+	return r, nil
+}
+
+func (r *Runtime) Host() string {
+	return "fake"
+}
+
+// SharesVolumes returns true so agent forwarding can be tested.
+func (r *Runtime) SharesVolumes() bool {
+	return true
+}
+
+// AddImage makes image of size present as if pulled or built.
+func (r *Runtime) AddImage(image string, size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.images == nil {
+		r.images = make(map[string]int64)
+	}
+	r.images[image] = size
+}
+
+// Container returns the spec container id was created with, or nil if it
+// does not exist or was removed.
+func (r *Runtime) Container(id string) *runtime.Container {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.containers[id]; ok {
+		return c.spec
+	}
+	return nil
+}
+
+func (r *Runtime) ImageExists(ctx context.Context, image string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.images[image]
+	return ok, nil
+}
+
+func (r *Runtime) ImageSize(ctx context.Context, image string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	size, ok := r.images[image]
+	if !ok {
+		return 0, errors.Errorf("no such image: %s", image)
+	}
+	return size, nil
+}
+
+// BuildImage tags an image the size of the build context.
+func (r *Runtime) BuildImage(ctx context.Context, buildCtx io.Reader, tag string, out io.Writer) error {
+	n, err := io.Copy(ioutil.Discard, buildCtx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Successfully tagged", tag)
+	r.AddImage(tag, n)
+	return nil
+}
+
+func (r *Runtime) PullImage(ctx context.Context, image string) error {
+	r.mu.Lock()
+	size, ok := r.Registry[image]
+	r.mu.Unlock()
+	if !ok {
+		return errors.Errorf("image not found in registry: %s", image)
+	}
+	r.AddImage(image, size)
+	return nil
+}
+
+func (r *Runtime) TagImage(ctx context.Context, image, tag string) error {
+	size, err := r.ImageSize(ctx, image)
+	if err != nil {
+		return err
+	}
+	r.AddImage(tag, size)
+	return nil
+}
+
+func (r *Runtime) RemoveImage(ctx context.Context, image string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.images[image]; !ok {
+		return errors.Errorf("no such image: %s", image)
+	}
+	delete(r.images, image)
+	return nil
+}
+
+func (r *Runtime) Create(ctx context.Context, spec *runtime.Container) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.images[spec.Image]; !ok {
+		return "", errors.Errorf("no such image: %s", spec.Image)
+	}
+	if r.containers == nil {
+		r.containers = make(map[string]*container)
+	}
+	r.next++
+	// unique in the short ID like Docker container IDs
+	id := fmt.Sprintf("%012x", r.next) + strings.Repeat("0", 52)
+	r.containers[id] = &container{
+		id:      id,
+		spec:    spec,
+		created: time.Now().UTC(),
+		killed:  make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	return id, nil
+}
+
+func (r *Runtime) lookup(id string) (*container, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for cid, c := range r.containers {
+		if strings.HasPrefix(cid, id) {
+			return c, nil
+		}
+	}
+	return nil, errors.Errorf("no such container: %s", id)
+}
+
+func (r *Runtime) Attach(ctx context.Context, id string, tty bool) (runtime.Stream, error) {
+	c, err := r.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c.stream != nil {
+		return nil, errors.Errorf("container already attached: %s", id)
+	}
+	c.stream = runtime.NewPipe()
+	return c.stream, nil
+}
+
+func (r *Runtime) Start(ctx context.Context, id string) error {
+	c, err := r.lookup(id)
+	if err != nil {
+		return err
+	}
+	p := &Process{
+		Container: c.spec,
+		Stdin:     strings.NewReader(""),
+		Stdout:    &syncWriter{mu: &r.mu, w: &c.logs},
+		Stderr:    &syncWriter{mu: &r.mu, w: &c.logs},
+		Killed:    c.killed,
+	}
+	r.mu.Lock()
+	s := c.stream
+	run := r.Run
+	c.started = true
+	r.mu.Unlock()
+	if s != nil {
+		p.Stdout, p.Stderr = s.Stdout, s.Stderr
+		if c.spec.Tty {
+			p.Stderr = s.Stdout
+		}
+		if c.spec.Stdin {
+			p.Stdin = s.Stdin
+		}
+	}
+	go func() {
+		var status int
+		if run != nil {
+			status = run(p)
+		}
+		if s != nil {
+			s.Exit(nil)
+		}
+		c.status = status
+		close(c.exited)
+		if c.spec.AutoRemove {
+			r.mu.Lock()
+			delete(r.containers, c.id)
+			r.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (r *Runtime) Wait(ctx context.Context, id string) (int, error) {
+	c, err := r.lookup(id)
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case <-c.exited:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return c.status, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (r *Runtime) Resize(ctx context.Context, id string, width, height int) error {
+	_, err := r.lookup(id)
+	return err
+}
+
+func (r *Runtime) Remove(ctx context.Context, id string) error {
+	c, err := r.lookup(id)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for cid, other := range r.containers {
+		if other == c {
+			delete(r.containers, cid)
+		}
+	}
+	if c.stream != nil {
+		c.stream.Close()
+	}
+	return nil
+}
+
+func (r *Runtime) List(ctx context.Context, labels map[string]string) ([]*runtime.ContainerInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var infos []*runtime.ContainerInfo
+	for _, c := range r.containers {
+		match := true
+		for k, v := range labels {
+			if c.spec.Labels[k] != v {
+				match = false
+			}
+		}
+		if match {
+			infos = append(infos, c.info())
+		}
+	}
+	return infos, nil
+}
+
+func (r *Runtime) Inspect(ctx context.Context, id string) (*runtime.ContainerInfo, error) {
+	c, err := r.lookup(id)
+	if err != nil {
+		return nil, runtime.ErrNotFound
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return c.info(), nil
+}
+
+// Logs writes output of containers started without a stream to stdout.
+func (r *Runtime) Logs(ctx context.Context, id string, follow bool, stdout, stderr io.Writer) error {
+	c, err := r.lookup(id)
+	if err != nil {
+		return err
+	}
+	if follow {
+		select {
+		case <-c.exited:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	r.mu.Lock()
+	logs := c.logs.String()
+	r.mu.Unlock()
+	_, err = io.WriteString(stdout, logs)
+	return err
+}
+
+// Kill closes the Killed channel of the process. The container exits once
+// Run returns.
+func (r *Runtime) Kill(ctx context.Context, id string) error {
+	c, err := r.lookup(id)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-c.killed:
+	default:
+		close(c.killed)
+	}
+	return nil
+}
+
+// syncWriter guards writes to w with mu.
+type syncWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
@@ -0,0 +1,746 @@
+// Package kubernetes runs each command invocation as a pod, attaching to it
+// through the API server.
+package kubernetes
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/log"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/gliderlabs/cmd/lib/runtime"
+)
+
+const (
+	containerName = "cmd"
+
+	// LabelApp marks pods run by cmd.
+	LabelApp = "cmd-run"
+)
+
+// ErrVolumesFrom is returned when creating containers sharing volumes with
+// other containers, such as the agent proxy, which pods can not do.
+var ErrVolumesFrom = errors.New("sharing volumes between commands is not supported on kubernetes")
+
+// ErrDockerSocket is returned when creating containers with the Docker
+// socket, since the socket of a node is its own daemon rather than a
+// sandboxed one.
+var ErrDockerSocket = errors.New("docker is not available to commands on kubernetes")
+
+// droppedCapabilities are removed from the default capabilities of command
+// containers, keeping those needed to install packages as root.
+var droppedCapabilities = []corev1.Capability{
+	"NET_RAW", "MKNOD", "SYS_CHROOT", "AUDIT_WRITE", "SETFCAP",
+}
+
+func init() {
+	com.Register("runtime.kubernetes", &Component{},
+		com.Option("namespace", "default", "namespace to run command pods in"),
+		com.Option("kubeconfig", "", "path to kubeconfig, default uses the in-cluster config"),
+		com.Option("registry", "", "registry to push images built from source to"),
+		com.Option("registry_username", "", "username for image lookups in the registry"),
+		com.Option("registry_password", "", "password for image lookups in the registry"),
+		com.Option("builder_image", "gcr.io/kaniko-project/executor:latest", "image used to build commands from source"),
+		com.Option("poll_interval", "1s", "interval between pod status checks"),
+	)
+}
+
+// Component is a runtime backend running commands in a Kubernetes cluster.
+type Component struct {
+	once sync.Once
+	rt   *Runtime
+	err  error
+}
+
+// Runtime returns the cluster runtime, connecting the first time.
+func (c *Component) Runtime(ctx context.Context, image string) (runtime.Runtime, error) {
+	c.once.Do(func() {
+		c.rt, c.err = connect()
+	})
+	return c.rt, c.err
+}
+
+func connect() (*Runtime, error) {
+	var config *rest.Config
+	var err error
+	if path := com.GetString("kubeconfig"); path != "" {
+		config, err = clientcmd.BuildConfigFromFlags("", path)
+	} else {
+		config, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to configure kubernetes client")
+	}
+	client, err := k8s.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	rt := New(client, com.GetString("namespace"))
+	rt.Registry = com.GetString("registry")
+	rt.RegistryUsername = com.GetString("registry_username")
+	rt.RegistryPassword = com.GetString("registry_password")
+	rt.BuilderImage = com.GetString("builder_image")
+	if interval, err := time.ParseDuration(com.GetString("poll_interval")); err == nil {
+		rt.PollInterval = interval
+	} else {
+		log.Info(err)
+	}
+	rt.attach = func(ctx context.Context, namespace, name string, opts remotecommand.StreamOptions) error {
+		req := client.CoreV1().RESTClient().Post().
+			Namespace(namespace).
+			Resource("pods").
+			Name(name).
+			SubResource("attach").
+			VersionedParams(&corev1.PodAttachOptions{
+				Container: containerName,
+				Stdin:     opts.Stdin != nil,
+				Stdout:    opts.Stdout != nil,
+				Stderr:    opts.Stderr != nil,
+				TTY:       opts.Tty,
+			}, scheme.ParameterCodec)
+		exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+		if err != nil {
+			return err
+		}
+		return exec.StreamWithContext(ctx, opts)
+	}
+	return rt, nil
+}
+
+// attachFunc streams the standard streams of a running pod.
+type attachFunc func(ctx context.Context, namespace, name string, opts remotecommand.StreamOptions) error
+
+// Runtime runs containers as pods in a namespace. Images are pulled by the
+// kubelet when pods start, and images built from source are pushed to
+// Registry by a builder pod. Images are looked up in their registry to
+// check they exist and size them.
+type Runtime struct {
+	Namespace        string
+	Registry         string
+	RegistryUsername string
+	RegistryPassword string
+	BuilderImage     string
+	PollInterval     time.Duration
+
+	client k8s.Interface
+	attach attachFunc
+	http   *http.Client
+
+	mu     sync.Mutex
+	images map[string]string
+	sizes  map[string]int64
+	// pods holds pods until started, or until removed if attached
+	pods map[string]*pod
+}
+
+type pod struct {
+	obj    *corev1.Pod
+	stream *runtime.Pipe
+	sizes  sizeQueue
+}
+
+// New returns a runtime creating pods in namespace with client.
+func New(client k8s.Interface, namespace string) *Runtime {
+	return &Runtime{
+		Namespace:    namespace,
+		PollInterval: time.Second,
+		client:       client,
+		attach: func(ctx context.Context, namespace, name string, opts remotecommand.StreamOptions) error {
+			return errors.New("attach not configured")
+		},
+		images: make(map[string]string),
+		sizes:  make(map[string]int64),
+		pods:   make(map[string]*pod),
+	}
+}
+
+func (r *Runtime) Host() string {
+	return "kubernetes/" + r.Namespace
+}
+
+// resolve returns the reference to pull for image, following tags.
+func (r *Runtime) resolve(image string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ref, ok := r.images[image]; ok {
+		return ref
+	}
+	return image
+}
+
+func (r *Runtime) setImage(tag, ref string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.images[tag] = ref
+}
+
+// pushRef returns where an image built as tag is pushed.
+func (r *Runtime) pushRef(tag string) string {
+	return strings.TrimSuffix(r.Registry, "/") + "/" + tag
+}
+
+func (r *Runtime) registryClient() *registry {
+	return &registry{
+		client:   r.http,
+		host:     strings.SplitN(r.Registry, "/", 2)[0],
+		username: r.RegistryUsername,
+		password: r.RegistryPassword,
+	}
+}
+
+// lookup the size of the image ref points to in its registry.
+func (r *Runtime) lookupSize(ctx context.Context, ref string) (int64, error) {
+	size, err := r.registryClient().imageSize(ctx, ref)
+	if err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sizes[ref] = size
+	return size, nil
+}
+
+// ImageExists returns true for images pulled or built by this runtime, and
+// for images already pushed to the registry by a build.
+func (r *Runtime) ImageExists(ctx context.Context, image string) (bool, error) {
+	r.mu.Lock()
+	_, ok := r.images[image]
+	r.mu.Unlock()
+	if ok || r.Registry == "" {
+		return ok, nil
+	}
+	ref := r.pushRef(image)
+	if _, err := r.lookupSize(ctx, ref); err != nil {
+		if err == errManifestUnknown {
+			return false, nil
+		}
+		return false, err
+	}
+	r.setImage(image, ref)
+	return true, nil
+}
+
+// ImageSize returns the compressed size of image in its registry.
+func (r *Runtime) ImageSize(ctx context.Context, image string) (int64, error) {
+	ref := r.resolve(image)
+	r.mu.Lock()
+	size, ok := r.sizes[ref]
+	r.mu.Unlock()
+	if ok {
+		return size, nil
+	}
+	size, err := r.lookupSize(ctx, ref)
+	if err == errManifestUnknown {
+		return 0, errors.Errorf("no such image: %s", image)
+	}
+	return size, err
+}
+
+// PullImage checks image is in its registry and records it to be pulled
+// when a pod using it starts.
+func (r *Runtime) PullImage(ctx context.Context, image string) error {
+	ref := r.resolve(image)
+	if _, err := r.lookupSize(ctx, ref); err != nil {
+		if err == errManifestUnknown {
+			return errors.Errorf("image not found in registry: %s", image)
+		}
+		return err
+	}
+	r.setImage(image, ref)
+	return nil
+}
+
+func (r *Runtime) TagImage(ctx context.Context, image, tag string) error {
+	r.setImage(tag, r.resolve(image))
+	return nil
+}
+
+func (r *Runtime) RemoveImage(ctx context.Context, image string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.images, image)
+	return nil
+}
+
+// BuildImage runs the builder image in a pod with the gzipped build context
+// on standard input, pushing the image to the registry.
+func (r *Runtime) BuildImage(ctx context.Context, buildCtx io.Reader, tag string, out io.Writer) error {
+	if r.Registry == "" {
+		return errors.New("no registry configured to build images")
+	}
+	ref := r.pushRef(tag)
+	id, err := r.Create(ctx, &runtime.Container{
+		Image: r.BuilderImage,
+		Cmd: []string{
+			"--context=tar://stdin",
+			"--dockerfile=Dockerfile",
+			"--destination=" + ref,
+		},
+		Stdin: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer r.Remove(ctx, id)
+	stream, err := r.Attach(ctx, id, false)
+	if err != nil {
+		return err
+	}
+	go func() {
+		zw := gzip.NewWriter(stream)
+		io.Copy(zw, buildCtx)
+		zw.Close()
+		stream.CloseWrite()
+	}()
+	if err := r.Start(ctx, id); err != nil {
+		return err
+	}
+	w := &syncWriter{w: out}
+	if err := stream.Output(w, w); err != nil {
+		return err
+	}
+	status, err := r.Wait(ctx, id)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return &runtime.BuildError{Message: fmt.Sprintf("builder exited with status %d", status)}
+	}
+	r.setImage(tag, ref)
+	return nil
+}
+
+// Create prepares a pod for c. The pod is created when started.
+func (r *Runtime) Create(ctx context.Context, c *runtime.Container) (string, error) {
+	if len(c.VolumesFrom) > 0 {
+		return "", ErrVolumesFrom
+	}
+	if c.DockerSocket {
+		return "", ErrDockerSocket
+	}
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("cmd-%x", b)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pods[name] = &pod{obj: r.podSpec(name, c)}
+	return name, nil
+}
+
+func (r *Runtime) podSpec(name string, c *runtime.Container) *corev1.Pod {
+	image := c.Image
+	if ref, ok := r.images[image]; ok {
+		image = ref
+	}
+	automount, privileged, escalation := false, false, false
+	container := corev1.Container{
+		Name:      containerName,
+		Image:     image,
+		Args:      c.Cmd,
+		Env:       envVars(c.Env),
+		Stdin:     c.Stdin,
+		StdinOnce: c.Stdin,
+		TTY:       c.Tty,
+		Resources: resources(c),
+		SecurityContext: &corev1.SecurityContext{
+			Privileged:               &privileged,
+			AllowPrivilegeEscalation: &escalation,
+			Capabilities:             &corev1.Capabilities{Drop: droppedCapabilities},
+		},
+	}
+	obj := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.Namespace,
+			Labels:    map[string]string{"app": LabelApp},
+			// container labels are kept as annotations since their values
+			// are not valid label values
+			Annotations: c.Labels,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:                corev1.RestartPolicyNever,
+			AutomountServiceAccountToken: &automount,
+			SecurityContext: &corev1.PodSecurityContext{
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+		},
+	}
+	obj.Spec.Containers = []corev1.Container{container}
+	return obj
+}
+
+// envVars converts `k=v` pairs to pod environment variables.
+func envVars(env []string) []corev1.EnvVar {
+	var vars []corev1.EnvVar
+	for _, kv := range env {
+		parts := strings.SplitN(kv, "=", 2)
+		v := corev1.EnvVar{Name: parts[0]}
+		if len(parts) > 1 {
+			v.Value = parts[1]
+		}
+		vars = append(vars, v)
+	}
+	return vars
+}
+
+// resources requests the CPU and memory limits of c so pods are only placed
+// on nodes with room for the full limit.
+func resources(c *runtime.Container) corev1.ResourceRequirements {
+	limits := corev1.ResourceList{}
+	if c.Memory > 0 {
+		limits[corev1.ResourceMemory] = *resource.NewQuantity(c.Memory, resource.BinarySI)
+	}
+	if c.CPUQuota > 0 && c.CPUPeriod > 0 {
+		limits[corev1.ResourceCPU] = *resource.NewMilliQuantity(c.CPUQuota*1000/c.CPUPeriod, resource.DecimalSI)
+	}
+	if len(limits) == 0 {
+		return corev1.ResourceRequirements{}
+	}
+	return corev1.ResourceRequirements{
+		Requests: limits.DeepCopy(),
+		Limits:   limits,
+	}
+}
+
+func (r *Runtime) lookup(id string) (*pod, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pods[id]
+	if !ok {
+		return nil, errors.Errorf("no such pod: %s", id)
+	}
+	return p, nil
+}
+
+func (r *Runtime) Attach(ctx context.Context, id string, tty bool) (runtime.Stream, error) {
+	p, err := r.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p.stream != nil {
+		return nil, errors.Errorf("pod already attached: %s", id)
+	}
+	p.stream = runtime.NewPipe()
+	if tty {
+		p.sizes = make(sizeQueue, 1)
+	}
+	return p.stream, nil
+}
+
+// Start creates the pod and, if attached, streams it once started.
+func (r *Runtime) Start(ctx context.Context, id string) error {
+	p, err := r.lookup(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.CoreV1().Pods(r.Namespace).Create(ctx, p.obj, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	s, sizes := p.stream, p.sizes
+	if s == nil {
+		// nothing is left to track for pods that aren't attached
+		delete(r.pods, id)
+	}
+	r.mu.Unlock()
+	if s == nil {
+		return nil
+	}
+	go func() {
+		s.Exit(r.stream(ctx, p.obj, s, sizes))
+	}()
+	return nil
+}
+
+// stream writes the output of pod obj to s from its logs, which are followed
+// from the start so output written before attaching is not lost. Pod logs
+// don't separate stderr, so all output is written to stdout. Stdin and
+// terminal sizes are passed by attaching while the pod runs.
+func (r *Runtime) stream(ctx context.Context, obj *corev1.Pod, s *runtime.Pipe, sizes sizeQueue) error {
+	status, err := r.waitFor(ctx, obj.Name, func(status *corev1.PodStatus) bool {
+		return status.Phase != corev1.PodPending || pullFailure(status) != ""
+	})
+	if err != nil {
+		return err
+	}
+	if reason := pullFailure(status); reason != "" {
+		return errors.Errorf("unable to pull image %s: %s", obj.Spec.Containers[0].Image, reason)
+	}
+	c := obj.Spec.Containers[0]
+	if status.Phase != corev1.PodRunning || (!c.Stdin && !c.TTY) {
+		return r.logs(ctx, obj.Name, true, s.Stdout)
+	}
+	opts := remotecommand.StreamOptions{Tty: c.TTY}
+	if c.Stdin {
+		opts.Stdin = s.Stdin
+	}
+	if c.TTY {
+		opts.TerminalSizeQueue = sizes
+	}
+	attachCtx, cancel := context.WithCancel(ctx)
+	attached := make(chan struct{})
+	go func() {
+		defer close(attached)
+		if err := r.attach(attachCtx, r.Namespace, obj.Name, opts); err != nil && attachCtx.Err() == nil {
+			// the pod may have exited before attaching, output is still logged
+			log.Info(obj.Name, err)
+		}
+	}()
+	err = r.logs(ctx, obj.Name, true, s.Stdout)
+	cancel()
+	<-attached
+	return err
+}
+
+// pullFailure returns the reason a pending pod is unable to pull its image,
+// if any.
+func pullFailure(status *corev1.PodStatus) string {
+	for _, c := range status.ContainerStatuses {
+		if c.State.Waiting == nil {
+			continue
+		}
+		switch c.State.Waiting.Reason {
+		case "ErrImagePull", "ImagePullBackOff", "InvalidImageName":
+			return c.State.Waiting.Reason
+		}
+	}
+	return ""
+}
+
+func (r *Runtime) logs(ctx context.Context, name string, follow bool, out io.Writer) error {
+	logs, err := r.client.CoreV1().Pods(r.Namespace).GetLogs(name, &corev1.PodLogOptions{
+		Container: containerName,
+		Follow:    follow,
+	}).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	_, err = io.Copy(out, logs)
+	return err
+}
+
+// waitFor polls the status of pod name until done returns true.
+func (r *Runtime) waitFor(ctx context.Context, name string, done func(*corev1.PodStatus) bool) (*corev1.PodStatus, error) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		obj, err := r.client.CoreV1().Pods(r.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if done(&obj.Status) {
+			return &obj.Status, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runtime) Wait(ctx context.Context, id string) (int, error) {
+	status, err := r.waitFor(ctx, id, func(status *corev1.PodStatus) bool {
+		return status.Phase == corev1.PodSucceeded || status.Phase == corev1.PodFailed
+	})
+	if err != nil {
+		return 0, err
+	}
+	return exitCode(status), nil
+}
+
+// exitCode returns the exit status of the command container of an exited
+// pod.
+func exitCode(status *corev1.PodStatus) int {
+	for _, c := range status.ContainerStatuses {
+		if c.Name == containerName && c.State.Terminated != nil {
+			return int(c.State.Terminated.ExitCode)
+		}
+	}
+	if status.Phase == corev1.PodFailed {
+		return 255
+	}
+	return 0
+}
+
+// Resize the terminal of an attached pod, dropping sizes not yet sent.
+func (r *Runtime) Resize(ctx context.Context, id string, width, height int) error {
+	size := remotecommand.TerminalSize{Width: uint16(width), Height: uint16(height)}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pods[id]
+	if !ok {
+		return errors.Errorf("no such pod: %s", id)
+	}
+	if p.sizes == nil {
+		return errors.Errorf("pod not attached with a tty: %s", id)
+	}
+	select {
+	case <-p.sizes:
+	default:
+	}
+	select {
+	case p.sizes <- size:
+	default:
+	}
+	return nil
+}
+
+// Remove deletes the pod immediately.
+func (r *Runtime) Remove(ctx context.Context, id string) error {
+	r.mu.Lock()
+	p, tracked := r.pods[id]
+	delete(r.pods, id)
+	if tracked && p.sizes != nil {
+		close(p.sizes)
+	}
+	r.mu.Unlock()
+	if tracked && p.stream != nil {
+		p.stream.Close()
+	}
+	grace := int64(0)
+	err := r.client.CoreV1().Pods(r.Namespace).Delete(ctx, id, metav1.DeleteOptions{
+		GracePeriodSeconds: &grace,
+	})
+	if apierrors.IsNotFound(err) {
+		if tracked {
+			// the pod may not have been started yet
+			return nil
+		}
+		return runtime.ErrNotFound
+	}
+	return err
+}
+
+// List returns pods run by cmd with all of labels as annotations.
+func (r *Runtime) List(ctx context.Context, labels map[string]string) ([]*runtime.ContainerInfo, error) {
+	pods, err := r.client.CoreV1().Pods(r.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=" + LabelApp,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var infos []*runtime.ContainerInfo
+	for i := range pods.Items {
+		obj := &pods.Items[i]
+		match := true
+		for k, v := range labels {
+			if obj.Annotations[k] != v {
+				match = false
+			}
+		}
+		if match {
+			infos = append(infos, podInfo(obj))
+		}
+	}
+	return infos, nil
+}
+
+func (r *Runtime) Inspect(ctx context.Context, id string) (*runtime.ContainerInfo, error) {
+	obj, err := r.client.CoreV1().Pods(r.Namespace).Get(ctx, id, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, runtime.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return podInfo(obj), nil
+}
+
+// podInfo describes obj as a container.
+func podInfo(obj *corev1.Pod) *runtime.ContainerInfo {
+	info := &runtime.ContainerInfo{
+		ID:      obj.Name,
+		Labels:  obj.Annotations,
+		State:   "created",
+		Created: obj.CreationTimestamp.UTC(),
+	}
+	switch obj.Status.Phase {
+	case corev1.PodRunning:
+		info.State = "running"
+	case corev1.PodSucceeded, corev1.PodFailed:
+		info.State = "exited"
+		info.ExitCode = exitCode(&obj.Status)
+	}
+	return info
+}
+
+// Logs writes the combined output of the pod to stdout, since pod logs do
+// not separate stderr.
+func (r *Runtime) Logs(ctx context.Context, id string, follow bool, stdout, stderr io.Writer) error {
+	// logs are unavailable until the container is created
+	_, err := r.waitFor(ctx, id, func(status *corev1.PodStatus) bool {
+		return status.Phase != corev1.PodPending || pullFailure(status) != ""
+	})
+	if apierrors.IsNotFound(err) {
+		return runtime.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return r.logs(ctx, id, follow, stdout)
+}
+
+// Kill sets a deadline on the pod, so the kubelet stops it while keeping
+// its status and logs.
+func (r *Runtime) Kill(ctx context.Context, id string) error {
+	pods := r.client.CoreV1().Pods(r.Namespace)
+	obj, err := pods.Get(ctx, id, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return runtime.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	deadline := int64(1)
+	obj.Spec.ActiveDeadlineSeconds = &deadline
+	_, err = pods.Update(ctx, obj, metav1.UpdateOptions{})
+	return err
+}
+
+// sizeQueue passes terminal sizes to an attached stream.
+type sizeQueue chan remotecommand.TerminalSize
+
+func (q sizeQueue) Next() *remotecommand.TerminalSize {
+	size, ok := <-q
+	if !ok {
+		return nil
+	}
+	return &size
+}
+
+// syncWriter serializes writes to w.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
@@ -0,0 +1,326 @@
+package kubernetes
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/gliderlabs/cmd/lib/runtime"
+)
+
+// newRuntime returns a runtime with a fake clientset where created pods are
+// given phase.
+func newRuntime(phase corev1.PodPhase) (*Runtime, *fake.Clientset) {
+	// the simple clientset is used since field management can not find
+	// types under the vendor path
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		pod.Status.Phase = phase
+		return false, nil, nil
+	})
+	rt := New(client, "cmd")
+	rt.PollInterval = time.Millisecond
+	return rt, client
+}
+
+// exit sets pod name as exited with status.
+func exit(t *testing.T, client *fake.Clientset, name string, status int32) {
+	pods := client.CoreV1().Pods("cmd")
+	pod, err := pods.Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod.Status.Phase = corev1.PodSucceeded
+	if status != 0 {
+		pod.Status.Phase = corev1.PodFailed
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name: containerName,
+		State: corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{ExitCode: status},
+		},
+	}}
+	if _, err := pods.UpdateStatus(context.Background(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	rt, client := newRuntime(corev1.PodRunning)
+	rt.TagImage(ctx, "alpine", "user-cmd")
+
+	id, err := rt.Create(ctx, &runtime.Container{
+		Image:     "user-cmd",
+		Cmd:       []string{"arg"},
+		Env:       []string{"USER=caller", "EMPTY"},
+		Labels:    map[string]string{"io.cmd.job.cmd": "user/cmd"},
+		Stdin:     true,
+		Memory:    256 * 1024 * 1024,
+		CPUPeriod: 100000,
+		CPUQuota:  50000,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, rt.Start(ctx, id))
+
+	pod, err := client.CoreV1().Pods("cmd").Get(ctx, id, metav1.GetOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, corev1.RestartPolicyNever, pod.Spec.RestartPolicy)
+	assert.Equal(t, "user/cmd", pod.Annotations["io.cmd.job.cmd"])
+	c := pod.Spec.Containers[0]
+	assert.Equal(t, "alpine", c.Image, "Tags should resolve to the pulled image")
+	assert.Equal(t, []string{"arg"}, c.Args)
+	assert.Equal(t, []corev1.EnvVar{{Name: "USER", Value: "caller"}, {Name: "EMPTY"}}, c.Env)
+	assert.True(t, c.Stdin)
+	assert.True(t, c.StdinOnce)
+	mem := resource.MustParse("256Mi")
+	cpu := resource.MustParse("500m")
+	assert.Equal(t, 0, c.Resources.Limits.Memory().Cmp(mem))
+	assert.Equal(t, 0, c.Resources.Limits.Cpu().Cmp(cpu))
+	assert.Equal(t, 0, c.Resources.Requests.Memory().Cmp(mem), "Limits should be requested")
+	if assert.NotNil(t, c.SecurityContext) {
+		assert.False(t, *c.SecurityContext.Privileged)
+		assert.False(t, *c.SecurityContext.AllowPrivilegeEscalation)
+		assert.Contains(t, c.SecurityContext.Capabilities.Drop, corev1.Capability("NET_RAW"))
+	}
+	assert.Equal(t, corev1.SeccompProfileTypeRuntimeDefault, pod.Spec.SecurityContext.SeccompProfile.Type)
+	assert.Empty(t, pod.Spec.Volumes, "No host paths should be mounted")
+
+	_, err = rt.Create(ctx, &runtime.Container{Image: "alpine", VolumesFrom: []string{"proxy"}})
+	assert.Equal(t, ErrVolumesFrom, err)
+	_, err = rt.Create(ctx, &runtime.Container{Image: "alpine", DockerSocket: true})
+	assert.Equal(t, ErrDockerSocket, err, "The node Docker socket should not be exposed")
+
+	assert.NoError(t, rt.Remove(ctx, id))
+	_, err = client.CoreV1().Pods("cmd").Get(ctx, id, metav1.GetOptions{})
+	assert.Error(t, err, "Pod should be deleted")
+}
+
+func TestAttach(t *testing.T) {
+	ctx := context.Background()
+	rt, client := newRuntime(corev1.PodRunning)
+	sizes := make(chan *remotecommand.TerminalSize, 1)
+	var input []byte
+	rt.attach = func(ctx context.Context, namespace, name string, opts remotecommand.StreamOptions) error {
+		assert.True(t, opts.Tty)
+		assert.Nil(t, opts.Stdout, "Output should be read from logs")
+		assert.Nil(t, opts.Stderr, "Output should be read from logs")
+		sizes <- opts.TerminalSizeQueue.Next()
+		input, _ = ioutil.ReadAll(opts.Stdin)
+		exit(t, client, name, 3)
+		return nil
+	}
+
+	id, _ := rt.Create(ctx, &runtime.Container{Image: "alpine", Stdin: true, Tty: true})
+	stream, err := rt.Attach(ctx, id, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, rt.Start(ctx, id))
+	assert.NoError(t, rt.Resize(ctx, id, 80, 24))
+	assert.Equal(t, &remotecommand.TerminalSize{Width: 80, Height: 24}, <-sizes)
+	io.WriteString(stream, "input")
+	stream.CloseWrite()
+
+	var stdout bytes.Buffer
+	assert.NoError(t, stream.Output(&stdout, ioutil.Discard))
+	assert.Equal(t, "input", string(input))
+	assert.Equal(t, "fake logs", stdout.String(), "Output should be read from logs")
+	status, err := rt.Wait(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, status)
+	assert.NoError(t, rt.Remove(ctx, id))
+}
+
+func TestAttachExited(t *testing.T) {
+	ctx := context.Background()
+	rt, _ := newRuntime(corev1.PodSucceeded)
+	rt.attach = func(ctx context.Context, namespace, name string, opts remotecommand.StreamOptions) error {
+		t.Error("Exited pods should not be attached")
+		return nil
+	}
+
+	id, _ := rt.Create(ctx, &runtime.Container{Image: "alpine"})
+	stream, _ := rt.Attach(ctx, id, false)
+	assert.NoError(t, rt.Start(ctx, id))
+	var stdout bytes.Buffer
+	assert.NoError(t, stream.Output(&stdout, ioutil.Discard))
+	assert.Equal(t, "fake logs", stdout.String(), "Output should be read from logs")
+}
+
+func TestBuildImage(t *testing.T) {
+	ctx := context.Background()
+	rt, client := newRuntime(corev1.PodRunning)
+	assert.Error(t, rt.BuildImage(ctx, strings.NewReader(""), "cmd-source:abc", ioutil.Discard),
+		"Builds should require a registry")
+
+	rt.Registry = "registry.example.com/cmd/"
+	rt.BuilderImage = "builder"
+	var files, args []string
+	status := int32(0)
+	rt.attach = func(ctx context.Context, namespace, name string, opts remotecommand.StreamOptions) error {
+		pod, _ := client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		assert.Equal(t, "builder", pod.Spec.Containers[0].Image)
+		args = pod.Spec.Containers[0].Args
+		zr, err := gzip.NewReader(opts.Stdin)
+		if assert.NoError(t, err) {
+			tr := tar.NewReader(zr)
+			for {
+				hdr, err := tr.Next()
+				if err != nil {
+					break
+				}
+				files = append(files, hdr.Name)
+			}
+		}
+		exit(t, client, name, status)
+		return nil
+	}
+
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	tw.WriteHeader(&tar.Header{Name: "Dockerfile", Mode: 0600, Size: 11})
+	tw.Write([]byte("FROM alpine"))
+	tw.Close()
+	buildCtx := buf.Bytes()
+
+	var out bytes.Buffer
+	assert.NoError(t, rt.BuildImage(ctx, bytes.NewReader(buildCtx), "cmd-source:abc", &out))
+	assert.Equal(t, []string{"Dockerfile"}, files)
+	assert.Contains(t, args, "--destination=registry.example.com/cmd/cmd-source:abc")
+	assert.Equal(t, "fake logs", out.String())
+	exists, _ := rt.ImageExists(ctx, "cmd-source:abc")
+	assert.True(t, exists)
+	assert.Equal(t, "registry.example.com/cmd/cmd-source:abc", rt.resolve("cmd-source:abc"))
+
+	status = 1
+	err := rt.BuildImage(ctx, bytes.NewReader(buildCtx), "cmd-source:def", ioutil.Discard)
+	assert.IsType(t, &runtime.BuildError{}, err)
+}
+
+func TestJobs(t *testing.T) {
+	ctx := context.Background()
+	rt, client := newRuntime(corev1.PodRunning)
+	id, err := rt.Create(ctx, &runtime.Container{
+		Image:  "alpine",
+		Labels: map[string]string{"io.cmd.job": "true", "io.cmd.job.user": "user"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, rt.Start(ctx, id))
+	assert.Empty(t, rt.pods, "Detached pods should not be tracked once started")
+	other, _ := rt.Create(ctx, &runtime.Container{
+		Image:  "alpine",
+		Labels: map[string]string{"io.cmd.job": "true", "io.cmd.job.user": "other"},
+	})
+	rt.Start(ctx, other)
+
+	infos, err := rt.List(ctx, map[string]string{"io.cmd.job.user": "user"})
+	assert.NoError(t, err)
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, id, infos[0].ID)
+		assert.Equal(t, "running", infos[0].State)
+	}
+
+	var logs bytes.Buffer
+	assert.NoError(t, rt.Logs(ctx, id, false, &logs, ioutil.Discard))
+	assert.Equal(t, "fake logs", logs.String())
+
+	assert.NoError(t, rt.Kill(ctx, id))
+	pod, _ := client.CoreV1().Pods("cmd").Get(ctx, id, metav1.GetOptions{})
+	if assert.NotNil(t, pod.Spec.ActiveDeadlineSeconds, "Killed pods should be stopped by deadline") {
+		assert.Equal(t, int64(1), *pod.Spec.ActiveDeadlineSeconds)
+	}
+	exit(t, client, id, 137)
+	info, err := rt.Inspect(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, &runtime.ContainerInfo{
+		ID:       id,
+		Labels:   map[string]string{"io.cmd.job": "true", "io.cmd.job.user": "user"},
+		State:    "exited",
+		ExitCode: 137,
+		Created:  pod.CreationTimestamp.UTC(),
+	}, info)
+
+	assert.NoError(t, rt.Remove(ctx, id), "Pods should be removed without being tracked")
+	_, err = rt.Inspect(ctx, id)
+	assert.Equal(t, runtime.ErrNotFound, err)
+	assert.Equal(t, runtime.ErrNotFound, rt.Remove(ctx, id))
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	var ts *httptest.Server
+	ts = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			assert.Equal(t, "repository:cmd/tool:pull", r.URL.Query().Get("scope"))
+			user, pass, _ := r.BasicAuth()
+			assert.Equal(t, "user:pass", user+":"+pass, "Credentials should be sent to the registry")
+			io.WriteString(w, `{"token":"secret"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("Www-Authenticate", fmt.Sprintf(
+				`Bearer realm="%s/token",service="registry",scope="repository:cmd/tool:pull"`, ts.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/cmd/tool/manifests/latest":
+			io.WriteString(w, `{"manifests":[
+				{"digest":"sha256:arm","platform":{"os":"linux","architecture":"arm64"}},
+				{"digest":"sha256:amd","platform":{"os":"linux","architecture":"amd64"}}]}`)
+		case "/v2/cmd/tool/manifests/sha256:amd":
+			io.WriteString(w, `{"config":{"size":10},"layers":[{"size":100},{"size":1000}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	host := strings.TrimPrefix(ts.URL, "https://")
+
+	rt, _ := newRuntime(corev1.PodRunning)
+	rt.http = ts.Client()
+	rt.Registry = host + "/cmd/"
+	rt.RegistryUsername = "user"
+	rt.RegistryPassword = "pass"
+
+	exists, err := rt.ImageExists(ctx, "tool")
+	assert.NoError(t, err)
+	assert.True(t, exists, "Images pushed to the registry should exist")
+	exists, err = rt.ImageExists(ctx, "missing")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.NoError(t, rt.PullImage(ctx, host+"/cmd/tool"))
+	size, err := rt.ImageSize(ctx, host+"/cmd/tool")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1110), size, "Manifest lists should be sized by their linux/amd64 image")
+	assert.NoError(t, rt.TagImage(ctx, host+"/cmd/tool", "user-tool"))
+	size, _ = rt.ImageSize(ctx, "user-tool")
+	assert.Equal(t, int64(1110), size, "Tags should be sized by their image")
+
+	assert.Error(t, rt.PullImage(ctx, host+"/cmd/missing"))
+	_, err = rt.ImageSize(ctx, host+"/cmd/missing")
+	assert.Error(t, err, "Images that can't be sized should fail plan checks")
+}
@@ -0,0 +1,239 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/pkg/errors"
+)
+
+// errManifestUnknown is returned for images missing from their registry.
+var errManifestUnknown = errors.New("manifest unknown")
+
+// manifestTypes are the manifest media types accepted from registries.
+var manifestTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// manifest is the subset of an image manifest or manifest list used to
+// size an image.
+type manifest struct {
+	Config struct {
+		Size int64 `json:"size"`
+	} `json:"config"`
+	Layers []struct {
+		Size int64 `json:"size"`
+	} `json:"layers"`
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// registry looks up images with the Docker registry HTTP API.
+type registry struct {
+	client *http.Client
+	// credentials are only sent to host
+	host     string
+	username string
+	password string
+}
+
+// imageSize returns the compressed size of image as stored in its registry,
+// or errManifestUnknown if it does not exist. Manifest lists are sized by
+// their linux/amd64 image.
+func (r *registry) imageSize(ctx context.Context, image string) (int64, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return 0, err
+	}
+	host := reference.Domain(named)
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	repo := reference.Path(named)
+	ref := "latest"
+	if digested, ok := named.(reference.Digested); ok {
+		ref = digested.Digest().String()
+	} else if tagged, ok := named.(reference.Tagged); ok {
+		ref = tagged.Tag()
+	}
+
+	m, err := r.manifest(ctx, host, repo, ref)
+	if err != nil {
+		return 0, err
+	}
+	if len(m.Manifests) > 0 {
+		digest := m.Manifests[0].Digest
+		for _, entry := range m.Manifests {
+			if entry.Platform.OS == "linux" && entry.Platform.Architecture == "amd64" {
+				digest = entry.Digest
+				break
+			}
+		}
+		if m, err = r.manifest(ctx, host, repo, digest); err != nil {
+			return 0, err
+		}
+	}
+	size := m.Config.Size
+	for _, layer := range m.Layers {
+		size += layer.Size
+	}
+	return size, nil
+}
+
+func (r *registry) manifest(ctx context.Context, host, repo, ref string) (*manifest, error) {
+	u := "https://" + host + "/v2/" + repo + "/manifests/" + ref
+	res, err := r.get(ctx, u, "")
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusUnauthorized {
+		challenge := res.Header.Get("Www-Authenticate")
+		res.Body.Close()
+		auth, err := r.authorize(ctx, host, challenge)
+		if err != nil {
+			return nil, err
+		}
+		if res, err = r.get(ctx, u, auth); err != nil {
+			return nil, err
+		}
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, errManifestUnknown
+	case res.StatusCode != http.StatusOK:
+		return nil, errors.Errorf("registry %s: %s", host, res.Status)
+	}
+	m := new(manifest)
+	if err := json.NewDecoder(io.LimitReader(res.Body, 4<<20)).Decode(m); err != nil {
+		return nil, errors.Wrapf(err, "registry %s", host)
+	}
+	return m, nil
+}
+
+func (r *registry) get(ctx context.Context, u, auth string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	return r.httpClient().Do(req.WithContext(ctx))
+}
+
+func (r *registry) httpClient() *http.Client {
+	if r.client != nil {
+		return r.client
+	}
+	return http.DefaultClient
+}
+
+// authorize answers an authentication challenge from host, returning the
+// Authorization header to retry with. Bearer tokens are requested
+// anonymously unless host has credentials.
+func (r *registry) authorize(ctx context.Context, host, challenge string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	hasCreds := host == r.host && r.username != ""
+	switch scheme {
+	case "basic":
+		if !hasCreds {
+			return "", errors.Errorf("registry %s requires credentials", host)
+		}
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.SetBasicAuth(r.username, r.password)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+	default:
+		return "", errors.Errorf("registry %s: unsupported authentication %q", host, challenge)
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme != "https" {
+		return "", errors.Errorf("registry %s: invalid token realm %q", host, params["realm"])
+	}
+	q := realm.Query()
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			q.Set(k, params[k])
+		}
+	}
+	realm.RawQuery = q.Encode()
+	req, err := http.NewRequest("GET", realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasCreds {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	res, err := r.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, res.Body)
+		return "", errors.Errorf("registry %s: token request: %s", host, res.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return "", errors.Wrapf(err, "registry %s: token request", host)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseChallenge splits a WWW-Authenticate header into its lower case
+// scheme and parameters.
+func parseChallenge(header string) (string, map[string]string) {
+	params := make(map[string]string)
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	scheme := strings.ToLower(parts[0])
+	if len(parts) < 2 {
+		return scheme, params
+	}
+	rest := parts[1]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimSpace(rest[eq+1:])
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return scheme, params
+}
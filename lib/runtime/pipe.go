@@ -0,0 +1,64 @@
+package runtime
+
+import "io"
+
+// Pipe is a Stream connected to a container with in-memory pipes, for
+// runtimes that copy container streams themselves. The container reads
+// Stdin and writes Stdout and Stderr.
+type Pipe struct {
+	Stdin  *io.PipeReader
+	Stdout *io.PipeWriter
+	Stderr *io.PipeWriter
+
+	stdin  *io.PipeWriter
+	stdout *io.PipeReader
+	stderr *io.PipeReader
+}
+
+// NewPipe returns a Pipe ready to attach to a container.
+func NewPipe() *Pipe {
+	p := &Pipe{}
+	p.Stdin, p.stdin = io.Pipe()
+	p.stdout, p.Stdout = io.Pipe()
+	p.stderr, p.Stderr = io.Pipe()
+	return p
+}
+
+func (p *Pipe) Write(b []byte) (int, error) {
+	return p.stdin.Write(b)
+}
+
+func (p *Pipe) CloseWrite() error {
+	return p.stdin.Close()
+}
+
+func (p *Pipe) Close() error {
+	p.stdin.Close()
+	p.stdout.Close()
+	p.stderr.Close()
+	return nil
+}
+
+// Exit ends output with err, or io.EOF if nil, and discards further input
+// once the container has exited.
+func (p *Pipe) Exit(err error) {
+	p.Stdout.CloseWithError(err)
+	p.Stderr.CloseWithError(err)
+	p.Stdin.Close()
+}
+
+func (p *Pipe) Output(stdout, stderr io.Writer) error {
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(stderr, p.stderr)
+		errCh <- err
+	}()
+	_, err := io.Copy(stdout, p.stdout)
+	if stderrErr := <-errCh; err == nil {
+		err = stderrErr
+	}
+	if err == io.ErrClosedPipe {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+// Package runtime defines how command containers are built and run so the
+// container engine can be swapped without changing how commands run.
+package runtime
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+)
+
+// ErrNotFound is returned for containers that don't exist.
+var ErrNotFound = errors.New("container not found")
+
+func init() {
+	com.Register("runtime", struct{}{},
+		com.Option("backend", "runtime.docker", "Container runtime backend"))
+}
+
+// Selected returns the configured runtime backend.
+func Selected() Backend {
+	backend := com.Select(com.GetString("backend"), new(Backend))
+	if backend == nil {
+		panic("Unable to find selected runtime: " + com.GetString("backend"))
+	}
+	return backend.(Backend)
+}
+
+// Backend provides runtimes to run commands with.
+type Backend interface {
+	// Runtime returns a runtime to run image with, preferring one where the
+	// image is already present. An empty image returns a runtime for
+	// managing existing containers wherever they run.
+	Runtime(ctx context.Context, image string) (Runtime, error)
+}
+
+// Runtime builds images and runs containers on a single host, and manages
+// containers it has created.
+type Runtime interface {
+	// Host identifies where containers are run for logging.
+	Host() string
+
+	ImageExists(ctx context.Context, image string) (bool, error)
+	ImageSize(ctx context.Context, image string) (int64, error)
+	// BuildImage builds the tar build context and tags the result, writing
+	// build output to out. Failed build steps return a *BuildError.
+	BuildImage(ctx context.Context, buildCtx io.Reader, tag string, out io.Writer) error
+	PullImage(ctx context.Context, image string) error
+	TagImage(ctx context.Context, image, tag string) error
+	RemoveImage(ctx context.Context, image string) error
+
+	// Create a container and return its ID.
+	Create(ctx context.Context, c *Container) (string, error)
+	// Attach to the standard streams of a created container. Attach before
+	// Start to receive all output.
+	Attach(ctx context.Context, id string, tty bool) (Stream, error)
+	Start(ctx context.Context, id string) error
+	// Wait for a container to exit and return its exit status.
+	Wait(ctx context.Context, id string) (int, error)
+	Resize(ctx context.Context, id string, width, height int) error
+	// Remove a container, killing it if running.
+	Remove(ctx context.Context, id string) error
+
+	// List returns containers with all of labels.
+	List(ctx context.Context, labels map[string]string) ([]*ContainerInfo, error)
+	// Inspect returns a container by ID or ID prefix, or ErrNotFound.
+	Inspect(ctx context.Context, id string) (*ContainerInfo, error)
+	// Logs writes the output of a container to stdout and stderr. When
+	// follow is set output is streamed until the container exits.
+	Logs(ctx context.Context, id string, follow bool, stdout, stderr io.Writer) error
+	// Kill stops a running container, keeping it for its logs until removed.
+	Kill(ctx context.Context, id string) error
+}
+
+// VolumeSharer is implemented by runtimes able to mount the volumes of
+// other containers with Container.VolumesFrom, which agent forwarding needs.
+type VolumeSharer interface {
+	SharesVolumes() bool
+}
+
+// SharesVolumes returns true if rt supports Container.VolumesFrom.
+func SharesVolumes(rt Runtime) bool {
+	sharer, ok := rt.(VolumeSharer)
+	return ok && sharer.SharesVolumes()
+}
+
+// Container describes a container to create.
+type Container struct {
+	Image  string
+	Cmd    []string
+	Env    []string
+	Labels map[string]string
+
+	// Tty allocates a terminal, combining all output on stdout.
+	Tty bool
+	// Stdin keeps standard input open for a single attached stream.
+	Stdin bool
+	// AutoRemove removes the container once it exits.
+	AutoRemove bool
+
+	Memory    int64
+	CPUPeriod int64
+	CPUQuota  int64
+
+	// DockerSocket exposes the Docker socket of the host in the container.
+	DockerSocket bool
+	// VolumesFrom mounts the volumes of other containers.
+	VolumesFrom []string
+}
+
+// ContainerInfo describes an existing container.
+type ContainerInfo struct {
+	ID     string
+	Labels map[string]string
+	// State is one of created, running or exited.
+	State    string
+	ExitCode int
+	Created  time.Time
+}
+
+// Stream is attached to the standard streams of a container. Writes go to
+// standard input.
+type Stream interface {
+	io.WriteCloser
+	// CloseWrite closes standard input.
+	CloseWrite() error
+	// Output copies container output to stdout and stderr until the
+	// container exits or the stream is closed.
+	Output(stdout, stderr io.Writer) error
+}
+
+// BuildError is returned when a runtime reports a failed build step.
+type BuildError struct {
+	Message string
+}
+
+func (e *BuildError) Error() string {
+	return "build failed: " + e.Message
+}
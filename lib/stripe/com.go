@@ -9,7 +9,8 @@ func init() {
 	com.Register("stripe", &Component{},
 		com.Option("secret_key", "", "Stripe secret key"),
 		com.Option("pub_key", "", "Stripe publishable key"),
-		com.Option("event_endpoint", "/_stripe", "Path to handle Stripe webhooks"))
+		com.Option("event_endpoint", "/_stripe", "Path to handle Stripe webhooks"),
+		com.Option("webhook_secret", "", "Stripe webhook endpoint signing secret"))
 }
 
 // Component ...
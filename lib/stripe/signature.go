@@ -0,0 +1,66 @@
+package stripe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the header Stripe signs webhook events with.
+const SignatureHeader = "Stripe-Signature"
+
+// SignatureTolerance is how far the timestamp of a signed event may be from
+// the time it is received.
+var SignatureTolerance = 5 * time.Minute
+
+// Errors returned for events without a valid signature
+var (
+	ErrInvalidSignature = errors.New("stripe: invalid signature")
+	ErrSignatureExpired = errors.New("stripe: signature timestamp out of tolerance")
+)
+
+// VerifySignature checks the Stripe-Signature header of an event with body
+// against the endpoint secret. The header has a timestamp and one or more v1
+// signatures, any of which may match.
+func VerifySignature(header string, body []byte, secret string, now time.Time) error {
+	if secret == "" {
+		return ErrInvalidSignature
+	}
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			if sig, err := hex.DecodeString(kv[1]); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, sig := range signatures {
+		if hmac.Equal(sig, expected) {
+			if age := now.Sub(time.Unix(unix, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+				return ErrSignatureExpired
+			}
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
@@ -6,8 +6,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"text/template"
+	"time"
 
 	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/log"
 )
 
 func (c *Component) WebTemplateFuncMap(r *http.Request) template.FuncMap {
@@ -24,7 +26,19 @@ func (c *Component) MatchHTTP(r *http.Request) bool {
 }
 
 func (c *Component) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	eventID, eventType, err := parseEvent(r)
+	data, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = VerifySignature(r.Header.Get(SignatureHeader), data, com.GetString("webhook_secret"), time.Now())
+	if err != nil {
+		log.Info(r, "stripe event rejected", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	eventID, eventType, err := parseEvent(data)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -42,14 +56,9 @@ func (c *Component) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func parseEvent(r *http.Request) (string, string, error) {
-	data, err := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
-	if err != nil {
-		return "", "", err
-	}
+func parseEvent(data []byte) (string, string, error) {
 	var event map[string]interface{}
-	err = json.Unmarshal(data, &event)
+	err := json.Unmarshal(data, &event)
 	if err != nil {
 		return "", "", err
 	}
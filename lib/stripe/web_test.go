@@ -0,0 +1,49 @@
+package stripe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/comlab/pkg/com"
+	"github.com/gliderlabs/comlab/pkg/com/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(body, secret string, at time.Time) string {
+	timestamp := fmt.Sprint(at.Unix())
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestServeHTTP(t *testing.T) {
+	cfg := viper.NewConfig()
+	cfg.Set("stripe.webhook_secret", "whsec")
+	com.SetConfig(cfg)
+	defer com.SetConfig(viper.NewConfig())
+
+	body := `{"id": "evt_1", "type": "test.unhandled"}`
+	deliver := func(signature string) int {
+		req := httptest.NewRequest("POST", "/_stripe", strings.NewReader(body))
+		if signature != "" {
+			req.Header.Set(SignatureHeader, signature)
+		}
+		w := httptest.NewRecorder()
+		(&Component{}).ServeHTTP(w, req)
+		return w.Code
+	}
+
+	now := time.Now()
+	assert.Equal(t, http.StatusUnauthorized, deliver(""), "Unsigned events should be rejected")
+	assert.Equal(t, http.StatusUnauthorized, deliver(sign(body, "other", now)))
+	assert.Equal(t, http.StatusUnauthorized, deliver(sign(body, "whsec", now.Add(-time.Hour))),
+		"Events outside the tolerance should be rejected")
+	assert.Equal(t, http.StatusOK, deliver(sign(body, "whsec", now)))
+}